@@ -15,6 +15,7 @@ import (
 	"os"
 	"path"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -54,6 +55,16 @@ const (
 	// DID_PREPARE is emitted when App.Prepare ends without errors.
 	// The object is the App.
 	DID_PREPARE = "gnd.la/app.did-prepare"
+	// WILL_SHUTDOWN is emitted at the beginning of App.Shutdown,
+	// before the App stops accepting new connections. The object
+	// is the App.
+	WILL_SHUTDOWN = "gnd.la/app.will-shutdown"
+	// DID_SHUTDOWN is emitted at the end of App.Shutdown, after
+	// all the pending requests and background contexts have finished
+	// and the shared resources have been closed or, if the Shutdown
+	// context expired, when giving up on waiting for them. The object
+	// is the App.
+	DID_SHUTDOWN = "gnd.la/app.did-shutdown"
 )
 
 var (
@@ -95,13 +106,73 @@ type LanguageHandler func(*Context) string
 type handlerInfo struct {
 	host      string
 	name      string
+	methods   []string
 	path      string
 	pathMatch []int
+	prefix    string
 	re        *regexp.Regexp
 	rc        *regexpCache
+	tmpl      *routeTemplate
 	handler   Handler
 }
 
+// match returns the submatch indexes for the given path
+// or nil if the handler pattern does not match it.
+func (h *handlerInfo) match(path string) []int {
+	if h.path != "" {
+		if h.path == path {
+			return h.pathMatch
+		}
+		return nil
+	}
+	if h.tmpl != nil {
+		return h.tmpl.match(path)
+	}
+	// Use FindStringSubmatchIndex, since this way we can
+	// reuse the slices used to store context arguments
+	return h.re.FindStringSubmatchIndex(path)
+}
+
+// acceptsMethod returns true iff the handler accepts requests
+// with the given method. Handlers which accept GET requests
+// accept HEAD requests too.
+func (h *handlerInfo) acceptsMethod(method string) bool {
+	if len(h.methods) == 0 {
+		return true
+	}
+	for _, v := range h.methods {
+		if v == method || (v == "GET" && method == "HEAD") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedMethods appends the methods accepted by the handler
+// to the given slice and returns it.
+func (h *handlerInfo) allowedMethods(methods []string) []string {
+	for _, v := range h.methods {
+		methods = append(methods, v)
+		if v == "GET" {
+			methods = append(methods, "HEAD")
+		}
+	}
+	return methods
+}
+
+func uniqueStrings(s []string) []string {
+	if len(s) == 0 {
+		return s
+	}
+	unique := s[:1]
+	for _, v := range s[1:] {
+		if v != unique[len(unique)-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
 type includedApp struct {
 	prefix    string
 	app       *App
@@ -164,6 +235,14 @@ type App struct {
 	// decoding cookies. If nil, gob is used.
 	CookieCodec *codec.Codec
 
+	// SessionStore is the backend used for storing the sessions
+	// returned by Context.Session. If nil, sessions are stored
+	// in an encrypted cookie using a CookieSessionStore.
+	SessionStore SessionStore
+	// SessionOptions indicates the options used for sessions.
+	// If nil, sessions never expire.
+	SessionOptions *SessionOptions
+
 	// Hasher is the hash function used to sign values. If nil,
 	// it defaults to HMAC-SHA1.
 	Hasher cryptoutil.Hasher
@@ -178,6 +257,8 @@ type App struct {
 	values map[string]interface{}
 
 	handlers           []*handlerInfo
+	router             *router
+	routerMutex        sync.RWMutex
 	trustXHeaders      bool
 	appendSlash        bool
 	errorHandler       ErrorHandler
@@ -198,6 +279,9 @@ type App struct {
 	o                  *Orm
 	store              *blobstore.Blobstore
 	prepared           bool
+	httpServers        []*http.Server
+	shuttingDown       bool
+	background         backgroundGroup
 
 	// Used for included apps
 	included  []*includedApp
@@ -216,34 +300,93 @@ func (app *App) HandleNamed(pattern string, handler Handler, name string) {
 	app.HandleOptions(pattern, handler, &HandlerOptions{Name: name})
 }
 
+// HandleGet is a shorthand for HandleOptions, passing an Options instance
+// which only accepts GET (and, implicitly, HEAD) requests.
+func (app *App) HandleGet(pattern string, handler Handler) {
+	app.HandleOptions(pattern, handler, &HandlerOptions{Methods: []string{"GET"}})
+}
+
+// HandlePost is a shorthand for HandleOptions, passing an Options instance
+// which only accepts POST requests.
+func (app *App) HandlePost(pattern string, handler Handler) {
+	app.HandleOptions(pattern, handler, &HandlerOptions{Methods: []string{"POST"}})
+}
+
+// HandlePut is a shorthand for HandleOptions, passing an Options instance
+// which only accepts PUT requests.
+func (app *App) HandlePut(pattern string, handler Handler) {
+	app.HandleOptions(pattern, handler, &HandlerOptions{Methods: []string{"PUT"}})
+}
+
+// HandleDelete is a shorthand for HandleOptions, passing an Options instance
+// which only accepts DELETE requests.
+func (app *App) HandleDelete(pattern string, handler Handler) {
+	app.HandleOptions(pattern, handler, &HandlerOptions{Methods: []string{"DELETE"}})
+}
+
 // HandleOptions adds a new handler to the App. If the Options include a
 // non-empty name, it can be be reversed using Context.Reverse or
 // the "reverse" template function. To add a host-specific Handler,
-// set the Host field in Options to a non-empty string. Note that handler patterns
-// are tried in the same order that they were added to the App.
+// set the Host field in Options to a non-empty string. To restrict the
+// Handler to some HTTP methods, set the Methods field in Options. Note that
+// handler patterns are tried in the same order that they were added to the App.
+//
+// The pattern might be either a regular expression or a path with
+// named parameters, like /users/{id:int}/{name}. Patterns with
+// parameters must match the whole path, unless they start with ^ or
+// end with $, which are then used as anchors like in a regular
+// expression (e.g. ^/users/{id:int}/ matches any path starting with
+// /users/12/). The rest of the text outside the parameters is matched
+// literally. A pattern is only considered to have parameters when all
+// its braces enclose a parameter, so regular expressions with braces
+// (e.g. ^/archive/(\d{4})/$) still work. Each parameter can be retrieved
+// using Context.ParamValue or Context.IndexValue and has a type,
+// which defaults to str. The supported types are:
+//
+//  str: any non-empty string without slashes
+//  int: one or more digits
+//  slug: one or more letters, digits, underscores or dashes
+//  path: any non-empty string, including slashes
+//  *: any string, including slashes and the empty string
+//
+// Any other type is interpreted as a regular expression
+// (e.g. {id:[a-f0-9]{24}}).
 func (app *App) HandleOptions(pattern string, handler Handler, opts *HandlerOptions) {
 	if handler == nil {
 		panic(fmt.Errorf("handler for pattern %q can't be nil", pattern))
 	}
-	re := regexp.MustCompile(pattern)
+	re, tmpl, err := compilePattern(pattern)
+	if err != nil {
+		panic(fmt.Errorf("invalid pattern %q: %s", pattern, err))
+	}
 	var host string
 	var name string
+	var methods []string
 	if opts != nil {
 		host = opts.Host
 		name = opts.Name
+		for _, v := range opts.Methods {
+			methods = append(methods, strings.ToUpper(v))
+		}
 	}
 	info := &handlerInfo{
 		host:    host,
 		name:    name,
+		methods: methods,
+		prefix:  anchoredPrefix(re),
 		re:      re,
 		rc:      newRegexpCache(re),
+		tmpl:    tmpl,
 		handler: handler,
 	}
 	if p := literalRegexp(re); p != "" {
 		info.path = p
 		info.pathMatch = []int{0, len(p)}
 	}
+	app.routerMutex.Lock()
 	app.handlers = append(app.handlers, info)
+	app.router = nil
+	app.routerMutex.Unlock()
 }
 
 // AddContextProcessor adds context processor to the App.
@@ -823,22 +966,59 @@ func (app *App) ListenAndServe() error {
 	if err := app.checkPort(); err != nil {
 		return err
 	}
+	if err := app.checkTLS(); err != nil {
+		return err
+	}
+	servers, err := app.servers()
+	if err != nil {
+		return err
+	}
 	signal.Emit(WILL_LISTEN, app)
 	app.started = time.Now().UTC()
 	if app.Logger != nil && os.Getenv("GONDOLA_DEV_SERVER") == "" {
+		ports := fmt.Sprintf("port %d", app.cfg.Port)
+		if app.TLSEnabled() {
+			ports = fmt.Sprintf("port %d (HTTPS on port %d)", app.cfg.Port, app.cfg.TLSPort)
+		}
 		if app.address != "" {
-			app.Logger.Infof("Listening on %s, port %d", app.address, app.cfg.Port)
+			app.Logger.Infof("Listening on %s, %s", app.address, ports)
 		} else {
-			app.Logger.Infof("Listening on port %d", app.cfg.Port)
+			app.Logger.Infof("Listening on %s", ports)
 		}
 	}
-	var err error
+	app.mu.Lock()
+	if app.shuttingDown {
+		// Shutdown was called while preparing the App
+		app.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	app.httpServers = servers
+	app.mu.Unlock()
 	time.AfterFunc(500*time.Millisecond, func() {
 		if err == nil {
 			signal.Emit(DID_LISTEN, app)
 		}
 	})
-	err = http.ListenAndServe(app.address+":"+strconv.Itoa(app.cfg.Port), app)
+	errs := make(chan error, len(servers))
+	for _, v := range servers {
+		go func(s *http.Server) {
+			if s.TLSConfig != nil {
+				errs <- s.ListenAndServeTLS("", "")
+			} else {
+				errs <- s.ListenAndServe()
+			}
+		}(v)
+	}
+	for range servers {
+		// http.ErrServerClosed is returned when the servers
+		// are stopped by App.Shutdown
+		if serr := <-errs; serr != nil && serr != http.ErrServerClosed && err == nil {
+			err = serr
+			for _, v := range servers {
+				v.Close()
+			}
+		}
+	}
 	return err
 }
 
@@ -1085,6 +1265,7 @@ func (app *App) errorPage(ctx *Context, elapsed time.Duration, skip int, stackSk
 // ServeHTTP is called from the net/http system. You shouldn't need
 // to call this function
 func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	app.setHSTSHeader(w, r)
 	ctx := app.newContext(w, r)
 	if profile.On && shouldProfile(ctx) {
 		profile.Begin()
@@ -1106,13 +1287,18 @@ func (app *App) serveOrNotFound(path string, ctx *Context) {
 }
 
 func (app *App) serve(path string, ctx *Context) bool {
-	if handler := app.matchHandler(path, ctx); handler != nil {
+	handler, allowed := app.matchHandler(path, ctx)
+	if handler != nil {
 		handler(ctx)
 		return true
 	}
+	if len(allowed) > 0 {
+		app.methodNotAllowed(ctx, allowed)
+		return true
+	}
 
 	if app.appendSlash && (ctx.R.Method == "GET" || ctx.R.Method == "HEAD") && !strings.HasSuffix(path, "/") {
-		if app.matchHandler(path+"/", ctx) != nil {
+		if h, _ := app.matchHandler(path+"/", ctx); h != nil {
 			prevPath := ctx.R.URL.Path
 			ctx.R.URL.Path += "/"
 			ctx.Redirect(ctx.R.URL.String(), true)
@@ -1123,28 +1309,69 @@ func (app *App) serve(path string, ctx *Context) bool {
 	return false
 }
 
-func (app *App) matchHandler(path string, ctx *Context) Handler {
-	for _, v := range app.handlers {
+// methodNotAllowed responds to a request which matched at least
+// one handler pattern, but none of the handlers accepted its
+// method. OPTIONS requests are answered with the allowed methods,
+// while any other method receives a 405 error.
+func (app *App) methodNotAllowed(ctx *Context, allowed []string) {
+	ctx.Header().Set("Allow", strings.Join(allowed, ", "))
+	if ctx.R.Method == "OPTIONS" {
+		ctx.Header().Set("Content-Length", "0")
+		ctx.WriteHeader(http.StatusOK)
+		return
+	}
+	app.handleHTTPError(ctx, "Method Not Allowed", http.StatusMethodNotAllowed)
+}
+
+// matchHandler returns the first handler which matches the given
+// path and the request method. If no handler matches but there
+// are handlers which match the path using other methods, the
+// allowed methods for the path are returned.
+func (app *App) matchHandler(path string, ctx *Context) (Handler, []string) {
+	var allowed []string
+	var buf [16]int
+	method := ctx.R.Method
+	r := app.routes()
+	for _, idx := range r.candidates(path, buf[:0]) {
+		v := r.handlers[idx]
 		if v.host != "" && v.host != ctx.R.Host {
 			continue
 		}
-		if v.path != "" {
-			if v.path == path {
-				ctx.reProvider.reset(v.re, path, v.pathMatch)
-				ctx.handlerName = v.name
-				return v.handler
-			}
-		} else {
-			// Use FindStringSubmatchIndex, since this way we can
-			// reuse the slices used to store context arguments
-			if m := v.re.FindStringSubmatchIndex(path); m != nil {
-				ctx.reProvider.reset(v.re, path, m)
-				ctx.handlerName = v.name
-				return v.handler
-			}
+		m := v.match(path)
+		if m == nil {
+			continue
 		}
+		if !v.acceptsMethod(method) {
+			allowed = v.allowedMethods(allowed)
+			continue
+		}
+		ctx.reProvider.reset(v.re, path, m)
+		ctx.handlerName = v.name
+		return v.handler, nil
+	}
+	if len(allowed) > 0 {
+		allowed = append(allowed, "OPTIONS")
+		sort.Strings(allowed)
+		allowed = uniqueStrings(allowed)
+	}
+	return nil, allowed
+}
+
+// routes returns the router for the current handlers,
+// building it if required.
+func (app *App) routes() *router {
+	app.routerMutex.RLock()
+	r := app.router
+	app.routerMutex.RUnlock()
+	if r == nil {
+		app.routerMutex.Lock()
+		if app.router == nil {
+			app.router = newRouter(app.handlers)
+		}
+		r = app.router
+		app.routerMutex.Unlock()
 	}
-	return nil
+	return r
 }
 
 // newContext returns a new context, using the
@@ -1394,6 +1621,8 @@ func (app *App) Prepare() error {
 		child.cfg = app.cfg
 		child.CookieOptions = app.CookieOptions
 		child.CookieCodec = app.CookieCodec
+		child.SessionStore = app.SessionStore
+		child.SessionOptions = app.SessionOptions
 		child.Hasher = app.Hasher
 		child.Cipherer = app.Cipherer
 		child.languageHandler = app.languageHandler
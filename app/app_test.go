@@ -1,9 +1,14 @@
 package app_test
 
 import (
+	"context"
 	"fmt"
 	"gnd.la/app"
 	"gnd.la/app/tester"
+	"gnd.la/signal"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -48,3 +53,109 @@ func TestGoWait(t *testing.T) {
 	tt.Get("/wait", nil).Expect("43")
 	tt.Get("/nowait", nil).Expect("42")
 }
+
+func TestMethods(t *testing.T) {
+	a := app.New()
+	a.HandleGet("^/items/$", func(ctx *app.Context) {
+		ctx.WriteString("list")
+	})
+	a.HandlePost("^/items/$", func(ctx *app.Context) {
+		ctx.WriteString("create")
+	})
+	a.HandleOptions("^/items/(\\d+)$", func(ctx *app.Context) {
+		ctx.WriteString("delete " + ctx.IndexValue(0))
+	}, &app.HandlerOptions{Methods: []string{"delete"}})
+	tt := tester.New(t, a)
+	tt.Get("/items/", nil).Expect("list")
+	tt.Post("/items/", nil).Expect("create")
+	tt.Request("HEAD", "/items/", nil).Expect(200)
+	tt.Request("PUT", "/items/", nil).Expect(405).ExpectHeader("Allow", "GET, HEAD, OPTIONS, POST")
+	tt.Request("OPTIONS", "/items/", nil).Expect(200).ExpectHeader("Allow", "GET, HEAD, OPTIONS, POST")
+	tt.Request("DELETE", "/items/42", nil).Expect("delete 42")
+	tt.Get("/items/42", nil).Expect(405).ExpectHeader("Allow", "DELETE, OPTIONS")
+	tt.Get("/other/", nil).Expect(404)
+}
+
+func TestConditional(t *testing.T) {
+	a := app.New()
+	a.Handle("^/$", app.Conditional(func(ctx *app.Context) {
+		ctx.WriteString("Hello world")
+	}))
+	a.Handle("^/missing$", app.Conditional(func(ctx *app.Context) {
+		ctx.NotFound("")
+	}))
+	get := func(path string, etag string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+	w := get("/", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != "Hello world" || etag == "" {
+		t.Fatalf("expecting 200 with body and ETag, got %d with %q and ETag %q", w.Code, w.Body.String(), etag)
+	}
+	if w = get("/", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expecting 304 without body, got %d with %q", w.Code, w.Body.String())
+	}
+	if w = get("/", `"other"`); w.Code != http.StatusOK {
+		t.Errorf("expecting 200 with a different ETag, got %d", w.Code)
+	}
+	if w = get("/missing", etag); w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" {
+		t.Errorf("expecting 404 without ETag, got %d with ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	a := app.New()
+	var finished int32
+	a.Handle("^/$", func(ctx *app.Context) {
+		ctx.Go(func(bg *app.Context) {
+			time.Sleep(200 * time.Millisecond)
+			atomic.StoreInt32(&finished, 1)
+		})
+		ctx.WriteString("ok")
+	})
+	var emitted []string
+	tok := signal.Listen(app.WILL_SHUTDOWN, func(name string) { emitted = append(emitted, name) })
+	defer signal.Stop(app.WILL_SHUTDOWN, tok)
+	tok2 := signal.Listen(app.DID_SHUTDOWN, func(name string) { emitted = append(emitted, name) })
+	defer signal.Stop(app.DID_SHUTDOWN, tok2)
+	tt := tester.New(t, a)
+	tt.Get("/", nil).Expect("ok")
+	c1, err := a.Cache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := a.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expecting DeadlineExceeded, got %v", err)
+	}
+	if c2, _ := a.Cache(); c2 != c1 {
+		t.Error("Shutdown released the shared Cache while background contexts were running")
+	}
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if atomic.LoadInt32(&finished) != 1 {
+		t.Error("Shutdown did not wait for background contexts")
+	}
+	expected := []string{app.WILL_SHUTDOWN, app.DID_SHUTDOWN, app.WILL_SHUTDOWN, app.DID_SHUTDOWN}
+	if fmt.Sprint(emitted) != fmt.Sprint(expected) {
+		t.Errorf("expecting signals %v, got %v", expected, emitted)
+	}
+}
+
+func TestShutdownBeforeListen(t *testing.T) {
+	a := app.New()
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ListenAndServe(); err != http.ErrServerClosed {
+		t.Errorf("expecting http.ErrServerClosed from ListenAndServe after Shutdown, got %v", err)
+	}
+}
@@ -71,3 +71,42 @@ func BenchmarkDirectNoLog(b *testing.B) {
 func BenchmarkDirectReNoLog(b *testing.B) {
 	benchmarkDirect(b, "article/7", true)
 }
+
+func testManyRoutesApp(pattern string) *App {
+	a := New()
+	a.Logger = nil
+	f := func(ctx *Context) {}
+	for ii := 0; ii < 400; ii++ {
+		a.Handle(fmt.Sprintf(pattern, ii), f)
+	}
+	return a
+}
+
+func benchmarkManyRoutes(b *testing.B, pattern string, path string) {
+	a := testManyRoutesApp(pattern)
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := a.newContext(nil, req)
+	if h, _ := a.matchHandler(req.URL.Path, ctx); h == nil {
+		b.Fatalf("no handler for %s", path)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for ii := 0; ii < b.N; ii++ {
+		a.matchHandler(req.URL.Path, ctx)
+	}
+}
+
+func BenchmarkManyRoutesLiteral(b *testing.B) {
+	benchmarkManyRoutes(b, "^/section%d/$", "/section399/")
+}
+
+func BenchmarkManyRoutesRegexp(b *testing.B) {
+	benchmarkManyRoutes(b, "^/section%d/(\\d+)/$", "/section399/42/")
+}
+
+func BenchmarkManyRoutesTemplate(b *testing.B) {
+	benchmarkManyRoutes(b, "/section%d/{id:int}/", "/section399/42/")
+}
@@ -0,0 +1,307 @@
+package app
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gnd.la/encoding/codec"
+	"gnd.la/form/input"
+	"gnd.la/i18n"
+	"gnd.la/util/stringutil"
+	"gnd.la/util/structs"
+)
+
+const (
+	// Not defined in net/http before Go 1.7
+	statusUnprocessableEntity = 422
+	// maxBindBodySize is the maximum size of the request bodies
+	// decoded by Bind, which matches the limit used by net/http
+	// when parsing url-encoded forms.
+	maxBindBodySize = 10 << 20
+)
+
+// BindError is returned by Context.Bind when the request was decoded
+// but some of its fields didn't pass validation. Its status code is 422
+// (Unprocessable Entity). When a DataHandler wrapped by JSONHandler returns
+// a BindError, the response is a JSON object with an "errors" key which
+// maps the invalid fields to their error messages.
+type BindError struct {
+	// Errors maps the field names, as they appear in the request
+	// (e.g. the JSON key), to their translated error messages.
+	Errors map[string]string `json:"errors"`
+}
+
+func (e *BindError) StatusCode() int {
+	return statusUnprocessableEntity
+}
+
+func (e *BindError) Error() string {
+	fields := make([]string, 0, len(e.Errors))
+	for k := range e.Errors {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	messages := make([]string, len(fields))
+	for ii, v := range fields {
+		messages[ii] = fmt.Sprintf("%s: %s", v, e.Errors[v])
+	}
+	return strings.Join(messages, ", ")
+}
+
+// bindRequestError is returned by Context.Bind when the request
+// body can't be decoded. Its status code is 400, unless the
+// Content-Type is not supported (415) or the body is too large (413).
+type bindRequestError struct {
+	code int
+	err  error
+}
+
+func (e *bindRequestError) StatusCode() int {
+	return e.code
+}
+
+func (e *bindRequestError) Error() string {
+	return e.err.Error()
+}
+
+// Bind decodes the request data into obj, which must be a pointer to
+// a struct, and then validates it. The decoder is chosen according
+// to the request Content-Type:
+//
+//  - application/json (or any type with the +json suffix) uses encoding/json.
+//  - application/xml, text/xml (or any type with the +xml suffix) uses encoding/xml.
+//  - application/x-msgpack and application/msgpack use the msgpack codec,
+//    which requires importing gnd.la/encoding/codec/msgpack.
+//  - application/x-www-form-urlencoded, multipart/form-data or no
+//    Content-Type at all parse the form values using the same field names
+//    as gnd.la/form (e.g. FirstName becomes first_name and Address.City
+//    becomes address.city).
+//
+// After decoding, every field is validated using its "form" struct tag, the
+// registered rules (see gnd.la/util/structs.RegisterRule) and its validation
+// function, if any (see gnd.la/util/structs.Validate), exactly like a form
+// does. Note that when binding from form values fields are required unless
+// they're tagged as optional, as in gnd.la/form, while fields decoded from
+// JSON, XML or msgpack are only required when they're tagged as required
+// and they're considered empty when they have their zero value.
+//
+// If any field doesn't pass validation, a *BindError is returned with
+// all the translated error messages. If the request can't be decoded, the
+// returned error implements Error, with a status code of 400, 415 (when
+// the Content-Type is not supported) or 413 (when a JSON, XML or msgpack
+// body is larger than 10MB, the same limit net/http uses for forms).
+func (c *Context) Bind(obj interface{}) error {
+	val := reflect.ValueOf(obj)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Bind requires a non-nil pointer to a struct, %T given", obj)
+	}
+	var mediaType string
+	if c.R != nil {
+		if ct := c.R.Header.Get("Content-Type"); ct != "" {
+			var err error
+			mediaType, _, err = mime.ParseMediaType(ct)
+			if err != nil {
+				return &bindRequestError{code: http.StatusBadRequest, err: err}
+			}
+		}
+	}
+	var keys []string
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		keys = []string{"json"}
+		body := c.bindBody()
+		if err := json.NewDecoder(body).Decode(obj); err != nil {
+			return body.error(err)
+		}
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		keys = []string{"xml"}
+		body := c.bindBody()
+		if err := xml.NewDecoder(body).Decode(obj); err != nil {
+			return body.error(err)
+		}
+	case mediaType == "application/x-msgpack" || mediaType == "application/msgpack":
+		keys = []string{"codec", "json"}
+		cod := codec.Get("msgpack")
+		if cod == nil {
+			return fmt.Errorf("can't bind %s, import gnd.la/encoding/codec/msgpack to enable msgpack support", mediaType)
+		}
+		body := c.bindBody()
+		data, err := ioutil.ReadAll(body)
+		if err != nil {
+			return body.error(err)
+		}
+		if err := cod.Decode(data, obj); err != nil {
+			return &bindRequestError{code: http.StatusBadRequest, err: err}
+		}
+	case mediaType == "" || mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		// Form values are parsed while validating
+	default:
+		return &bindRequestError{
+			code: http.StatusUnsupportedMediaType,
+			err:  fmt.Errorf("can't bind data with Content-Type %s", mediaType),
+		}
+	}
+	return c.bind(val.Elem(), keys)
+}
+
+// bindBody returns the request body limited to maxBindBodySize.
+func (c *Context) bindBody() *limitedBody {
+	return &limitedBody{r: http.MaxBytesReader(c, c.R.Body, maxBindBodySize)}
+}
+
+// limitedBody wraps a body limited by http.MaxBytesReader and
+// keeps track of the bytes read, so errors due to the limit
+// can be told apart from the rest.
+type limitedBody struct {
+	r    io.Reader
+	read int64
+	err  error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// error returns the bindRequestError for the given decoding error,
+// with a 413 status code if the body exceeded the limit.
+func (b *limitedBody) error(err error) error {
+	// http.MaxBytesReader only fails after reading
+	// exactly the number of bytes allowed.
+	if b.err != nil && b.read == maxBindBodySize {
+		return &bindRequestError{
+			code: http.StatusRequestEntityTooLarge,
+			err:  fmt.Errorf("request body is larger than %d bytes", maxBindBodySize),
+		}
+	}
+	return &bindRequestError{code: http.StatusBadRequest, err: err}
+}
+
+// bind validates the struct in val. If keys is empty, the values
+// are parsed from the form, otherwise keys are the struct tags used
+// to obtain the names of the fields in the request.
+func (c *Context) bind(val reflect.Value, keys []string) error {
+	s, err := structs.NewStruct(val.Type(), []string{"form"})
+	if err != nil {
+		return err
+	}
+	form := len(keys) == 0
+	errors := make(map[string]string)
+	for ii, qname := range s.QNames {
+		idx := s.Indexes[ii]
+		tag := s.Tags[ii]
+		parent, ok := bindParent(val, idx[:len(idx)-1], form)
+		if !ok {
+			// Nested struct not present in the request
+			continue
+		}
+		fieldVal := parent.Field(idx[len(idx)-1])
+		var name string
+		if form {
+			name = bindFormName(qname)
+			err = input.InputNamed(name, c.FormValue(name), fieldVal.Addr().Interface(), tag, true)
+		} else {
+			name = bindFieldName(val.Type(), idx, keys)
+			err = input.Check(name, fieldVal, tag, false)
+		}
+		if err == nil {
+			err = structs.ValidateRules(name, fieldVal, parent, tag)
+		}
+		if err == nil {
+			err = structs.Validate(val.Addr().Interface(), qname, c)
+		}
+		if err != nil {
+			errors[name] = i18n.TranslatedError(err, c).Error()
+		}
+	}
+	if len(errors) > 0 {
+		return &BindError{Errors: errors}
+	}
+	return nil
+}
+
+// bindFormName returns the name of the form value for the
+// field with the given qualified name, like gnd.la/form does.
+func bindFormName(qname string) string {
+	parts := strings.Split(qname, ".")
+	for ii, v := range parts {
+		parts[ii] = stringutil.CamelCaseToLower(v, "_")
+	}
+	return strings.Join(parts, ".")
+}
+
+// bindParent returns the struct which contains the field at the given
+// index. Nil pointers are allocated when alloc is true, otherwise
+// bindParent returns false when it finds one.
+func bindParent(val reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for _, idx := range index {
+		val = val.Field(idx)
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				if !alloc {
+					return val, false
+				}
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+	}
+	return val, true
+}
+
+// bindFieldName returns the name of the field at the given index
+// as it appears in the request, using the name in the first tag
+// found in keys. Like encoding/json and encoding/xml do, untagged
+// fields use their Go name and untagged embedded structs are inlined.
+func bindFieldName(typ reflect.Type, index []int, keys []string) string {
+	var names []string
+	for _, idx := range index {
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		field := typ.Field(idx)
+		typ = field.Type
+		var name string
+		for _, k := range keys {
+			if t := field.Tag.Get(k); t != "" {
+				name = strings.Split(t, ",")[0]
+				break
+			}
+		}
+		if name == "" {
+			if field.Anonymous {
+				continue
+			}
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ".")
+}
+
+// writeBindError writes the given BindError as JSON,
+// using its status code.
+func writeBindError(ctx *Context, err *BindError) {
+	data, jerr := json.Marshal(err)
+	if jerr != nil {
+		panic(jerr)
+	}
+	header := ctx.Header()
+	header.Set("Content-Type", "application/json")
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	ctx.WriteHeader(err.StatusCode())
+	ctx.Write(data)
+}
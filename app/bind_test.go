@@ -0,0 +1,101 @@
+package app_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gnd.la/app"
+)
+
+type bindAddress struct {
+	City string `json:"city" form:",required"`
+}
+
+type bindUser struct {
+	Name    string       `json:"name" form:",required,max_length=5"`
+	Email   string       `json:"email" form:",optional,email"`
+	Age     int          `json:"age" form:",optional,min=18"`
+	Address *bindAddress `json:"address"`
+}
+
+func (u *bindUser) ValidateName() error {
+	if u.Name == "root" {
+		return errors.New("name is reserved")
+	}
+	return nil
+}
+
+func TestBind(t *testing.T) {
+	a := app.New()
+	var user bindUser
+	a.Handle("^/$", app.JSONHandler(func(ctx *app.Context) (interface{}, error) {
+		user = bindUser{}
+		if err := ctx.Bind(&user); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}))
+	post := func(contentType string, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+	errs := func(w *httptest.ResponseRecorder) map[string]string {
+		if w.Code != 422 {
+			t.Fatalf("expecting 422, got %d with %q", w.Code, w.Body.String())
+		}
+		var res struct {
+			Errors map[string]string `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res.Errors
+	}
+	w := post("application/json", `{"name": "foo", "email": "foo@example.com", "age": 20, "address": {"city": "Madrid"}}`)
+	if w.Code != http.StatusOK || user.Name != "foo" || user.Age != 20 || user.Address == nil || user.Address.City != "Madrid" {
+		t.Errorf("expecting 200 with bound user, got %d with %q (%+v)", w.Code, w.Body.String(), user)
+	}
+	e := errs(post("application/json; charset=utf-8", `{"name": "foobar", "email": "foo", "age": 10, "address": {}}`))
+	for _, v := range []string{"name", "email", "age", "address.city"} {
+		if e[v] == "" {
+			t.Errorf("expecting an error for %s, got %v", v, e)
+		}
+	}
+	if e = errs(post("application/json", `{"name": "root", "age": 18}`)); e["name"] != "name is reserved" || len(e) != 1 {
+		t.Errorf("expecting error from ValidateName, got %v", e)
+	}
+	w = post("application/xml", `<bindUser><Name>foo</Name><Age>30</Age></bindUser>`)
+	if w.Code != http.StatusOK || user.Name != "foo" || user.Age != 30 {
+		t.Errorf("expecting 200 with bound user from XML, got %d with %q", w.Code, w.Body.String())
+	}
+	w = post("application/x-www-form-urlencoded", "name=foo&age=21&address.city=Paris")
+	if w.Code != http.StatusOK || user.Name != "foo" || user.Age != 21 || user.Address == nil || user.Address.City != "Paris" {
+		t.Errorf("expecting 200 with bound user from form, got %d with %q (%+v)", w.Code, w.Body.String(), user)
+	}
+	if e = errs(post("application/x-www-form-urlencoded", "age=twenty")); e["name"] == "" || e["age"] == "" || e["address.city"] == "" {
+		t.Errorf("expecting errors for name, age and address.city, got %v", e)
+	}
+	if w = post("application/json", `{"name": `); w.Code != http.StatusBadRequest {
+		t.Errorf("expecting 400 for malformed JSON, got %d", w.Code)
+	}
+	if w = post("text/csv", "name\nfoo"); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expecting 415 for unsupported Content-Type, got %d", w.Code)
+	}
+	padding := strings.Repeat(" ", 10<<20)
+	large := map[string]string{
+		"application/json": `{"name": "foo"` + padding + `}`,
+		"application/xml":  `<bindUser><Name>foo</Name>` + padding + `</bindUser>`,
+	}
+	for k, v := range large {
+		if w = post(k, v); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expecting 413 for a large %s body, got %d", k, w.Code)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"gnd.la/internal/httpserve"
+)
+
+// conditionalWriter buffers a response, so Conditional
+// can check the request preconditions before sending it.
+type conditionalWriter struct {
+	http.ResponseWriter
+	buf        bytes.Buffer
+	statusCode int
+}
+
+func (w *conditionalWriter) WriteHeader(code int) {
+	if w.statusCode == 0 {
+		w.statusCode = code
+	}
+}
+
+func (w *conditionalWriter) Write(data []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.buf.Write(data)
+}
+
+// Conditional returns a new Handler which buffers the response
+// from the given Handler and, for GET and HEAD requests with a 200
+// response code, adds an ETag header computed from the response body
+// (unless the handler sets its own ETag). If the request If-None-Match
+// or If-Modified-Since headers match the ETag or Last-Modified headers
+// in the response, a 304 response is sent without any body. Note that,
+// since the response is buffered, Conditional should not be used with
+// handlers which stream their responses.
+func Conditional(handler Handler) Handler {
+	return func(ctx *Context) {
+		if m := ctx.R.Method; m != "GET" && m != "HEAD" {
+			handler(ctx)
+			return
+		}
+		rw := ctx.ResponseWriter
+		w := &conditionalWriter{ResponseWriter: rw}
+		ctx.ResponseWriter = w
+		defer func() {
+			ctx.ResponseWriter = rw
+		}()
+		handler(ctx)
+		ctx.ResponseWriter = rw
+		if w.statusCode == 0 {
+			return
+		}
+		body := w.buf.Bytes()
+		if w.statusCode == http.StatusOK {
+			header := rw.Header()
+			httpserve.SetValidators(header, httpserve.BodyETag(body), time.Time{})
+			if httpserve.NotModified(ctx.R, header) {
+				httpserve.WriteNotModified(rw)
+				return
+			}
+		}
+		rw.WriteHeader(w.statusCode)
+		rw.Write(body)
+	}
+}
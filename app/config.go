@@ -19,11 +19,25 @@ type Config struct {
 	// translating strings when there's no LanguageHandler
 	// or when it returns an empty string.
 	Language string `help:"Set the default language for translating strings"`
-	// Port indicates the port to listen on.
-	Port      int         `default:"8888" help:"Port to listen on"`
-	Database  *config.URL `help:"Default database to use, used by Context.Orm()"`
-	Cache     *config.URL `help:"Default cache, returned by Context.Cache()"`
-	Blobstore *config.URL `help:"Default blobstore, returned by Context.Blobstore()"`
+	// Port indicates the port to listen on. When TLS is enabled,
+	// requests received on this port are redirected to TLSPort.
+	Port int `default:"8888" help:"Port to listen on"`
+	// TLSPort indicates the port to listen on for HTTPS requests. It's
+	// only used when both TLSCert and TLSKey are set.
+	TLSPort int `default:"8443" help:"Port to listen on for HTTPS, when TLSCert and TLSKey are set"`
+	// TLSCert is the path to the PEM encoded TLS certificate. If both
+	// TLSCert and TLSKey are set, the app serves HTTPS on TLSPort.
+	// The certificate and key are reloaded when their files change.
+	TLSCert string `help:"Path to the TLS certificate, enables HTTPS when used with TLSKey"`
+	// TLSKey is the path to the PEM encoded private key for TLSCert.
+	TLSKey string `help:"Path to the TLS private key"`
+	// HSTSMaxAge is the max-age, in seconds, sent in the
+	// Strict-Transport-Security header for HTTPS responses. If zero,
+	// the header is not sent.
+	HSTSMaxAge int         `help:"Max age in seconds for the Strict-Transport-Security header, 0 disables it"`
+	Database   *config.URL `help:"Default database to use, used by Context.Orm()"`
+	Cache      *config.URL `help:"Default cache, returned by Context.Cache()"`
+	Blobstore  *config.URL `help:"Default blobstore, returned by Context.Blobstore()"`
 	// Secret indicates the secret associated with the app,
 	// which is used for signed cookies. It should be a
 	// random string with at least 32 characters.
@@ -37,7 +51,8 @@ type Config struct {
 
 var (
 	defaultConfig = Config{
-		Port: 8888,
+		Port:    8888,
+		TLSPort: 8443,
 	}
 )
 
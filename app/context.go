@@ -44,6 +44,7 @@ type Context struct {
 	started         time.Time
 	cookies         *cookies.Cookies
 	user            User
+	session         *Session
 	translations    *table.Table
 	hasTranslations bool
 	background      bool
@@ -58,6 +59,7 @@ func (c *Context) reset() {
 	c.started = time.Now()
 	c.cookies = nil
 	c.user = nil
+	c.session = nil
 	c.translations = nil
 	c.hasTranslations = false
 	c.values = nil
@@ -449,11 +451,12 @@ func (c *Context) IsXHR() bool {
 	return c.GetHeader("X-Requested-With") == "XMLHttpRequest"
 }
 
-// Close closes any resources opened by the context.
-// It's automatically called by the App, so you
-// don't need to call it manually
+// Close closes any resources opened by the context
+// and saves its Session, if any. It's automatically
+// called by the App, so you don't need to call it
+// manually
 func (c *Context) Close() {
-	// currently a no-op
+	c.saveSession()
 }
 
 // BackgroundContext returns a copy of the given Context
@@ -518,12 +521,17 @@ func (c *Context) Go(f func(*Context)) {
 		c.wg = new(sync.WaitGroup)
 	}
 	c.wg.Add(1)
+	// Track the goroutine in the top-level app too, so
+	// App.Shutdown can wait for it.
+	root := c.app.root()
+	root.background.add()
 	bg := c.backgroundContext()
 	var id int
 	if profile.On {
 		id = profile.ID()
 	}
 	go func() {
+		defer root.background.finish()
 		if profile.On {
 			profile.Begin()
 			defer profile.End(id)
@@ -579,6 +587,7 @@ func (c *Context) WriteHeader(code int) {
 		code = -c.statusCode
 	}
 	c.statusCode = code
+	c.saveSession()
 	if profile.On && profile.Profiling() {
 		header := profileHeader(c)
 		c.Header().Set(profile.HeaderName, header)
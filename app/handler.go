@@ -19,6 +19,14 @@ type HandlerOptions struct {
 	// Host specifies the host the Handler will match. If non-empty,
 	// only requests to this specific host will match the Handler.
 	Host string
+	// Methods specifies the HTTP methods the Handler will match. If
+	// empty, the Handler matches any method. Otherwise, requests which
+	// match the Handler's pattern but use a different method are passed
+	// to the next Handler and, if none of them accepts the method, the
+	// App responds with a 405 Method Not Allowed. Note that HEAD is
+	// implicitly accepted by Handlers which accept GET, while OPTIONS
+	// requests are automatically answered when no Handler accepts them.
+	Methods []string
 }
 
 type HandlerInfo struct {
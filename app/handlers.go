@@ -80,11 +80,17 @@ type DataHandler func(*Context) (interface{}, error)
 
 // JSONHandler returns a Handler which executes the given DataHandler
 // to obtain the data and, if it succeeds, serializes the data using
-// JSON and returns it back to the client.
+// JSON and returns it back to the client. If the DataHandler returns
+// a *BindError (see Context.Bind), it's sent to the client as JSON with
+// a 422 status code, including the error message for each invalid field.
 func JSONHandler(dataHandler DataHandler) Handler {
 	return func(ctx *Context) {
 		data, err := dataHandler(ctx)
 		if err != nil {
+			if berr, ok := err.(*BindError); ok {
+				writeBindError(ctx, berr)
+				return
+			}
 			panic(err)
 		}
 		if _, err := ctx.WriteJSON(data); err != nil {
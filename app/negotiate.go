@@ -0,0 +1,197 @@
+package app
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gnd.la/app/serialize"
+	"gnd.la/encoding/codec"
+)
+
+const (
+	// FormatParameter is the name of the query parameter which
+	// might be used to override the Accept header in handlers
+	// returned by NegotiatedHandler (e.g. ?format=json).
+	FormatParameter = "format"
+	htmlFormat      = "html"
+	htmlMediaType   = "text/html"
+	xhtmlMediaType  = "application/xhtml+xml"
+)
+
+type acceptedType struct {
+	mediaType string
+	q         float64
+}
+
+type acceptedTypes []*acceptedType
+
+func (a acceptedTypes) Len() int           { return len(a) }
+func (a acceptedTypes) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a acceptedTypes) Less(i, j int) bool { return a[i].q > a[j].q }
+
+// parseAccept returns the media ranges in the given Accept
+// header, sorted by their quality. Media ranges with q=0
+// are not acceptable, so they're omitted.
+func parseAccept(header string) []string {
+	var accepted acceptedTypes
+	for _, v := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qs, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, &acceptedType{mediaType: mediaType, q: q})
+		}
+	}
+	sort.Stable(accepted)
+	types := make([]string, len(accepted))
+	for ii, v := range accepted {
+		types[ii] = v.mediaType
+	}
+	return types
+}
+
+// negotiatedFormats returns the formats available for content negotiation,
+// in order of preference: HTML (if there's a template), JSON and then
+// the rest of the codecs with a MediaType, sorted by name.
+func negotiatedFormats(html bool) []string {
+	var formats []string
+	if html {
+		formats = append(formats, htmlFormat)
+	}
+	if c := codec.Get("json"); c != nil && c.MediaType != "" {
+		formats = append(formats, "json")
+	}
+	for _, v := range codec.Names() {
+		if v != "json" && codec.Get(v).MediaType != "" {
+			formats = append(formats, v)
+		}
+	}
+	return formats
+}
+
+func formatMediaType(format string) string {
+	if format == htmlFormat {
+		return htmlMediaType
+	}
+	return codec.Get(format).MediaType
+}
+
+func matchesMediaRange(mediaRange string, format string) bool {
+	if mediaRange == "*/*" {
+		return true
+	}
+	mediaType := formatMediaType(format)
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1])
+	}
+	return mediaRange == mediaType || (format == htmlFormat && mediaRange == xhtmlMediaType)
+}
+
+// negotiateFormats returns the acceptable formats for the request,
+// in order of preference. If none of the available ones is acceptable,
+// it returns an empty slice.
+func negotiateFormats(ctx *Context, html bool) []string {
+	formats := negotiatedFormats(html)
+	if ctx.R == nil {
+		return formats
+	}
+	if f := ctx.R.URL.Query().Get(FormatParameter); f != "" {
+		for _, v := range formats {
+			if v == f {
+				return []string{v}
+			}
+		}
+		return nil
+	}
+	accept := ctx.R.Header.Get("Accept")
+	if accept == "" {
+		return formats
+	}
+	var acceptable []string
+	added := make(map[string]bool)
+	for _, mediaRange := range parseAccept(accept) {
+		for _, v := range formats {
+			if !added[v] && matchesMediaRange(mediaRange, v) {
+				acceptable = append(acceptable, v)
+				added[v] = true
+			}
+		}
+	}
+	return acceptable
+}
+
+// NegotiatedHandler returns a Handler which executes the given DataHandler
+// and sends the obtained data in the format requested by the client, which
+// is determined by the Accept header. The available formats are HTML (using
+// the given template, which might be empty to disable HTML responses), JSON
+// and any other codec registered in gnd.la/encoding/codec with a MediaType,
+// which include XML, CSV and msgpack (which requires importing
+// gnd.la/encoding/codec/msgpack). Clients might also request a format by
+// its codec name (or "html") using the FormatParameter in the query
+// string, overriding the Accept header (e.g. /articles/?format=csv).
+//
+// When the client accepts any format, HTML is preferred, followed by JSON.
+// If the codec for the preferred format doesn't support the type of the
+// data (see gnd.la/encoding/codec.IsUnsupportedType), e.g. CSV only
+// supports structs and slices of structs, the next acceptable format is
+// used. Any other encoding error causes a panic. If none of the available
+// formats is acceptable or supports the data, the response is a 406 (Not
+// Acceptable) error. Responses always include the
+// Vary: Accept header and, like JSONHandler, a *BindError returned by the
+// DataHandler is sent as JSON with a 422 status code when the format is
+// not HTML.
+func NegotiatedHandler(dataHandler DataHandler, template string) Handler {
+	return func(ctx *Context) {
+		ctx.Header().Add("Vary", "Accept")
+		formats := negotiateFormats(ctx, template != "")
+		if len(formats) == 0 {
+			ctx.Error(http.StatusNotAcceptable)
+			return
+		}
+		data, err := dataHandler(ctx)
+		if err != nil {
+			if berr, ok := err.(*BindError); ok && formats[0] != htmlFormat {
+				writeBindError(ctx, berr)
+				return
+			}
+			panic(err)
+		}
+		for _, format := range formats {
+			if format == htmlFormat {
+				ctx.MustExecute(template, data)
+				return
+			}
+			if _, ok := data.(serialize.JSONWriter); ok && format == "json" {
+				if _, err := ctx.WriteJSON(data); err != nil {
+					panic(err)
+				}
+				return
+			}
+			c := codec.Get(format)
+			b, err := c.Encode(data)
+			if err != nil {
+				if codec.IsUnsupportedType(err) {
+					// Try the next acceptable format
+					continue
+				}
+				panic(err)
+			}
+			header := ctx.Header()
+			header.Set("Content-Type", c.MediaType)
+			header.Set("Content-Length", strconv.Itoa(len(b)))
+			ctx.Write(b)
+			return
+		}
+		ctx.Error(http.StatusNotAcceptable)
+	}
+}
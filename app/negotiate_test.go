@@ -0,0 +1,126 @@
+package app
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var negotiateTests = []struct {
+	accept   string
+	format   string
+	html     bool
+	expected string
+}{
+	{"", "", true, "html"},
+	{"", "", false, "json"},
+	{"*/*", "", true, "html"},
+	{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "", true, "html"},
+	{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "", false, "xml"},
+	{"application/json", "", true, "json"},
+	{"application/xml;q=0.5, application/json;q=0.4", "", true, "xml"},
+	{"application/*", "", true, "json"},
+	{"text/*", "", false, "csv"},
+	{"text/csv", "", true, "csv"},
+	{"text/html;q=0, application/json", "", true, "json"},
+	{"image/png", "", true, ""},
+	{"text/html", "", false, ""},
+	{"text/html", "json", true, "json"},
+	{"", "csv", true, "csv"},
+	{"", "html", false, ""},
+	{"", "gob", true, ""},
+	{"", "invalid", true, ""},
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	for _, v := range negotiateTests {
+		u := "/"
+		if v.format != "" {
+			u += "?" + FormatParameter + "=" + v.format
+		}
+		r, _ := http.NewRequest("GET", u, nil)
+		if v.accept != "" {
+			r.Header.Set("Accept", v.accept)
+		}
+		var f string
+		if formats := negotiateFormats(&Context{R: r}, v.html); len(formats) > 0 {
+			f = formats[0]
+		}
+		if f != v.expected {
+			t.Errorf("expecting format %q for Accept %q, format %q and html %v, got %q", v.expected, v.accept, v.format, v.html, f)
+		}
+	}
+}
+
+type negotiatedItem struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestNegotiatedHandler(t *testing.T) {
+	a := New()
+	a.Handle("^/$", NegotiatedHandler(func(ctx *Context) (interface{}, error) {
+		return []*negotiatedItem{{1, "foo"}, {2, "bar"}}, nil
+	}, ""))
+	get := func(path string, accept string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", path, nil)
+		r.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, r)
+		return w
+	}
+	var tests = []struct {
+		path        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"/", "application/json", "application/json", `[{"id":1,"name":"foo"},{"id":2,"name":"bar"}]`},
+		{"/", "text/csv", "text/csv", "id,name\n1,foo\n2,bar\n"},
+		{"/?format=csv", "application/json", "text/csv", "id,name\n1,foo\n2,bar\n"},
+		{"/", "application/xml", "application/xml", "<negotiatedItem><Id>1</Id><Name>foo</Name></negotiatedItem><negotiatedItem><Id>2</Id><Name>bar</Name></negotiatedItem>"},
+	}
+	for _, v := range tests {
+		w := get(v.path, v.accept)
+		if w.Code != http.StatusOK {
+			t.Errorf("expecting 200 for %s with Accept %s, got %d", v.path, v.accept, w.Code)
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); ct != v.contentType {
+			t.Errorf("expecting Content-Type %s for %s with Accept %s, got %s", v.contentType, v.path, v.accept, ct)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("expecting Vary: Accept, got %q", vary)
+		}
+		if body := w.Body.String(); body != v.body {
+			t.Errorf("expecting body %q for %s with Accept %s, got %q", v.body, v.path, v.accept, body)
+		}
+	}
+	w := get("/", "text/html")
+	if w.Code != http.StatusNotAcceptable || w.Header().Get("Vary") != "Accept" {
+		t.Errorf("expecting 406 with Vary: Accept, got %d with %q", w.Code, w.Header().Get("Vary"))
+	}
+	if strings.Contains(w.Body.String(), "foo") {
+		t.Errorf("406 response includes the data: %q", w.Body.String())
+	}
+	// CSV can't encode a map, so the next acceptable format is used
+	a.Handle("^/map/$", NegotiatedHandler(func(ctx *Context) (interface{}, error) {
+		return map[string]int{"foo": 1}, nil
+	}, ""))
+	w = get("/map/", "text/csv, application/json;q=0.5")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" || w.Body.String() != `{"foo":1}` {
+		t.Errorf("expecting 200 with JSON, got %d with %q (%s)", w.Code, w.Body.String(), w.Header().Get("Content-Type"))
+	}
+	if w = get("/map/?format=csv", ""); w.Code != http.StatusNotAcceptable {
+		t.Errorf("expecting 406 when the data can't be encoded as CSV, got %d", w.Code)
+	}
+	// Other encoding errors are not retried with another format
+	a.Handle("^/nan/$", NegotiatedHandler(func(ctx *Context) (interface{}, error) {
+		return map[string]float64{"foo": math.NaN()}, nil
+	}, ""))
+	if w = get("/nan/", "application/json, application/xml"); w.Code != http.StatusInternalServerError {
+		t.Errorf("expecting 500 when the data can't be encoded as JSON, got %d with %q", w.Code, w.Body.String())
+	}
+}
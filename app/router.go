@@ -0,0 +1,340 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// paramType represents a type which might be used in
+// pattern parameters (e.g. /users/{id:int}).
+type paramType struct {
+	// re is the regular expression used when the
+	// pattern is converted to a regexp.
+	re string
+	// accepts returns true iff the given byte can be
+	// part of a parameter of this type.
+	accepts func(byte) bool
+	// empty indicates if the parameter might match
+	// an empty string.
+	empty bool
+}
+
+var (
+	patternParamRe = regexp.MustCompile(`^\{[A-Za-z_][A-Za-z0-9_]*(?::.+)?\}$`)
+	strParam       = &paramType{re: `[^/]+`, accepts: func(b byte) bool { return b != '/' }}
+	paramTypes     = map[string]*paramType{
+		"":    strParam,
+		"str": strParam,
+		"int": &paramType{re: `\d+`, accepts: func(b byte) bool { return b >= '0' && b <= '9' }},
+		"slug": &paramType{re: `[\w\-]+`, accepts: func(b byte) bool {
+			return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' || b == '-'
+		}},
+		"path": &paramType{re: `.+`, accepts: func(b byte) bool { return b != '\n' }},
+		"*":    &paramType{re: `.*`, accepts: func(b byte) bool { return b != '\n' }, empty: true},
+	}
+)
+
+type templatePart struct {
+	literal string
+	param   *paramType
+}
+
+// routeTemplate matches paths against patterns with typed parameters
+// without using regular expressions. It returns the same submatch
+// indexes that the equivalent regexp would return, so the arguments
+// can be retrieved by the regexpProvider.
+type routeTemplate struct {
+	parts  []templatePart
+	groups int
+}
+
+func (t *routeTemplate) match(path string) []int {
+	m := make([]int, 2*(t.groups+1))
+	m[1] = len(path)
+	pos := 0
+	g := 2
+	for _, v := range t.parts {
+		if v.param == nil {
+			if !strings.HasPrefix(path[pos:], v.literal) {
+				return nil
+			}
+			pos += len(v.literal)
+			continue
+		}
+		start := pos
+		for pos < len(path) && v.param.accepts(path[pos]) {
+			pos++
+		}
+		if pos == start && !v.param.empty {
+			return nil
+		}
+		m[g] = start
+		m[g+1] = pos
+		g += 2
+	}
+	if pos != len(path) {
+		return nil
+	}
+	return m
+}
+
+// paramEnd returns the index of the brace which closes the one at
+// start, taking nested braces into account, or -1 if there's none.
+func paramEnd(pattern string, start int) int {
+	depth := 0
+	for ii := start; ii < len(pattern); ii++ {
+		switch pattern[ii] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return ii
+			}
+		}
+	}
+	return -1
+}
+
+// isTemplatePattern returns true iff the pattern contains named
+// parameters using the {name} or {name:type} syntax. Patterns with
+// braces which are not parameters (e.g. repetitions like \d{4}) are
+// regular expressions, even if they also contain a {name}.
+func isTemplatePattern(pattern string) bool {
+	found := false
+	for ii := 0; ii < len(pattern); ii++ {
+		switch pattern[ii] {
+		case '{':
+			end := paramEnd(pattern, ii)
+			if end < 0 || !patternParamRe.MatchString(pattern[ii:end+1]) {
+				return false
+			}
+			found = true
+			ii = end
+		case '}':
+			return false
+		}
+	}
+	return found
+}
+
+// compileTemplate converts a pattern with parameters to its equivalent
+// regular expression. Patterns without explicit anchors match the whole
+// path, while patterns starting with ^ or ending with $ keep the anchors
+// they have, like regular expressions do. If the pattern matches whole
+// paths, all its parameters use a known type and they can be matched
+// unambiguously, a *routeTemplate is also returned.
+func compileTemplate(pattern string) (string, *routeTemplate, error) {
+	var buf bytes.Buffer
+	var parts []templatePart
+	begin, end := true, true
+	if strings.HasPrefix(pattern, "^") || strings.HasSuffix(pattern, "$") {
+		begin = strings.HasPrefix(pattern, "^")
+		end = strings.HasSuffix(pattern, "$")
+		pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "^"), "$")
+	}
+	fast := begin && end
+	if begin {
+		buf.WriteByte('^')
+	}
+	for pattern != "" {
+		start := strings.IndexByte(pattern, '{')
+		if start < 0 {
+			parts = append(parts, templatePart{literal: pattern})
+			buf.WriteString(regexp.QuoteMeta(pattern))
+			break
+		}
+		if lit := pattern[:start]; lit != "" {
+			parts = append(parts, templatePart{literal: lit})
+			buf.WriteString(regexp.QuoteMeta(lit))
+		}
+		stop := paramEnd(pattern, start)
+		if stop < 0 {
+			return "", nil, fmt.Errorf("unterminated parameter in pattern %q", pattern)
+		}
+		param := pattern[start+1 : stop]
+		name := param
+		var typ string
+		if p := strings.IndexByte(param, ':'); p >= 0 {
+			name = param[:p]
+			typ = param[p+1:]
+		}
+		pt := paramTypes[typ]
+		if pt == nil {
+			// Custom regexp, can't use the fast path
+			if _, err := syntax.Parse(typ, syntax.Perl); err != nil {
+				return "", nil, fmt.Errorf("invalid type %q for parameter %q: %s", typ, name, err)
+			}
+			fast = false
+			pt = &paramType{re: typ}
+		}
+		parts = append(parts, templatePart{param: pt})
+		fmt.Fprintf(&buf, "(?P<%s>%s)", name, pt.re)
+		pattern = pattern[stop+1:]
+	}
+	if end {
+		buf.WriteByte('$')
+	}
+	var tmpl *routeTemplate
+	if fast {
+		tmpl = &routeTemplate{parts: parts}
+		for ii, v := range parts {
+			if v.param == nil {
+				continue
+			}
+			tmpl.groups++
+			if ii < len(parts)-1 {
+				// Parameters must be followed by a literal which
+				// can't be part of the parameter, otherwise we'd
+				// need backtracking.
+				next := parts[ii+1]
+				if next.param != nil || v.param.accepts(next.literal[0]) {
+					tmpl = nil
+					break
+				}
+			}
+		}
+	}
+	return buf.String(), tmpl, nil
+}
+
+// compilePattern returns the regexp for the given handler pattern
+// and, when possible, a *routeTemplate which can match it faster.
+func compilePattern(pattern string) (*regexp.Regexp, *routeTemplate, error) {
+	if !isTemplatePattern(pattern) {
+		re, err := regexp.Compile(pattern)
+		return re, nil, err
+	}
+	expr, tmpl, err := compileTemplate(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	re, err := regexp.Compile(expr)
+	return re, tmpl, err
+}
+
+// anchoredPrefix returns the literal prefix that any path
+// matched by the given regexp must start with. For regular
+// expressions which are not anchored at the beginning, it
+// returns an empty string.
+func anchoredPrefix(re *regexp.Regexp) string {
+	sre, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	sre = sre.Simplify()
+	subs := []*syntax.Regexp{sre}
+	if sre.Op == syntax.OpConcat {
+		subs = sre.Sub
+	}
+	if len(subs) == 0 || subs[0].Op != syntax.OpBeginText {
+		return ""
+	}
+	var buf bytes.Buffer
+	for _, v := range subs[1:] {
+		if v.Op != syntax.OpLiteral || v.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		for _, r := range v.Rune {
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+// routeNode is a node in the radix tree of literal prefixes. Each
+// node stores the indexes of the handlers whose literal prefix ends
+// at the node.
+type routeNode struct {
+	prefix   string
+	handlers []int
+	children []*routeNode
+}
+
+func (n *routeNode) insert(key string, idx int) {
+	for key != "" {
+		var child *routeNode
+		for _, v := range n.children {
+			if v.prefix[0] == key[0] {
+				child = v
+				break
+			}
+		}
+		if child == nil {
+			n.children = append(n.children, &routeNode{prefix: key, handlers: []int{idx}})
+			return
+		}
+		common := 0
+		for common < len(key) && common < len(child.prefix) && key[common] == child.prefix[common] {
+			common++
+		}
+		if common < len(child.prefix) {
+			// Split the child
+			split := &routeNode{
+				prefix:   child.prefix[common:],
+				handlers: child.handlers,
+				children: child.children,
+			}
+			child.prefix = child.prefix[:common]
+			child.handlers = nil
+			child.children = []*routeNode{split}
+		}
+		key = key[common:]
+		n = child
+	}
+	n.handlers = append(n.handlers, idx)
+}
+
+// router stores the handlers in a radix tree keyed by their
+// literal prefixes, so only the handlers which can potentially
+// match a given path are tried.
+type router struct {
+	handlers []*handlerInfo
+	root     *routeNode
+}
+
+func newRouter(handlers []*handlerInfo) *router {
+	r := &router{
+		handlers: handlers,
+		root:     &routeNode{},
+	}
+	for ii, v := range handlers {
+		r.root.insert(v.prefix, ii)
+	}
+	return r
+}
+
+// candidates appends to dst the indexes of the handlers which
+// might match the given path, in the same order the handlers
+// were added to the App.
+func (r *router) candidates(path string, dst []int) []int {
+	n := r.root
+	dst = append(dst, n.handlers...)
+	sorted := true
+	for path != "" {
+		var next *routeNode
+		for _, v := range n.children {
+			if v.prefix[0] == path[0] && strings.HasPrefix(path, v.prefix) {
+				next = v
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		if len(next.handlers) > 0 {
+			sorted = sorted && (len(dst) == 0 || dst[len(dst)-1] < next.handlers[0])
+			dst = append(dst, next.handlers...)
+		}
+		path = path[len(next.prefix):]
+		n = next
+	}
+	if !sorted {
+		sort.Ints(dst)
+	}
+	return dst
+}
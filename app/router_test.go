@@ -0,0 +1,165 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type templateTest struct {
+	pattern string
+	expr    string
+	fast    bool
+}
+
+var templateTests = []templateTest{
+	{"/users/{id:int}", `^/users/(?P<id>\d+)$`, true},
+	{"/users/{id:int}/{name}/", `^/users/(?P<id>\d+)/(?P<name>[^/]+)/$`, true},
+	{"/articles/{slug:slug}.html", `^/articles/(?P<slug>[\w\-]+)\.html$`, true},
+	{"/static/{file:path}", `^/static/(?P<file>.+)$`, true},
+	{"/files/{file:*}", `^/files/(?P<file>.*)$`, true},
+	{"/objects/{id:[a-f0-9]{24}}", `^/objects/(?P<id>[a-f0-9]{24})$`, false},
+	{"/{a}{b}", `^/(?P<a>[^/]+)(?P<b>[^/]+)$`, false},
+	{"/{file:path}/edit", `^/(?P<file>.+)/edit$`, false},
+	{"^/users/{id:int}/$", `^/users/(?P<id>\d+)/$`, true},
+	{"^/users/{id:int}/", `^/users/(?P<id>\d+)/`, false},
+	{"/users/{id:int}/$", `/users/(?P<id>\d+)/$`, false},
+}
+
+func TestCompileTemplate(t *testing.T) {
+	for _, v := range templateTests {
+		if !isTemplatePattern(v.pattern) {
+			t.Errorf("%q not detected as a template pattern", v.pattern)
+			continue
+		}
+		expr, tmpl, err := compileTemplate(v.pattern)
+		if err != nil {
+			t.Errorf("error compiling %q: %s", v.pattern, err)
+			continue
+		}
+		if expr != v.expr {
+			t.Errorf("expecting regexp %q for %q, got %q", v.expr, v.pattern, expr)
+		}
+		if fast := tmpl != nil; fast != v.fast {
+			t.Errorf("expecting fast = %v for %q, got %v", v.fast, v.pattern, fast)
+		}
+	}
+	for _, v := range []string{"^/program/(\\d+)/$", "^/archive/(\\d{4})/$", "/foo/",
+		"^/archive/(\\d{2,4})/$", "^/x{1}/$", "^/{id}/(\\d{4})/$", "^/a\\{b\\}/$", "^/{id/$", "^/}/$"} {
+		if isTemplatePattern(v) {
+			t.Errorf("%q detected as a template pattern", v)
+		}
+	}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	paths := []string{"", "/", "/users/", "/users/12", "/users/12/", "/users/12/john/", "/users/x/john/",
+		"/articles/hello-world.html", "/articles/hello world.html", "/static/", "/static/css/style.css",
+		"/files/", "/files/a/b", "/static/a\nb"}
+	for _, v := range templateTests {
+		if !v.fast {
+			continue
+		}
+		re, tmpl, err := compilePattern(v.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range paths {
+			expected := re.FindStringSubmatchIndex(p)
+			if m := tmpl.match(p); !reflect.DeepEqual(m, expected) {
+				t.Errorf("matching %q with %q: expecting %v, got %v", p, v.pattern, expected, m)
+			}
+		}
+	}
+}
+
+func TestAnchoredPrefix(t *testing.T) {
+	cases := map[string]string{
+		"^/foobar/$":              "/foobar/",
+		"^/article/(\\d)$":        "/article/",
+		"^/foo/?$":                "/foo",
+		"^/(foo|bar)/$":           "/",
+		"/foo/":                   "",
+		"^(?i)/foo/":              "",
+		"^/users/(?P<id>\\d+)$":   "/users/",
+		"^/program/(\\d+)/(?:x)?": "/program/",
+	}
+	for k, v := range cases {
+		re, _, err := compilePattern(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := anchoredPrefix(re); p != v {
+			t.Errorf("expecting prefix %q for %q, got %q", v, k, p)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	a := New()
+	handler := func(s string) Handler {
+		return func(ctx *Context) {
+			ctx.WriteString(s)
+		}
+	}
+	a.Handle("^/users/new/$", handler("new"))
+	a.Handle("/users/{id:int}/", handler("user"))
+	a.Handle("^/users/(\\w+)/$", handler("users-re"))
+	a.Handle("^/archive/(\\d{4})/$", handler("archive"))
+	a.Handle("^/posts/{id:int}/", handler("post"))
+	a.Handle("^/", handler("root"))
+	a.Handle("^/users/", handler("never"))
+	cases := map[string]string{
+		"/users/new/":        "new",
+		"/users/12/":         "user",
+		"/users/foo/":        "users-re",
+		"/users/":            "root",
+		"/":                  "root",
+		"/archive/2015/":     "archive",
+		"/posts/3/comments/": "post",
+	}
+	for k, v := range cases {
+		req, _ := http.NewRequest("GET", k, nil)
+		ctx := a.newContext(nil, req)
+		h, _ := a.matchHandler(k, ctx)
+		if h == nil {
+			t.Errorf("no handler for %q", k)
+			continue
+		}
+		w := &testResponseWriter{header: make(http.Header)}
+		ctx.ResponseWriter = w
+		h(ctx)
+		if s := w.String(); s != v {
+			t.Errorf("expecting handler %q for %q, got %q", v, k, s)
+		}
+	}
+}
+
+func TestTemplateParams(t *testing.T) {
+	a := New()
+	a.HandleOptions("/users/{id:int}/{name}/", func(ctx *Context) {
+		fmt.Fprintf(ctx, "%s-%s-%s-%d", ctx.ParamValue("id"), ctx.ParamValue("name"), ctx.IndexValue(1), ctx.Count())
+	}, &HandlerOptions{Name: "user"})
+	req, _ := http.NewRequest("GET", "/users/42/john/", nil)
+	w := &testResponseWriter{header: make(http.Header)}
+	a.ServeHTTP(w, req)
+	if s := w.String(); s != "42-john-john-2" {
+		t.Errorf("unexpected response %q", s)
+	}
+	testReverse(t, "/users/42/john/", a, "user", []interface{}{42, "john"})
+	testReverse(t, "", a, "user", []interface{}{"john", 42})
+}
+
+type testResponseWriter struct {
+	header http.Header
+	data   []byte
+}
+
+func (w *testResponseWriter) Header() http.Header { return w.header }
+func (w *testResponseWriter) WriteHeader(int)     {}
+func (w *testResponseWriter) Write(b []byte) (int, error) {
+	w.data = append(w.data, b...)
+	return len(b), nil
+}
+func (w *testResponseWriter) String() string { return string(w.data) }
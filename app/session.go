@@ -0,0 +1,327 @@
+package app
+
+import (
+	"time"
+
+	"gnd.la/app/cookies"
+	"gnd.la/encoding/base64"
+	"gnd.la/encoding/codec"
+	"gnd.la/util/stringutil"
+)
+
+const (
+	// SessionCookieName is the default name of the cookie used
+	// to store the session id. The cookie is signed using the
+	// gnd.la/app.App secret.
+	SessionCookieName = "session"
+
+	sessionUserKey    = "__gondola_user"
+	sessionFlashesKey = "__gondola_flashes"
+	sessionIdLength   = 24
+	// Minimum interval between updates of the session
+	// access time, to avoid saving sessions on every
+	// request when using IdleTimeout.
+	sessionAccessResolution = time.Minute
+)
+
+// SessionOptions specify how sessions are stored and when
+// they expire. See App.SessionOptions.
+type SessionOptions struct {
+	// CookieName is the name of the cookie used to store
+	// the session id. If empty, SessionCookieName is used.
+	CookieName string
+	// IdleTimeout indicates the maximum amount of time
+	// between two requests using the same session. If
+	// it's zero, sessions don't expire due to inactivity.
+	IdleTimeout time.Duration
+	// AbsoluteTimeout indicates the maximum lifetime of
+	// a session, regardless of its activity. If it's zero,
+	// sessions don't have a maximum lifetime.
+	AbsoluteTimeout time.Duration
+	// StoreUser makes Context.SignIn store the user id in
+	// the session rather than in its own cookie. This allows
+	// revoking signed in users by deleting their sessions
+	// from the SessionStore.
+	StoreUser bool
+}
+
+func (o *SessionOptions) cookieName() string {
+	if o != nil && o.CookieName != "" {
+		return o.CookieName
+	}
+	return SessionCookieName
+}
+
+// sessionData is the value encoded and passed to the SessionStore.
+type sessionData struct {
+	Values   map[string]interface{}
+	Created  time.Time
+	Accessed time.Time
+}
+
+func newSessionData() *sessionData {
+	now := time.Now()
+	return &sessionData{
+		Values:   make(map[string]interface{}),
+		Created:  now,
+		Accessed: now,
+	}
+}
+
+func (d *sessionData) expires(opts *SessionOptions) time.Time {
+	var expires time.Time
+	if opts == nil {
+		return expires
+	}
+	if opts.IdleTimeout > 0 {
+		expires = d.Accessed.Add(opts.IdleTimeout)
+	}
+	if opts.AbsoluteTimeout > 0 {
+		if abs := d.Created.Add(opts.AbsoluteTimeout); expires.IsZero() || abs.Before(expires) {
+			expires = abs
+		}
+	}
+	return expires
+}
+
+// Session represents the server side state associated with
+// a client. Sessions are loaded lazily the first time
+// Context.Session is called during a request and saved
+// automatically before the response headers are sent (or
+// when the Context is closed, if nothing was written).
+//
+// Values stored in a session are encoded using the
+// App.CookieCodec (gob by default), so any non-basic type
+// must be registered using encoding/gob.Register.
+type Session struct {
+	ctx   *Context
+	id    string
+	data  *sessionData
+	isNew bool
+	dirty bool
+	// ids which must be deleted from the store
+	// when saving the session.
+	stale []string
+	// true iff the request included a session cookie
+	hasCookie bool
+}
+
+func newSessionId() string {
+	return base64.Encode(stringutil.RandomBytes(sessionIdLength))
+}
+
+// Session returns the Session for the current request. If
+// there's no session or it has expired, a new one is created.
+// New sessions are only persisted once a value is stored in them.
+func (c *Context) Session() *Session {
+	if c.session == nil {
+		c.session = c.loadSession()
+	}
+	return c.session
+}
+
+func (c *Context) sessionCodec() *codec.Codec {
+	if c.app.CookieCodec != nil {
+		return c.app.CookieCodec
+	}
+	return codec.Get("gob")
+}
+
+func (c *Context) loadSession() *Session {
+	s := &Session{ctx: c}
+	opts := c.app.SessionOptions
+	var id string
+	if c.R != nil && c.Cookies().GetSecure(opts.cookieName(), &id) == nil && id != "" {
+		s.hasCookie = true
+		if data := c.loadSessionData(id); data != nil {
+			now := time.Now()
+			if expires := data.expires(opts); !expires.IsZero() && expires.Before(now) {
+				s.stale = append(s.stale, id)
+			} else {
+				s.id = id
+				s.data = data
+				if opts != nil && opts.IdleTimeout > 0 && now.Sub(data.Accessed) > sessionAccessResolution {
+					data.Accessed = now
+					s.dirty = true
+				}
+				return s
+			}
+		}
+	}
+	s.reset()
+	return s
+}
+
+func (c *Context) loadSessionData(id string) *sessionData {
+	b, err := c.app.sessionStore().Load(c, id)
+	if err != nil {
+		c.logger().Errorf("error loading session: %s", err)
+		return nil
+	}
+	if b == nil {
+		return nil
+	}
+	var data *sessionData
+	if err := c.sessionCodec().Decode(b, &data); err != nil {
+		c.logger().Errorf("error decoding session: %s", err)
+		return nil
+	}
+	if data.Values == nil {
+		data.Values = make(map[string]interface{})
+	}
+	return data
+}
+
+// reset replaces the session with a new and empty one.
+func (s *Session) reset() {
+	s.id = newSessionId()
+	s.data = newSessionData()
+	s.isNew = true
+	s.dirty = false
+}
+
+// ID returns the session id. Note that the id of new sessions
+// is not sent to the client until the session is saved.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Get returns the value stored in the session for the
+// given key, or nil if there's no such key.
+func (s *Session) Get(key string) interface{} {
+	return s.data.Values[key]
+}
+
+// Set stores a value in the session for the given key.
+func (s *Session) Set(key string, value interface{}) {
+	s.data.Values[key] = value
+	s.dirty = true
+}
+
+// Delete removes the value with the given key from
+// the session.
+func (s *Session) Delete(key string) {
+	if _, ok := s.data.Values[key]; ok {
+		delete(s.data.Values, key)
+		s.dirty = true
+	}
+}
+
+// AddFlash adds a flash message to the session. Flash
+// messages are kept until they're retrieved with Flashes.
+func (s *Session) AddFlash(msg string) {
+	flashes, _ := s.Get(sessionFlashesKey).([]string)
+	s.Set(sessionFlashesKey, append(flashes, msg))
+}
+
+// Flashes returns the flash messages stored in the session
+// and removes them.
+func (s *Session) Flashes() []string {
+	flashes, _ := s.Get(sessionFlashesKey).([]string)
+	s.Delete(sessionFlashesKey)
+	return flashes
+}
+
+// Rotate assigns a new id to the session, keeping its values.
+// The previous id is removed from the SessionStore, so it can't
+// be reused. Context.SignIn calls this function automatically to
+// prevent session fixation attacks.
+func (s *Session) Rotate() {
+	if s.isNew {
+		// The id has not been sent to the client yet
+		return
+	}
+	s.stale = append(s.stale, s.id)
+	s.id = newSessionId()
+	s.isNew = true
+	s.dirty = true
+}
+
+// Destroy removes the session from the SessionStore and
+// replaces it with a new and empty one. Context.SignOut
+// calls this function automatically.
+func (s *Session) Destroy() {
+	if !s.isNew {
+		s.stale = append(s.stale, s.id)
+	}
+	s.reset()
+}
+
+// Save persists the session. Most of the time there's no need
+// to call this function, since the Context saves its session
+// before sending the response headers. Note that stores which
+// keep the session data in cookies can't save it once the
+// headers have been sent.
+func (s *Session) Save() error {
+	ctx := s.ctx
+	store := ctx.app.sessionStore()
+	var err error
+	for _, v := range s.stale {
+		if derr := store.Delete(ctx, v); err == nil {
+			err = derr
+		}
+	}
+	s.stale = nil
+	if !s.dirty {
+		if s.isNew && s.hasCookie {
+			// Session was destroyed or has expired
+			ctx.Cookies().Delete(ctx.app.SessionOptions.cookieName())
+			s.hasCookie = false
+		}
+		return err
+	}
+	if s.isNew && len(s.data.Values) == 0 {
+		// Don't persist empty sessions
+		s.dirty = false
+		return err
+	}
+	b, cerr := ctx.sessionCodec().Encode(s.data)
+	if cerr != nil {
+		return cerr
+	}
+	expires := s.data.expires(ctx.app.SessionOptions)
+	if serr := store.Save(ctx, s.id, b, expires); serr != nil {
+		return serr
+	}
+	opts := ctx.app.sessionCookieOptions(expires)
+	if cerr := ctx.Cookies().SetSecureOpts(ctx.app.SessionOptions.cookieName(), s.id, opts); cerr != nil {
+		return cerr
+	}
+	s.isNew = false
+	s.dirty = false
+	s.hasCookie = true
+	return err
+}
+
+func (c *Context) saveSession() {
+	if c.session == nil {
+		return
+	}
+	if err := c.session.Save(); err != nil {
+		c.logger().Errorf("error saving session: %s", err)
+	}
+}
+
+func (app *App) sessionStore() SessionStore {
+	if app.SessionStore != nil {
+		return app.SessionStore
+	}
+	return defaultSessionStore
+}
+
+// sessionCookieOptions returns the cookie options used for the
+// session cookies, which are derived from the App CookieOptions.
+func (app *App) sessionCookieOptions(expires time.Time) *cookies.Options {
+	var opts cookies.Options
+	if app.CookieOptions != nil {
+		opts = *app.CookieOptions
+	} else {
+		opts = *cookies.Defaults()
+	}
+	opts.HttpOnly = true
+	if !expires.IsZero() {
+		opts.Expires = expires
+		opts.MaxAge = 0
+	}
+	return &opts
+}
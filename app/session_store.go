@@ -0,0 +1,193 @@
+package app
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"gnd.la/app/cookies"
+	"gnd.la/cache"
+	"gnd.la/orm"
+)
+
+var (
+	defaultSessionStore SessionStore = &CookieSessionStore{}
+	ormSessionOnce      sync.Once
+)
+
+// SessionStore is the interface implemented by the session
+// backends. The App uses a CookieSessionStore by default,
+// see App.SessionStore to use a different one.
+type SessionStore interface {
+	// Load returns the data for the session with the given id.
+	// If the session does not exist, Load must return nil, nil.
+	Load(ctx *Context, id string) ([]byte, error)
+	// Save stores the data for the session with the given id.
+	// If expires is non-zero, the session might be discarded
+	// after that time.
+	Save(ctx *Context, id string, data []byte, expires time.Time) error
+	// Delete removes the session with the given id.
+	Delete(ctx *Context, id string) error
+}
+
+// CookieSessionStore stores the sessions in an encrypted cookie,
+// using the App secret and encryption key. Since the data is stored
+// on the client, this store is limited to ~4K per session and
+// sessions can't be revoked before they expire.
+type CookieSessionStore struct {
+	// Name is the name of the cookie used to store the session
+	// data. If empty, SessionCookieName + "_data" is used.
+	Name string
+}
+
+type cookieSession struct {
+	Id   string
+	Data []byte
+}
+
+func (s *CookieSessionStore) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return SessionCookieName + "_data"
+}
+
+func (s *CookieSessionStore) Load(ctx *Context, id string) ([]byte, error) {
+	var cs cookieSession
+	if err := ctx.Cookies().GetEncrypted(s.name(), &cs); err != nil {
+		if err == cookies.ErrNoEncrypter {
+			return nil, err
+		}
+		// Missing or tampered cookie
+		return nil, nil
+	}
+	if cs.Id != id {
+		return nil, nil
+	}
+	return cs.Data, nil
+}
+
+func (s *CookieSessionStore) Save(ctx *Context, id string, data []byte, expires time.Time) error {
+	opts := ctx.app.sessionCookieOptions(expires)
+	return ctx.Cookies().SetEncryptedOpts(s.name(), &cookieSession{Id: id, Data: data}, opts)
+}
+
+func (s *CookieSessionStore) Delete(ctx *Context, id string) error {
+	var cs cookieSession
+	if ctx.Cookies().GetEncrypted(s.name(), &cs) == nil && cs.Id == id {
+		ctx.Cookies().Delete(s.name())
+	}
+	return nil
+}
+
+// CacheSessionStore stores the sessions in a gnd.la/cache.Cache.
+type CacheSessionStore struct {
+	// Prefix is prepended to the session id to obtain the cache
+	// key. If empty, "session:" is used.
+	Prefix string
+	// Cache is the cache used to store the sessions. If nil,
+	// the App cache is used.
+	Cache *cache.Cache
+}
+
+func (s *CacheSessionStore) key(id string) string {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "session:"
+	}
+	return prefix + id
+}
+
+func (s *CacheSessionStore) cache(ctx *Context) *cache.Cache {
+	if s.Cache != nil {
+		return s.Cache
+	}
+	return ctx.Cache().Cache
+}
+
+func (s *CacheSessionStore) Load(ctx *Context, id string) ([]byte, error) {
+	data, err := s.cache(ctx).GetBytes(s.key(id))
+	if err == cache.ErrNotFound {
+		err = nil
+	}
+	return data, err
+}
+
+func (s *CacheSessionStore) Save(ctx *Context, id string, data []byte, expires time.Time) error {
+	var timeout int
+	if !expires.IsZero() {
+		timeout = int(expires.Sub(time.Now()) / time.Second)
+		if timeout <= 0 {
+			timeout = 1
+		}
+	}
+	return s.cache(ctx).SetBytes(s.key(id), data, timeout)
+}
+
+func (s *CacheSessionStore) Delete(ctx *Context, id string) error {
+	err := s.cache(ctx).Delete(s.key(id))
+	if err == cache.ErrNotFound {
+		err = nil
+	}
+	return err
+}
+
+// OrmSessionStore stores the sessions in the gondola_sessions
+// table, using the App ORM. Use NewOrmSessionStore to initialize
+// an OrmSessionStore.
+type OrmSessionStore struct {
+}
+
+type ormSession struct {
+	Id      string `orm:",primary_key,max_length=64"`
+	Data    []byte
+	Expires time.Time `orm:",index"`
+}
+
+// NewOrmSessionStore returns a new OrmSessionStore. Since it
+// registers the model used for storing the sessions, it must be
+// called before the App is prepared (e.g. before calling
+// App.ListenAndServe), so the table is created.
+func NewOrmSessionStore() *OrmSessionStore {
+	ormSessionOnce.Do(func() {
+		orm.Register((*ormSession)(nil), &orm.Options{Table: "gondola_sessions"})
+	})
+	return &OrmSessionStore{}
+}
+
+func (s *OrmSessionStore) Load(ctx *Context, id string) ([]byte, error) {
+	var sess ormSession
+	ok, err := ctx.Orm().One(orm.Eq("Id", id), &sess)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if sess.Expires.Before(time.Now()) {
+		return nil, nil
+	}
+	return sess.Data, nil
+}
+
+func (s *OrmSessionStore) Save(ctx *Context, id string, data []byte, expires time.Time) error {
+	if expires.IsZero() {
+		expires = cookies.Permanent
+	}
+	_, err := ctx.Orm().Save(&ormSession{Id: id, Data: data, Expires: expires.UTC()})
+	return err
+}
+
+func (s *OrmSessionStore) Delete(ctx *Context, id string) error {
+	_, err := ctx.Orm().DeleteFrom(s.table(ctx.Orm().Orm), orm.Eq("Id", id))
+	return err
+}
+
+// Purge removes the expired sessions from the database. Apps
+// using an OrmSessionStore should call this function periodically
+// (e.g. from a task scheduled with gnd.la/tasks).
+func (s *OrmSessionStore) Purge(o *orm.Orm) error {
+	_, err := o.DeleteFrom(s.table(o), orm.Lt("Expires", time.Now().UTC()))
+	return err
+}
+
+func (s *OrmSessionStore) table(o *orm.Orm) *orm.Table {
+	return o.TypeTable(reflect.TypeOf(ormSession{}))
+}
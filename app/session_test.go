@@ -0,0 +1,187 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gnd.la/cache"
+	"gnd.la/config"
+)
+
+type sessionTestUser int64
+
+func (u sessionTestUser) Id() int64     { return int64(u) }
+func (u sessionTestUser) IsAdmin() bool { return false }
+
+// sessionClient sends requests to an App, keeping
+// the cookies between requests.
+type sessionClient struct {
+	t       *testing.T
+	a       *App
+	cookies map[string]*http.Cookie
+}
+
+func (c *sessionClient) get(path string) string {
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for _, v := range c.cookies {
+		req.AddCookie(v)
+	}
+	w := httptest.NewRecorder()
+	c.a.ServeHTTP(w, req)
+	for _, v := range (&http.Response{Header: w.Header()}).Cookies() {
+		if v.MaxAge < 0 || (!v.Expires.IsZero() && v.Expires.Before(time.Now())) {
+			delete(c.cookies, v.Name)
+			continue
+		}
+		c.cookies[v.Name] = v
+	}
+	return w.Body.String()
+}
+
+func (c *sessionClient) expect(path string, expected string) {
+	if s := c.get(path); s != expected {
+		c.t.Errorf("expecting %q from %s, got %q", expected, path, s)
+	}
+}
+
+func newSessionTestApp(store SessionStore, opts *SessionOptions) *App {
+	a := New()
+	a.Logger = nil
+	a.Config().Secret = strings.Repeat("s", 32)
+	a.Config().EncryptionKey = strings.Repeat("k", 32)
+	a.SessionStore = store
+	a.SessionOptions = opts
+	a.SetUserFunc(func(ctx *Context, id int64) User {
+		return sessionTestUser(id)
+	})
+	a.Handle("^/set/(\\w+)/$", func(ctx *Context) {
+		ctx.Session().Set("value", ctx.IndexValue(0))
+		ctx.Session().AddFlash("saved " + ctx.IndexValue(0))
+	})
+	a.Handle("^/get/$", func(ctx *Context) {
+		val, _ := ctx.Session().Get("value").(string)
+		ctx.WriteString(val)
+	})
+	a.Handle("^/id/$", func(ctx *Context) {
+		ctx.WriteString(ctx.Session().ID())
+	})
+	a.Handle("^/flashes/$", func(ctx *Context) {
+		ctx.WriteString(strings.Join(ctx.Session().Flashes(), ","))
+	})
+	a.Handle("^/signin/$", func(ctx *Context) {
+		ctx.MustSignIn(sessionTestUser(42))
+	})
+	a.Handle("^/signout/$", func(ctx *Context) {
+		ctx.SignOut()
+	})
+	a.Handle("^/user/$", func(ctx *Context) {
+		if u := ctx.User(); u != nil {
+			ctx.WriteString("signed in")
+		}
+	})
+	return a
+}
+
+func testSession(t *testing.T, store SessionStore) {
+	a := newSessionTestApp(store, nil)
+	c := &sessionClient{t: t, a: a, cookies: make(map[string]*http.Cookie)}
+	c.expect("/get/", "")
+	if len(c.cookies) != 0 {
+		t.Errorf("empty session was saved, cookies %v", c.cookies)
+	}
+	c.expect("/set/foo/", "")
+	c.expect("/get/", "foo")
+	c.expect("/flashes/", "saved foo")
+	c.expect("/flashes/", "")
+	c.expect("/get/", "foo")
+	id := c.get("/id/")
+	c.expect("/signin/", "")
+	c.expect("/user/", "signed in")
+	if newId := c.get("/id/"); newId == id {
+		t.Errorf("session id was not rotated after signing in")
+	}
+	c.expect("/get/", "foo")
+	c.expect("/signout/", "")
+	c.expect("/user/", "")
+	c.expect("/get/", "")
+}
+
+func TestCookieSession(t *testing.T) {
+	testSession(t, nil)
+}
+
+func newSessionTestCache(t *testing.T) *cache.Cache {
+	u, err := config.ParseURL("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc, err := cache.New(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cc
+}
+
+func TestCacheSession(t *testing.T) {
+	cc := newSessionTestCache(t)
+	store := &CacheSessionStore{Cache: cc}
+	testSession(t, store)
+	// Sessions must be revocable on the server side
+	a := newSessionTestApp(store, &SessionOptions{StoreUser: true})
+	c := &sessionClient{t: t, a: a, cookies: make(map[string]*http.Cookie)}
+	c.expect("/signin/", "")
+	c.expect("/user/", "signed in")
+	if _, ok := c.cookies[USER_COOKIE_NAME]; ok {
+		t.Errorf("user cookie set with StoreUser")
+	}
+	if err := cc.Delete(store.key(c.get("/id/"))); err != nil {
+		t.Fatal(err)
+	}
+	c.expect("/user/", "")
+}
+
+func TestSessionExpiration(t *testing.T) {
+	data := &sessionData{Created: time.Now(), Accessed: time.Now()}
+	if e := data.expires(nil); !e.IsZero() {
+		t.Errorf("expecting no expiration without options, got %v", e)
+	}
+	opts := &SessionOptions{IdleTimeout: time.Hour, AbsoluteTimeout: 24 * time.Hour}
+	if e := data.expires(opts); !e.Equal(data.Accessed.Add(time.Hour)) {
+		t.Errorf("expecting idle expiration, got %v", e)
+	}
+	data.Created = data.Created.Add(-24 * time.Hour)
+	if e := data.expires(opts); !e.Equal(data.Created.Add(24 * time.Hour)) {
+		t.Errorf("expecting absolute expiration, got %v", e)
+	}
+	store := &CacheSessionStore{Cache: newSessionTestCache(t)}
+	a := newSessionTestApp(store, &SessionOptions{IdleTimeout: time.Hour})
+	c := &sessionClient{t: t, a: a, cookies: make(map[string]*http.Cookie)}
+	c.expect("/set/foo/", "")
+	c.expect("/get/", "foo")
+	// Simulate inactivity by rewinding the access time
+	id := c.get("/id/")
+	var expired *sessionData
+	ctx := a.NewContext(nil)
+	b, err := store.Load(ctx, id)
+	if err != nil || b == nil {
+		t.Fatalf("error loading session %s: %v", id, err)
+	}
+	if err := ctx.sessionCodec().Decode(b, &expired); err != nil {
+		t.Fatal(err)
+	}
+	expired.Accessed = expired.Accessed.Add(-2 * time.Hour)
+	b, err = ctx.sessionCodec().Encode(expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(ctx, id, b, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	c.expect("/get/", "")
+}
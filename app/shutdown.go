@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"os"
+	ossignal "os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"gnd.la/signal"
+)
+
+// ListenAndServeGraceful works like ListenAndServe, but it also
+// listens for SIGINT and SIGTERM. When one of these signals is received,
+// the App is stopped by calling Shutdown, waiting at most timeout
+// for the pending requests and background contexts to finish.
+func (app *App) ListenAndServeGraceful(timeout time.Duration) error {
+	sigs := make(chan os.Signal, 1)
+	ossignal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer ossignal.Stop(sigs)
+	served := make(chan error, 1)
+	go func() {
+		served <- app.ListenAndServe()
+	}()
+	select {
+	case err := <-served:
+		return err
+	case sig := <-sigs:
+		if app.Logger != nil {
+			app.Logger.Infof("Received signal %s, shutting down", sig)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := app.Shutdown(ctx)
+	// ListenAndServe returns http.ErrServerClosed if the
+	// signal arrived before it started the servers.
+	if serr := <-served; err == nil && serr != http.ErrServerClosed {
+		err = serr
+	}
+	return err
+}
+
+// Shutdown gracefully stops the App. First, it stops accepting new
+// connections and waits for the requests which are being served to
+// finish. Then, it waits for any background contexts spawned with
+// Context.Go to finish and finally closes the shared Cache, Orm and
+// Blobstore. Tasks scheduled using gnd.la/tasks are stopped too.
+//
+// If ctx expires before the pending requests and background contexts
+// have finished, Shutdown returns the error from ctx and leaves the
+// shared resources open, since the remaining requests and background
+// contexts might still be using them. In that case, Shutdown might be
+// called again (e.g. with a longer deadline) to wait for them and
+// close the shared resources. Shutdown emits WILL_SHUTDOWN when it
+// starts and DID_SHUTDOWN when it ends, even if ctx expired. Note that
+// an App can't be restarted after calling Shutdown and, if Shutdown
+// is called while ListenAndServe is still preparing the App, the
+// latter returns http.ErrServerClosed without serving any requests.
+func (app *App) Shutdown(ctx context.Context) error {
+	signal.Emit(WILL_SHUTDOWN, app)
+	app.mu.Lock()
+	app.shuttingDown = true
+	servers := app.httpServers
+	app.mu.Unlock()
+	var err error
+	for _, v := range servers {
+		// Calling Shutdown again on a server waits
+		// for the requests which are still running.
+		if serr := v.Shutdown(ctx); err == nil {
+			err = serr
+		}
+	}
+	if err == nil {
+		err = app.waitBackground(ctx)
+	}
+	if err == nil {
+		err = app.closeShared()
+	}
+	signal.Emit(DID_SHUTDOWN, app)
+	return err
+}
+
+// waitBackground waits until all the background contexts
+// spawned from requests served by this app have finished or
+// ctx expires. Background contexts are tracked by the top-level
+// App, so included apps wait for the ones in their parent.
+func (app *App) waitBackground(ctx context.Context) error {
+	select {
+	case <-app.root().background.idle():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closedChan is returned by backgroundGroup.idle
+// when there are no running goroutines.
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
+}
+
+// backgroundGroup works like a sync.WaitGroup, but waiting is done
+// by receiving from a channel, so it can be abandoned (e.g. when a
+// context expires) without leaking a goroutine.
+type backgroundGroup struct {
+	mu      sync.Mutex
+	running int
+	done    chan struct{}
+}
+
+func (g *backgroundGroup) add() {
+	g.mu.Lock()
+	if g.running == 0 {
+		g.done = make(chan struct{})
+	}
+	g.running++
+	g.mu.Unlock()
+}
+
+func (g *backgroundGroup) finish() {
+	g.mu.Lock()
+	g.running--
+	if g.running == 0 {
+		close(g.done)
+	}
+	g.mu.Unlock()
+}
+
+// idle returns a channel which is closed when
+// there are no goroutines running in the group.
+func (g *backgroundGroup) idle() <-chan struct{} {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running == 0 {
+		return closedChan
+	}
+	return g.done
+}
+
+// closeShared closes the Cache, Orm and Blobstore shared by
+// all the requests served by this app. Included apps share the
+// resources of their parent, so they're only closed by the
+// top-level app.
+func (app *App) closeShared() error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	var err error
+	if app.parent == nil {
+		if app.c != nil && app.c.Cache != nil {
+			err = app.c.Cache.Close()
+		}
+		if app.o != nil && app.o.Orm != nil {
+			if oerr := app.o.Orm.Close(); err == nil {
+				err = oerr
+			}
+		}
+		if app.store != nil {
+			if serr := app.store.Close(); err == nil {
+				err = serr
+			}
+		}
+	}
+	app.c = nil
+	app.o = nil
+	app.store = nil
+	return err
+}
+
+// root returns the top-level App, which is the App
+// itself when it has not been included into another one.
+func (app *App) root() *App {
+	for app.parent != nil {
+		app = app.parent
+	}
+	return app
+}
@@ -0,0 +1,191 @@
+package app
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gnd.la/log"
+)
+
+// certReloadInterval is the minimum interval between checks
+// for changes in the TLS certificate and key files.
+var certReloadInterval = 10 * time.Second
+
+// certReloader loads a TLS certificate and its key from disk,
+// reloading them when any of the files change.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	checked  time.Time
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	modTime, err := r.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) lastModified() (time.Time, error) {
+	var modTime time.Time
+	for _, v := range []string{r.certFile, r.keyFile} {
+		st, err := os.Stat(v)
+		if err != nil {
+			return modTime, err
+		}
+		if mt := st.ModTime(); mt.After(modTime) {
+			modTime = mt
+		}
+	}
+	return modTime, nil
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate %s and key %s: %s", r.certFile, r.keyFile, err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// reload checks if the files have changed since they were loaded
+// and reloads them if required. Errors are logged and the previous
+// certificate is kept, so a partially written file can't stop the
+// App from serving requests.
+func (r *certReloader) reload() {
+	r.mu.Lock()
+	if time.Since(r.checked) < certReloadInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.checked = time.Now()
+	prev := r.modTime
+	r.mu.Unlock()
+	modTime, err := r.lastModified()
+	if err != nil {
+		log.Errorf("error checking TLS certificate: %s", err)
+		return
+	}
+	if modTime.Equal(prev) {
+		return
+	}
+	if err := r.load(modTime); err != nil {
+		log.Error(err)
+		return
+	}
+	log.Infof("reloaded TLS certificate %s", r.certFile)
+}
+
+// GetCertificate implements the function with the same name
+// in crypto/tls.Config.
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.reload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// TLSEnabled returns true iff the App has been configured to
+// serve HTTPS requests, by setting both TLSCert and TLSKey in
+// its Config.
+func (app *App) TLSEnabled() bool {
+	return app.cfg.TLSCert != "" && app.cfg.TLSKey != ""
+}
+
+func (app *App) checkTLS() error {
+	cfg := app.cfg
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return fmt.Errorf("both TLSCert and TLSKey must be set to enable TLS")
+	}
+	if app.TLSEnabled() {
+		if p := cfg.TLSPort; p <= 0 {
+			return fmt.Errorf("TLS port %d is invalid, must be > 0", p)
+		}
+		if cfg.TLSPort == cfg.Port {
+			return fmt.Errorf("TLS port can't be the same as the HTTP port (%d)", cfg.Port)
+		}
+	}
+	return nil
+}
+
+// servers returns the *http.Server instances which serve this
+// App. When TLS is enabled, the first one serves HTTPS while the
+// second one redirects HTTP requests to HTTPS.
+func (app *App) servers() ([]*http.Server, error) {
+	addr := app.address + ":" + strconv.Itoa(app.cfg.Port)
+	if !app.TLSEnabled() {
+		return []*http.Server{{Addr: addr, Handler: app}}, nil
+	}
+	reloader, err := newCertReloader(app.cfg.TLSCert, app.cfg.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	https := &http.Server{
+		Addr:    app.address + ":" + strconv.Itoa(app.cfg.TLSPort),
+		Handler: app,
+		TLSConfig: &tls.Config{
+			GetCertificate: reloader.GetCertificate,
+		},
+	}
+	redirect := &http.Server{
+		Addr:    addr,
+		Handler: httpsRedirectHandler(app.cfg.TLSPort),
+	}
+	return []*http.Server{https, redirect}, nil
+}
+
+// httpsRedirectHandler returns an http.Handler which permanently
+// redirects every request to the same URL using HTTPS on the given
+// port.
+func httpsRedirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]")
+		}
+		if port == 443 {
+			if strings.IndexByte(host, ':') >= 0 {
+				host = "[" + host + "]"
+			}
+		} else {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		u := *r.URL
+		u.Scheme = "https"
+		u.Host = host
+		http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+	})
+}
+
+// setHSTSHeader adds the Strict-Transport-Security header to
+// responses to HTTPS requests when HSTSMaxAge is non-zero.
+func (app *App) setHSTSHeader(w http.ResponseWriter, r *http.Request) {
+	if r.TLS != nil && app.cfg.HSTSMaxAge > 0 {
+		w.Header().Set("Strict-Transport-Security", "max-age="+strconv.Itoa(app.cfg.HSTSMaxAge))
+	}
+}
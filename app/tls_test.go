@@ -0,0 +1,158 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCert(t *testing.T, dir string, name string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{certFile, keyFile} {
+		if err := os.Chtimes(v, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func certName(t *testing.T, r *certReloader) string {
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return x.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gondola-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	prevInterval := certReloadInterval
+	certReloadInterval = 0
+	defer func() {
+		certReloadInterval = prevInterval
+	}()
+	now := time.Now()
+	writeTestCert(t, dir, "first.example.com", now.Add(-time.Minute))
+	r, err := newCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := certName(t, r); n != "first.example.com" {
+		t.Errorf("expecting first.example.com, got %s", n)
+	}
+	writeTestCert(t, dir, "second.example.com", now)
+	if n := certName(t, r); n != "second.example.com" {
+		t.Errorf("expecting second.example.com after reloading, got %s", n)
+	}
+	// Broken files must not replace the current certificate
+	if err := ioutil.WriteFile(filepath.Join(dir, "cert.pem"), []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if n := certName(t, r); n != "second.example.com" {
+		t.Errorf("expecting second.example.com with broken files, got %s", n)
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	cases := []struct {
+		port   int
+		url    string
+		expect string
+	}{
+		{443, "http://example.com/foo?bar=1", "https://example.com/foo?bar=1"},
+		{443, "http://example.com:8888/foo", "https://example.com/foo"},
+		{8443, "http://example.com:8888/", "https://example.com:8443/"},
+		{8443, "http://[::1]:8888/", "https://[::1]:8443/"},
+		{443, "http://[::1]/", "https://[::1]/"},
+	}
+	for _, v := range cases {
+		req, err := http.NewRequest("GET", v.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		httpsRedirectHandler(v.port).ServeHTTP(w, req)
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("expecting code %d for %s, got %d", http.StatusMovedPermanently, v.url, w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != v.expect {
+			t.Errorf("expecting redirect from %s to %s, got %s", v.url, v.expect, loc)
+		}
+	}
+}
+
+func TestHTTPS(t *testing.T) {
+	a := New()
+	a.Logger = nil
+	a.Config().HSTSMaxAge = 3600
+	a.Handle("^/$", func(ctx *Context) {
+		ctx.WriteString(ctx.URL().String())
+	})
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "example.com"
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if s := w.Body.String(); s != "http://example.com/" {
+		t.Errorf("expecting http://example.com/, got %s", s)
+	}
+	if h := w.Header().Get("Strict-Transport-Security"); h != "" {
+		t.Errorf("unexpected HSTS header over HTTP %q", h)
+	}
+	req.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if s := w.Body.String(); s != "https://example.com/" {
+		t.Errorf("expecting https://example.com/, got %s", s)
+	}
+	if h := w.Header().Get("Strict-Transport-Security"); h != "max-age=3600" {
+		t.Errorf("expecting HSTS header max-age=3600, got %q", h)
+	}
+}
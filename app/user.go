@@ -2,6 +2,8 @@ package app
 
 import (
 	"errors"
+
+	"gnd.la/util/types"
 )
 
 const (
@@ -34,24 +36,40 @@ type UserFunc func(ctx *Context, id int64) User
 // UserFunc defined.
 func (c *Context) User() User {
 	if c.user == nil && c.app.userFunc != nil {
-		var id int64
-		err := c.Cookies().GetSecure(USER_COOKIE_NAME, &id)
-		if err == nil {
-			c.user = c.app.userFunc(c, id)
+		if c.storesUserInSession() {
+			if val := c.Session().Get(sessionUserKey); val != nil {
+				if id, err := types.ToInt64(val); err == nil {
+					c.user = c.app.userFunc(c, id)
+				}
+			}
+		} else {
+			var id int64
+			err := c.Cookies().GetSecure(USER_COOKIE_NAME, &id)
+			if err == nil {
+				c.user = c.app.userFunc(c, id)
+			}
 		}
 	}
 	return c.user
 }
 
 // SignIn sets the cookie for signin in the given user. The default
-// cookie options for the App are used.
+// cookie options for the App are used. If the App SessionOptions have
+// StoreUser set, the user id is stored in the session instead. In
+// both cases, the session id is rotated.
 func (c *Context) SignIn(user User) error {
 	if c.app.userFunc == nil {
 		return errNoUserFunc
 	}
-	err := c.Cookies().SetSecure(USER_COOKIE_NAME, user.Id())
-	if err != nil {
-		return err
+	session := c.Session()
+	session.Rotate()
+	if c.storesUserInSession() {
+		session.Set(sessionUserKey, user.Id())
+	} else {
+		err := c.Cookies().SetSecure(USER_COOKIE_NAME, user.Id())
+		if err != nil {
+			return err
+		}
 	}
 	c.user = user
 	return nil
@@ -64,8 +82,18 @@ func (c *Context) MustSignIn(user User) {
 	}
 }
 
-// SignOut deletes the signed in cookie for the current user. If there's
-// no current signed in user, it does nothing.
+// SignOut deletes the signed in cookie for the current user and
+// destroys the current session. Note that the session is destroyed
+// even if there's no signed in user, so any data stored in it by
+// an anonymous user is lost too.
 func (c *Context) SignOut() {
-	c.Cookies().Delete(USER_COOKIE_NAME)
+	if !c.storesUserInSession() {
+		c.Cookies().Delete(USER_COOKIE_NAME)
+	}
+	c.Session().Destroy()
+	c.user = nil
+}
+
+func (c *Context) storesUserInSession() bool {
+	return c.app.SessionOptions != nil && c.app.SessionOptions.StoreUser
 }
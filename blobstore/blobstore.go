@@ -11,6 +11,7 @@ import (
 
 	"gnd.la/blobstore/driver"
 	"gnd.la/config"
+	"gnd.la/internal/httpserve"
 )
 
 var (
@@ -177,9 +178,23 @@ func (s *Blobstore) Driver() driver.Driver {
 // Serve servers the given file by writing it to the given http.ResponseWriter.
 // Some drivers might be able to serve the file directly from their backend. Otherwise,
 // the file will be read from the blobstore and written to w. The rng parameter might be
-// used for sending a partial response to the client.
+// used for sending a partial response to the client. To support conditional requests,
+// use ServeRequest.
 func (s *Blobstore) Serve(w http.ResponseWriter, id string, rng *Range) error {
-	if s.srv != nil {
+	return s.serve(w, nil, id, rng)
+}
+
+// ServeRequest works like Serve, but it also handles the Range, If-None-Match
+// and If-Modified-Since headers in the given request, sending a 304 response
+// when the client already has the file. The ETag header is derived from the
+// hash of the file data and, for files with automatically generated ids, the
+// Last-Modified header is set to the file creation time.
+func (s *Blobstore) ServeRequest(w http.ResponseWriter, r *http.Request, id string) error {
+	return s.serve(w, r, id, ParseRange(r))
+}
+
+func (s *Blobstore) serve(w http.ResponseWriter, r *http.Request, id string, rng *Range) error {
+	if s.srv != nil && r == nil {
 		if ok, err := s.srv.Serve(w, id, rng); ok || err != nil {
 			return err
 		}
@@ -189,11 +204,28 @@ func (s *Blobstore) Serve(w http.ResponseWriter, id string, rng *Range) error {
 		return err
 	}
 	defer f.Close()
+	hash, err := f.Hash()
+	if err != nil {
+		return err
+	}
+	header := w.Header()
+	httpserve.SetValidators(header, httpserve.ETag(hash), idTime(id))
+	if r != nil {
+		if httpserve.NotModified(r, header) {
+			httpserve.WriteNotModified(w)
+			return nil
+		}
+		if s.srv != nil {
+			if ok, err := s.srv.Serve(w, id, rng); ok || err != nil {
+				return err
+			}
+		}
+	}
 	size, err := f.Size()
 	if err != nil {
 		return err
 	}
-	var r io.Reader = f
+	var rd io.Reader = f
 	if rng.IsValid() {
 		if rng.Start != nil {
 			var offset int64
@@ -207,12 +239,12 @@ func (s *Blobstore) Serve(w http.ResponseWriter, id string, rng *Range) error {
 			}
 		}
 		if rng.End != nil {
-			r = &io.LimitedReader{R: r, N: int64(rng.Size(size))}
+			rd = &io.LimitedReader{R: rd, N: int64(rng.Size(size))}
 		}
 	}
 	rng.Set(w, size)
 	w.WriteHeader(rng.StatusCode())
-	if _, err := io.Copy(w, r); err != nil {
+	if _, err := io.Copy(w, rd); err != nil {
 		return err
 	}
 	return nil
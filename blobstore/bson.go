@@ -1,6 +1,8 @@
 package blobstore
 
 import (
+	"time"
+
 	"gnd.la/internal/bson"
 )
 
@@ -15,3 +17,12 @@ func unmarshal(data []byte, out interface{}) error {
 func newId() string {
 	return bson.NewObjectId().Hex()
 }
+
+// idTime returns the creation time encoded in the given
+// id, or the zero time if the id was not generated by newId.
+func idTime(id string) time.Time {
+	if !bson.IsObjectIdHex(id) {
+		return time.Time{}
+	}
+	return bson.ObjectIdHex(id).Time()
+}
@@ -118,6 +118,15 @@ func (r *RFile) Size() (uint64, error) {
 	return r.dataLength, nil
 }
 
+// Hash returns the fnv64a hash of the file data,
+// as recorded when the file was stored.
+func (r *RFile) Hash() (uint64, error) {
+	if err := r.decodeMeta(); err != nil {
+		return 0, err
+	}
+	return r.dataHash, nil
+}
+
 func (r *RFile) decodeMeta() error {
 	if !r.hasMeta {
 		if !r.store.drvNoMeta {
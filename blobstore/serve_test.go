@@ -0,0 +1,62 @@
+package blobstore
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	_ "gnd.la/blobstore/driver/file"
+	"gnd.la/config"
+)
+
+func TestServeRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serve-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := New(config.MustParseURL("file://" + dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	data := []byte("hello blobstore")
+	id, err := store.Store(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("GET", "/"+id, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		if err := store.ServeRequest(w, r, id); err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+	w := serve(nil)
+	etag := w.Header().Get("ETag")
+	lastModified := w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || w.Body.String() != string(data) {
+		t.Fatalf("expecting 200 with %q, got %d with %q", string(data), w.Code, w.Body.String())
+	}
+	if etag == "" || lastModified == "" {
+		t.Fatalf("expecting ETag and Last-Modified, got %q and %q", etag, lastModified)
+	}
+	if w = serve(http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expecting 304 with If-None-Match, got %d", w.Code)
+	}
+	if w = serve(http.Header{"If-None-Match": {`"other"`}}); w.Code != http.StatusOK {
+		t.Errorf("expecting 200 with different If-None-Match, got %d", w.Code)
+	}
+	if w = serve(http.Header{"If-Modified-Since": {lastModified}}); w.Code != http.StatusNotModified {
+		t.Errorf("expecting 304 with If-Modified-Since, got %d", w.Code)
+	}
+	if w = serve(http.Header{"Range": {"bytes=0-4"}}); w.Code != http.StatusPartialContent || w.Body.String() != "hello" {
+		t.Errorf("expecting 206 with \"hello\", got %d with %q", w.Code, w.Body.String())
+	}
+}
@@ -87,7 +87,7 @@ func (r *Renderer) BeginField(w io.Writer, field *form.Field) error {
 
 func (r *Renderer) BeginLabel(w io.Writer, field *form.Field, label string, pos int) error {
 	var err error
-	if field.Type == form.CHECKBOX || field.Type == form.RADIO {
+	if field.Type == form.CHECKBOX || field.Type == form.RADIO || field.Type == form.CHECKBOXES {
 		if c := r.inlineLabelClass(); c != "" {
 			div := html.Div()
 			div.Attrs = html.Attrs{"class": c}
@@ -95,9 +95,13 @@ func (r *Renderer) BeginLabel(w io.Writer, field *form.Field, label string, pos
 			_, err = div.WriteTo(w)
 		}
 	}
-	if err == nil && field.Type == form.RADIO && pos >= 0 {
+	if err == nil && (field.Type == form.RADIO || field.Type == form.CHECKBOXES) && pos >= 0 {
+		class := "radio"
+		if field.Type == form.CHECKBOXES {
+			class = "checkbox"
+		}
 		div := html.Div()
-		div.Attrs = html.Attrs{"class": "radio"}
+		div.Attrs = html.Attrs{"class": class}
 		div.Open = true
 		_, err = div.WriteTo(w)
 	}
@@ -105,7 +109,7 @@ func (r *Renderer) BeginLabel(w io.Writer, field *form.Field, label string, pos
 }
 
 func (r *Renderer) LabelAttributes(field *form.Field, pos int) (html.Attrs, error) {
-	if field.Type != form.CHECKBOX && field.Type != form.RADIO {
+	if field.Type != form.CHECKBOX && field.Type != form.RADIO && field.Type != form.CHECKBOXES {
 		if c := r.labelClass(); c != "" {
 			return html.Attrs{"class": c}, nil
 		}
@@ -115,11 +119,11 @@ func (r *Renderer) LabelAttributes(field *form.Field, pos int) (html.Attrs, erro
 
 func (r *Renderer) EndLabel(w io.Writer, field *form.Field, pos int) error {
 	var err error
-	if field.Type == form.RADIO && pos >= 0 {
+	if (field.Type == form.RADIO || field.Type == form.CHECKBOXES) && pos >= 0 {
 		_, err = io.WriteString(w, "</div>")
 	}
 	if err == nil {
-		if field.Type != form.CHECKBOX && field.Type != form.RADIO {
+		if field.Type != form.CHECKBOX && field.Type != form.RADIO && field.Type != form.CHECKBOXES {
 			if c := r.inputDivClass(); c != "" {
 				div := html.Div()
 				div.Attrs = html.Attrs{"class": c}
@@ -143,7 +147,7 @@ func (r *Renderer) BeginInput(w io.Writer, field *form.Field, placeholder string
 }
 
 func (r *Renderer) FieldAttributes(field *form.Field, pos int) (html.Attrs, error) {
-	if field.Type == form.CHECKBOX || (field.Type == form.SELECT && pos != -1) || field.Type == form.RADIO || field.Type == form.FILE {
+	if field.Type == form.CHECKBOX || (field.Type == form.SELECT && pos != -1) || field.Type == form.RADIO || field.Type == form.CHECKBOXES || field.Type == form.FILE || field.Type == form.RANGE {
 		return nil, nil
 	}
 	return html.Attrs{
@@ -109,3 +109,39 @@ func New(base string, current, count int, next, prev, sep string, f paginator.Fu
 		pager:     pager,
 	}
 }
+
+// CursorPaginator represents a paginator for results paginated
+// using cursors, which is rendered using Bootstrap's pager markup.
+type CursorPaginator struct {
+	*paginator.CursorPaginator
+}
+
+type cursorPager struct {
+	*paginator.SimplePager
+}
+
+func (p *cursorPager) Root() *html.Node {
+	return &html.Node{
+		Tag:   "ul",
+		Attrs: html.Attrs{"class": "pager"},
+	}
+}
+
+// NewCursor returns a new CursorPaginator with the given base URL, cursors
+// for the previous and next pages (empty if there's no such page) and texts
+// for the next and previous links.
+func NewCursor(base, prevCursor, nextCursor string, next, prev string, f paginator.CursorFunc) *CursorPaginator {
+	pager := &cursorPager{
+		SimplePager: &paginator.SimplePager{
+			Wrapper:       "li",
+			Next:          next,
+			Prev:          prev,
+			NextClass:     "next",
+			PrevClass:     "previous",
+			DisabledClass: "disabled",
+		},
+	}
+	return &CursorPaginator{
+		CursorPaginator: paginator.NewCursor(base, prevCursor, nextCursor, pager, f),
+	}
+}
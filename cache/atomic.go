@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"gnd.la/app/profile"
+	"gnd.la/cache/driver"
+)
+
+var (
+	// ErrNotImplemented is returned by the atomic operations
+	// (Add, Increment, Decrement and CompareAndSwap) when the
+	// cache driver doesn't support them.
+	ErrNotImplemented = driver.ErrNotImplemented
+	// ErrNotNumeric is returned by Increment and Decrement when
+	// the value stored for the key is not a counter.
+	ErrNotNumeric = driver.ErrNotNumeric
+)
+
+func (c *Cache) atomic() (driver.Atomic, error) {
+	if a, ok := c.driver.(driver.Atomic); ok {
+		return a, nil
+	}
+	return nil, ErrNotImplemented
+}
+
+// atomicError wraps errors returned by the atomic operations,
+// except ErrNotImplemented and ErrNotNumeric which are returned
+// unchanged, so callers can check for them.
+func (c *Cache) atomicError(op string, key string, err error) error {
+	if err == nil || err == ErrNotImplemented || err == ErrNotNumeric {
+		return err
+	}
+	aerr := &cacheError{
+		op:  op,
+		key: key,
+		err: err,
+	}
+	c.error(aerr)
+	return aerr
+}
+
+// Add stores the given object in the cache only if there's no
+// object already associated with the given key. It returns true
+// iff the object was stored. Since Add is atomic, it can be used
+// to implement locks shared by several processes. See the
+// documentation for Set for an explanation of the timeout
+// parameter.
+func (c *Cache) Add(key string, object interface{}, timeout int) (bool, error) {
+	b, err := c.codecEncode(key, object)
+	if err != nil {
+		return false, err
+	}
+	return c.AddBytes(key, b, timeout)
+}
+
+// AddBytes works like Add, but stores the given []byte
+// without encoding it.
+func (c *Cache) AddBytes(key string, b []byte, timeout int) (bool, error) {
+	if profile.On && profile.Profiling() {
+		defer profile.Start(cache).Note("ADD", key).End()
+	}
+	a, err := c.atomic()
+	if err != nil {
+		return false, err
+	}
+	if b, err = c.pipeEncode(key, b); err != nil {
+		return false, err
+	}
+	added, err := a.Add(c.backendKey(key), b, timeout)
+	return added, c.atomicError("adding key", key, err)
+}
+
+// Increment atomically increments the counter associated with
+// the given key by delta and returns its new value. If the counter
+// doesn't exist, it's created with the value delta and it expires
+// after the given timeout (see Set). Otherwise, timeout is ignored.
+// Calling Increment with a zero delta returns the counter value
+// without altering it.
+//
+// Note that counters are stored as plain decimal integers, without
+// using the cache codec nor its pipe, so they must be only accessed
+// using Increment and Decrement.
+func (c *Cache) Increment(key string, delta uint64, timeout int) (uint64, error) {
+	if profile.On && profile.Profiling() {
+		defer profile.Start(cache).Note("INCR", key).End()
+	}
+	a, err := c.atomic()
+	if err != nil {
+		return 0, err
+	}
+	val, err := a.Increment(c.backendKey(key), delta, timeout)
+	return val, c.atomicError("incrementing key", key, err)
+}
+
+// Decrement works like Increment, but it subtracts delta from the
+// counter. Counters never go below zero.
+func (c *Cache) Decrement(key string, delta uint64, timeout int) (uint64, error) {
+	if profile.On && profile.Profiling() {
+		defer profile.Start(cache).Note("DECR", key).End()
+	}
+	a, err := c.atomic()
+	if err != nil {
+		return 0, err
+	}
+	val, err := a.Decrement(c.backendKey(key), delta, timeout)
+	return val, c.atomicError("decrementing key", key, err)
+}
+
+// CompareAndSwap atomically replaces the object associated with the
+// given key by object, but only if the currently stored object is
+// old. It returns true iff the object was replaced. Objects are
+// compared after encoding them, so the cache codec must always
+// produce the same encoding for equal objects (e.g. objects
+// containing maps might not be compared reliably). See the
+// documentation for Set for an explanation of the timeout parameter.
+func (c *Cache) CompareAndSwap(key string, old interface{}, object interface{}, timeout int) (bool, error) {
+	ob, err := c.codecEncode(key, old)
+	if err != nil {
+		return false, err
+	}
+	b, err := c.codecEncode(key, object)
+	if err != nil {
+		return false, err
+	}
+	return c.CompareAndSwapBytes(key, ob, b, timeout)
+}
+
+// CompareAndSwapBytes works like CompareAndSwap, but compares and
+// stores the given []byte without encoding them.
+func (c *Cache) CompareAndSwapBytes(key string, old []byte, b []byte, timeout int) (bool, error) {
+	if profile.On && profile.Profiling() {
+		defer profile.Start(cache).Note("CAS", key).End()
+	}
+	a, err := c.atomic()
+	if err != nil {
+		return false, err
+	}
+	if old, err = c.pipeEncode(key, old); err != nil {
+		return false, err
+	}
+	if b, err = c.pipeEncode(key, b); err != nil {
+		return false, err
+	}
+	swapped, err := a.CompareAndSwap(c.backendKey(key), old, b, timeout)
+	return swapped, c.atomicError("swapping key", key, err)
+}
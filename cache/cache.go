@@ -1,9 +1,11 @@
 package cache
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"gnd.la/app/profile"
@@ -33,6 +35,9 @@ type Cache struct {
 	driver    driver.Driver
 	codec     *codec.Codec
 	pipe      *pipe.Pipe
+	// Options used by GetOrSet
+	loadOptions LoadOptions
+	flights     flightGroup
 }
 
 func (c *Cache) backendKey(key string) string {
@@ -51,6 +56,15 @@ func (c *Cache) frontendKey(key string) string {
 // expires. If the timeout is 0, the item never expires, but
 // might be only purged from cache when running out of space.
 func (c *Cache) Set(key string, object interface{}, timeout int) error {
+	b, err := c.codecEncode(key, object)
+	if err != nil {
+		return err
+	}
+	return c.SetBytes(key, b, timeout)
+}
+
+// codecEncode encodes the given object using the cache codec.
+func (c *Cache) codecEncode(key string, object interface{}) ([]byte, error) {
 	b, err := c.codec.Encode(object)
 	if err != nil {
 		eerr := &cacheError{
@@ -60,9 +74,28 @@ func (c *Cache) Set(key string, object interface{}, timeout int) error {
 			err:   err,
 		}
 		c.error(eerr)
-		return eerr
+		return nil, eerr
 	}
-	return c.SetBytes(key, b, timeout)
+	return b, nil
+}
+
+// pipeEncode passes the given data trough the cache
+// pipe, if any.
+func (c *Cache) pipeEncode(key string, b []byte) ([]byte, error) {
+	if c.pipe == nil {
+		return b, nil
+	}
+	b, err := c.pipe.Encode(b)
+	if err != nil {
+		perr := &cacheError{
+			op:  "encoding data with pipe",
+			key: key,
+			err: err,
+		}
+		c.error(perr)
+		return nil, perr
+	}
+	return b, nil
 }
 
 // Get retrieves the requested item from the cache and decodes it
@@ -136,12 +169,30 @@ func (c *Cache) GetMulti(out map[string]interface{}, typer Typer) error {
 		c.error(gerr)
 		return gerr
 	}
+	if err := c.untag(data); err != nil {
+		terr := &cacheError{
+			op:  "checking tags",
+			key: strings.Join(keys, ", "),
+			err: err,
+		}
+		c.error(terr)
+		return terr
+	}
 	if typer == nil {
 		typer = mapTyper(out)
 	}
 	for ii, k := range keys {
-		value := data[qkeys[ii]]
-		if value == nil {
+		value, stale, err := parseStale(data[qkeys[ii]])
+		if err != nil {
+			serr := &cacheError{
+				op:  "checking expiration",
+				key: k,
+				err: err,
+			}
+			c.error(serr)
+			return serr
+		}
+		if value == nil || stale {
 			delete(out, k)
 			continue
 		}
@@ -156,7 +207,7 @@ func (c *Cache) GetMulti(out map[string]interface{}, typer Typer) error {
 			return derr
 		}
 		val := reflect.New(typ)
-		err := c.codec.Decode(value, val.Interface())
+		err = c.codec.Decode(value, val.Interface())
 		if err != nil {
 			derr := &cacheError{
 				op:  "decoding object",
@@ -178,21 +229,12 @@ func (c *Cache) SetBytes(key string, b []byte, timeout int) error {
 	if profile.On && profile.Profiling() {
 		defer profile.Start(cache).Note("SET", key).End()
 	}
-	if c.pipe != nil {
-		var err error
-		b, err = c.pipe.Encode(b)
-		if err != nil {
-			perr := &cacheError{
-				op:  "encoding data with pipe",
-				key: key,
-				err: err,
-			}
-			c.error(perr)
-			return perr
-		}
+	b, err := c.pipeEncode(key, b)
+	if err != nil {
+		return err
 	}
 	k := c.backendKey(key)
-	err := c.driver.Set(k, b, timeout)
+	err = c.driver.Set(k, b, timeout)
 	if err != nil {
 		serr := &cacheError{
 			op:  "setting key",
@@ -211,7 +253,19 @@ func (c *Cache) GetBytes(key string) ([]byte, error) {
 	if profile.On && profile.Profiling() {
 		defer profile.Start(cache).Note("GET", key).End()
 	}
-	b, err := c.driver.Get(c.backendKey(key))
+	b, stale, err := c.getBytes(key)
+	if err == nil && stale {
+		return nil, ErrNotFound
+	}
+	return b, err
+}
+
+// getBytes returns the data for the given key, after checking
+// its tags and decoding it with the pipe. Items stored with a
+// grace period (see GetOrSet) are returned with stale = true
+// once their timeout expires.
+func (c *Cache) getBytes(key string) (b []byte, stale bool, err error) {
+	b, err = c.driver.Get(c.backendKey(key))
 	if err != nil {
 		gerr := &cacheError{
 			op:  "getting key",
@@ -219,10 +273,34 @@ func (c *Cache) GetBytes(key string) ([]byte, error) {
 			err: err,
 		}
 		c.error(gerr)
-		return nil, gerr
+		return nil, false, gerr
 	}
 	if b == nil {
-		return nil, ErrNotFound
+		return nil, false, ErrNotFound
+	}
+	if bytes.HasPrefix(b, taggedMagic) {
+		items := map[string][]byte{key: b}
+		if err := c.untag(items); err != nil {
+			terr := &cacheError{
+				op:  "checking tags",
+				key: key,
+				err: err,
+			}
+			c.error(terr)
+			return nil, false, terr
+		}
+		if b = items[key]; b == nil {
+			return nil, false, ErrNotFound
+		}
+	}
+	if b, stale, err = parseStale(b); err != nil {
+		serr := &cacheError{
+			op:  "checking expiration",
+			key: key,
+			err: err,
+		}
+		c.error(serr)
+		return nil, false, serr
 	}
 	if c.pipe != nil {
 		b, err = c.pipe.Decode(b)
@@ -233,10 +311,10 @@ func (c *Cache) GetBytes(key string) ([]byte, error) {
 				err: err,
 			}
 			c.error(perr)
-			return nil, perr
+			return nil, false, perr
 		}
 	}
-	return b, nil
+	return b, stale, nil
 }
 
 // Delete removes the key from the cache. An error is returned only
@@ -325,6 +403,20 @@ func newConfig(conf *config.URL) (*Cache, error) {
 			return nil, fmt.Errorf("unknown pipe %q, maybe you forgot an import?", pipeName)
 		}
 	}
+	if grace := conf.Fragment.Get("grace"); grace != "" {
+		g, err := strconv.Atoi(grace)
+		if err != nil || g < 0 {
+			return nil, fmt.Errorf("invalid grace period %q, must be a non-negative number of seconds", grace)
+		}
+		cache.loadOptions.Grace = g
+	}
+	if lock := conf.Fragment.Get("lock"); lock != "" {
+		l, err := strconv.ParseBool(lock)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lock value %q: %s", lock, err)
+		}
+		cache.loadOptions.Lock = l
+	}
 	var opener driver.Opener
 	if conf.Scheme != "" {
 		opener = driver.Get(conf.Scheme)
@@ -3,8 +3,12 @@ package cache
 import (
 	"encoding/gob"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -30,6 +34,9 @@ var (
 		testSetExpires,
 		testDelete,
 		testBytes,
+		testAtomic,
+		testTags,
+		testGetOrSet,
 	}
 	benchmarks = []func(T, *Cache){
 		testSetGet,
@@ -274,6 +281,63 @@ func testBytes(t T, c *Cache) {
 	}
 }
 
+func testAtomic(t T, c *Cache) {
+	c.Delete("lock")
+	c.Delete("counter")
+	c.Delete("cas")
+	if added, err := c.Add("lock", true, 0); err != nil || !added {
+		t.Errorf("expecting Add to store the object, got %v (error %v)", added, err)
+	}
+	if added, err := c.Add("lock", true, 0); err != nil || added {
+		t.Errorf("expecting Add to fail with an existing object, got %v (error %v)", added, err)
+	}
+	for ii, v := range []struct {
+		delta uint64
+		incr  bool
+		want  uint64
+	}{
+		{5, true, 5},
+		{0, true, 5},
+		{2, false, 3},
+		{10, false, 0},
+		{7, true, 7},
+	} {
+		var val uint64
+		var err error
+		if v.incr {
+			val, err = c.Increment("counter", v.delta, 0)
+		} else {
+			val, err = c.Decrement("counter", v.delta, 0)
+		}
+		if err != nil {
+			t.Errorf("error updating counter at step %d: %s", ii, err)
+		} else if val != v.want {
+			t.Errorf("expecting counter %d at step %d, got %d", v.want, ii, val)
+		}
+	}
+	if _, err := c.Increment("lock", 1, 0); err != ErrNotNumeric {
+		t.Errorf("expecting ErrNotNumeric when incrementing an object, got %v", err)
+	}
+	if swapped, err := c.CompareAndSwap("cas", 1, 2, 0); err != nil || swapped {
+		t.Errorf("expecting CompareAndSwap to fail on a missing key, got %v (error %v)", swapped, err)
+	}
+	if err := c.Set("cas", 1, 0); err != nil {
+		t.Error(err)
+	}
+	if swapped, err := c.CompareAndSwap("cas", 3, 2, 0); err != nil || swapped {
+		t.Errorf("expecting CompareAndSwap to fail with a different value, got %v (error %v)", swapped, err)
+	}
+	if swapped, err := c.CompareAndSwap("cas", 1, 2, 0); err != nil || !swapped {
+		t.Errorf("expecting CompareAndSwap to succeed, got %v (error %v)", swapped, err)
+	}
+	var val int
+	if err := c.Get("cas", &val); err != nil {
+		t.Error(err)
+	} else if val != 2 {
+		t.Errorf("expecting swapped value 2, got %d", val)
+	}
+}
+
 func testCache(t *testing.T, url string) {
 	if testing.Verbose() {
 		log.SetLevel(log.LDebug)
@@ -330,6 +394,76 @@ func TestPrefix(t *testing.T) {
 	}
 }
 
+func TestFileSystemAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c, err := newCache("file://" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testAtomic(t, c)
+}
+
+func TestAtomicNotImplemented(t *testing.T) {
+	c, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Add("k", 1, 0); err != ErrNotImplemented {
+		t.Errorf("expecting ErrNotImplemented, got %v", err)
+	}
+	if _, err := c.Increment("k", 1, 0); err != ErrNotImplemented {
+		t.Errorf("expecting ErrNotImplemented, got %v", err)
+	}
+}
+
+func TestGetOrSetGrace(t *testing.T) {
+	c, err := newCache("memory://#grace=10&lock=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	loader := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+	var v int
+	if err := c.GetOrSet("grace", &v, 1, loader); err != nil || v != 1 {
+		t.Fatalf("expecting 1, got %v (error %v)", v, err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if err := c.Get("grace", &v); err != ErrNotFound {
+		t.Errorf("expecting ErrNotFound for expired object, got %v", err)
+	}
+	// Serve stale object while another call loads it
+	loading := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		var v int
+		c.GetOrSet("grace", &v, 1, func() (interface{}, error) {
+			loading <- true
+			<-done
+			return loader()
+		})
+		done <- true
+	}()
+	<-loading
+	if err := c.GetOrSet("grace", &v, 1, loader); err != nil || v != 1 {
+		t.Errorf("expecting stale 1, got %v (error %v)", v, err)
+	}
+	done <- true
+	<-done
+	if err := c.GetOrSet("grace", &v, 1, loader); err != nil || v != 2 {
+		t.Errorf("expecting 2, got %v (error %v)", v, err)
+	}
+	if calls != 2 {
+		t.Errorf("expecting 2 calls to the loader, got %d", calls)
+	}
+}
+
 func TestMemcache(t *testing.T) {
 	if !testPort(11211) {
 		t.Skip("memcache is not running. start memcache on localhost to run this test")
@@ -426,3 +560,92 @@ func BenchmarkPrefixCache(b *testing.B) {
 func init() {
 	gob.Register((*simple)(nil))
 }
+
+func testTags(t T, c *Cache) {
+	if err := c.SetTagged("tagged1", 1, 0, "a", "b"); err != nil {
+		t.Error(err)
+	}
+	if err := c.SetTagged("tagged2", 2, 0, "b"); err != nil {
+		t.Error(err)
+	}
+	if err := c.SetBytesTagged("tagged3", []byte("3"), 0, "c"); err != nil {
+		t.Error(err)
+	}
+	var v int
+	if err := c.Get("tagged1", &v); err != nil || v != 1 {
+		t.Errorf("expecting 1, got %v (error %v)", v, err)
+	}
+	if err := c.InvalidateTag("a"); err != nil {
+		t.Error(err)
+	}
+	if err := c.Get("tagged1", &v); err != ErrNotFound {
+		t.Errorf("expecting ErrNotFound after invalidating tag, got %v", err)
+	}
+	out := map[string]interface{}{"tagged1": 0, "tagged2": 0}
+	if err := c.GetMulti(out, nil); err != nil {
+		t.Error(err)
+	}
+	if len(out) != 1 || out["tagged2"] != 2 {
+		t.Errorf("expecting only tagged2 = 2, got %v", out)
+	}
+	if err := c.InvalidateTag("b"); err != nil {
+		t.Error(err)
+	}
+	if err := c.Get("tagged2", &v); err != ErrNotFound {
+		t.Errorf("expecting ErrNotFound after invalidating tag, got %v", err)
+	}
+	if b, err := c.GetBytes("tagged3"); err != nil || string(b) != "3" {
+		t.Errorf("expecting \"3\", got %q (error %v)", b, err)
+	}
+	// Storing again after invalidating
+	if err := c.SetTagged("tagged1", 4, 0, "a"); err != nil {
+		t.Error(err)
+	}
+	if err := c.Get("tagged1", &v); err != nil || v != 4 {
+		t.Errorf("expecting 4, got %v (error %v)", v, err)
+	}
+}
+
+func testGetOrSet(t T, c *Cache) {
+	c.Delete("getorset")
+	var calls int32
+	release := make(chan bool)
+	loader := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "loaded", nil
+	}
+	const count = 10
+	var wg sync.WaitGroup
+	results := make([]string, count)
+	for ii := 0; ii < count; ii++ {
+		wg.Add(1)
+		go func(ii int) {
+			defer wg.Done()
+			if err := c.GetOrSet("getorset", &results[ii], 0, loader); err != nil {
+				t.Error(err)
+			}
+		}(ii)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expecting 1 call to the loader, got %d", n)
+	}
+	for ii, v := range results {
+		if v != "loaded" {
+			t.Errorf("expecting \"loaded\" at %d, got %q", ii, v)
+		}
+	}
+	var s string
+	if err := c.Get("getorset", &s); err != nil || s != "loaded" {
+		t.Errorf("expecting \"loaded\", got %q (error %v)", s, err)
+	}
+	if err := c.GetOrSet("getorset", &s, 0, func() (interface{}, error) {
+		t.Error("loader called for cached object")
+		return nil, nil
+	}); err != nil {
+		t.Error(err)
+	}
+}
@@ -10,10 +10,21 @@
 //  - codec: The codec used for encoding/decoding the cached objects. See gnd.la/encoding/codec for the available ones.
 //  - pipe: A pipe to pass the data trough, usually for compressing it. See gnd.la/encoding/pipe for the available ones.
 //  - prefix: A prefix to be prepended to all keys stored.
+//  - grace: Number of seconds expired objects are kept and served while GetOrSet loads them again.
+//  - lock: Use a lock in the cache to make sure only one process loads each object in GetOrSet.
 //
 // Note that these options are not mandatory. For the available drivers, see gnd.la/cache/driver for the ones without
 // dependencies and its subpackages for the ones with external dependencies.
 //
+// Besides storing and retrieving objects, the memory, file, memcache and redis
+// drivers support atomic operations (see Cache.Add, Cache.Increment, Cache.Decrement
+// and Cache.CompareAndSwap), which might be used to implement counters and locks.
+// Drivers without support for them return ErrNotImplemented.
+//
+// Objects might also be stored with a set of tags using Cache.SetTagged, which
+// allows removing all the objects with a given tag at once with Cache.InvalidateTag.
+// Tags are implemented using generation counters, so they work with every driver.
+//
 // Some examples of valid configurations:
 //
 //  memcache://localhost#codec=json&pipe=zlib
@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrNotNumeric is returned by Atomic.Increment and Atomic.Decrement
+// when the existing value for the key is not a decimal integer.
+var ErrNotNumeric = errors.New("cached value is not a number")
+
+// Atomic is an optional interface implemented by drivers which
+// support atomic operations. Drivers which implement Atomic but
+// lack some of its operations should return ErrNotImplemented
+// from them.
+type Atomic interface {
+	// Add stores the value for the given key only if the key
+	// doesn't exist yet. It returns true iff the value was
+	// stored. See Driver.Set for the meaning of timeout.
+	Add(key string, b []byte, timeout int) (bool, error)
+	// Increment atomically increments the counter stored at key
+	// by delta and returns its new value. If the key doesn't
+	// exist, it's initialized to delta, expiring after the
+	// given timeout. Otherwise, timeout is ignored. Counters are
+	// stored as decimal integers.
+	Increment(key string, delta uint64, timeout int) (uint64, error)
+	// Decrement works like Increment, but it subtracts delta from
+	// the counter. Counters never go below zero.
+	Decrement(key string, delta uint64, timeout int) (uint64, error)
+	// CompareAndSwap sets the value for the given key to b only if
+	// its current value is equal to old. It returns true iff the
+	// value was updated. Keys which don't exist are never updated.
+	CompareAndSwap(key string, old []byte, b []byte, timeout int) (bool, error)
+}
+
+// parseCounter parses a counter value stored as a
+// decimal integer. A nil value represents zero.
+func parseCounter(b []byte) (uint64, error) {
+	if b == nil {
+		return 0, nil
+	}
+	val, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0, ErrNotNumeric
+	}
+	return val, nil
+}
+
+// addCounter returns the result of adding delta to val if
+// incr is true or subtracting it, saturating at zero, otherwise.
+func addCounter(val uint64, delta uint64, incr bool) uint64 {
+	if incr {
+		return val + delta
+	}
+	if delta > val {
+		return 0
+	}
+	return val - delta
+}
+
+func formatCounter(val uint64) []byte {
+	return strconv.AppendUint(nil, val, 10)
+}
@@ -1,10 +1,12 @@
 package driver
 
 import (
+	"bytes"
 	"encoding/binary"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"gnd.la/config"
@@ -12,6 +14,14 @@ import (
 	"gnd.la/util/pathutil"
 )
 
+// fsMu serializes the atomic operations in
+// FileSystemDriver.
+var fsMu sync.Mutex
+
+// FileSystemDriver implements a cache which stores each item in
+// its own file. Note that its atomic operations (see Atomic) are
+// only atomic among the FileSystemDriver instances in the same
+// process, with the exception of Add.
 type FileSystemDriver struct {
 	Root string
 }
@@ -22,12 +32,16 @@ func (f *FileSystemDriver) keyPath(key string) string {
 }
 
 func (f *FileSystemDriver) Set(key string, b []byte, timeout int) error {
+	return f.write(key, b, timeout, os.O_TRUNC)
+}
+
+func (f *FileSystemDriver) write(key string, b []byte, timeout int, flag int) error {
 	p := f.keyPath(key)
 	err := os.MkdirAll(filepath.Dir(p), 0755)
 	if err != nil {
 		return err
 	}
-	fd, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	fd, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|flag, 0644)
 	if err != nil {
 		return err
 	}
@@ -50,23 +64,30 @@ func (f *FileSystemDriver) Set(key string, b []byte, timeout int) error {
 }
 
 func (f *FileSystemDriver) Get(key string) ([]byte, error) {
+	data, _, err := f.read(key)
+	return data, err
+}
+
+// read returns the data for the given key and its expiration
+// as a Unix time (zero means no expiration).
+func (f *FileSystemDriver) read(key string) ([]byte, int64, error) {
 	fd, err := os.Open(f.keyPath(key))
 	if err != nil {
 		/* Cache miss */
-		return nil, nil
+		return nil, 0, nil
 	}
 	defer fd.Close()
 	var expiration int64
 	binary.Read(fd, binary.LittleEndian, &expiration)
 	if expiration > 0 && expiration < time.Now().Unix() {
 		f.Delete(key)
-		return nil, nil
+		return nil, 0, nil
 	}
 	data, err := ioutil.ReadAll(fd)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return data, nil
+	return data, expiration, nil
 }
 
 func (f *FileSystemDriver) GetMulti(keys []string) (map[string][]byte, error) {
@@ -82,9 +103,71 @@ func (f *FileSystemDriver) GetMulti(keys []string) (map[string][]byte, error) {
 
 func (f *FileSystemDriver) Delete(key string) error {
 	err := os.Remove(f.keyPath(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
 	return err
 }
 
+func (f *FileSystemDriver) Add(key string, b []byte, timeout int) (bool, error) {
+	// Remove the item if it has expired, so
+	// O_EXCL doesn't make the write fail.
+	if _, err := f.Get(key); err != nil {
+		return false, err
+	}
+	err := f.write(key, b, timeout, os.O_EXCL)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f *FileSystemDriver) Increment(key string, delta uint64, timeout int) (uint64, error) {
+	return f.addCounter(key, delta, timeout, true)
+}
+
+func (f *FileSystemDriver) Decrement(key string, delta uint64, timeout int) (uint64, error) {
+	return f.addCounter(key, delta, timeout, false)
+}
+
+func (f *FileSystemDriver) addCounter(key string, delta uint64, timeout int, incr bool) (uint64, error) {
+	fsMu.Lock()
+	defer fsMu.Unlock()
+	prev, expiration, err := f.read(key)
+	if err != nil {
+		return 0, err
+	}
+	val, err := parseCounter(prev)
+	if err != nil {
+		return 0, err
+	}
+	if prev != nil {
+		// Keep the current expiration
+		timeout = 0
+		if expiration > 0 {
+			timeout = int(expiration - time.Now().Unix())
+			if timeout <= 0 {
+				timeout = 1
+			}
+		}
+	}
+	val = addCounter(val, delta, incr)
+	return val, f.Set(key, formatCounter(val), timeout)
+}
+
+func (f *FileSystemDriver) CompareAndSwap(key string, old []byte, b []byte, timeout int) (bool, error) {
+	fsMu.Lock()
+	defer fsMu.Unlock()
+	prev, _, err := f.read(key)
+	if err != nil || prev == nil || !bytes.Equal(prev, old) {
+		return false, err
+	}
+	if err := f.Set(key, b, timeout); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (f *FileSystemDriver) Close() error {
 	return nil
 }
@@ -3,7 +3,9 @@
 package memcache
 
 import (
+	"bytes"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -49,6 +51,72 @@ func (c *memcacheDriver) Delete(key string) error {
 	return c.error(c.Client.Delete(key))
 }
 
+func (c *memcacheDriver) Add(key string, b []byte, timeout int) (bool, error) {
+	err := c.Client.Add(&memcache.Item{Key: key, Value: b, Expiration: int32(timeout)})
+	if err == memcache.ErrNotStored {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *memcacheDriver) Increment(key string, delta uint64, timeout int) (uint64, error) {
+	return c.addCounter(key, delta, timeout, true)
+}
+
+func (c *memcacheDriver) Decrement(key string, delta uint64, timeout int) (uint64, error) {
+	return c.addCounter(key, delta, timeout, false)
+}
+
+func (c *memcacheDriver) addCounter(key string, delta uint64, timeout int, incr bool) (uint64, error) {
+	f := c.Client.Decrement
+	initial := uint64(0)
+	if incr {
+		f = c.Client.Increment
+		initial = delta
+	}
+	for {
+		val, err := f(key, delta)
+		if err != memcache.ErrCacheMiss {
+			// The server replies with "CLIENT_ERROR cannot
+			// increment or decrement non-numeric value".
+			if err != nil && strings.Contains(err.Error(), "non-numeric") {
+				return 0, driver.ErrNotNumeric
+			}
+			return val, err
+		}
+		// memcache doesn't create missing counters, add
+		// the initial value and retry if another client
+		// created the counter in the meantime.
+		added, err := c.Add(key, []byte(strconv.FormatUint(initial, 10)), timeout)
+		if err != nil {
+			return 0, err
+		}
+		if added {
+			return initial, nil
+		}
+	}
+}
+
+func (c *memcacheDriver) CompareAndSwap(key string, old []byte, b []byte, timeout int) (bool, error) {
+	item, err := c.Client.Get(key)
+	if err != nil {
+		if err == memcache.ErrCacheMiss {
+			err = nil
+		}
+		return false, err
+	}
+	if !bytes.Equal(item.Value, old) {
+		return false, nil
+	}
+	item.Value = b
+	item.Expiration = int32(timeout)
+	err = c.Client.CompareAndSwap(item)
+	if err == memcache.ErrCASConflict || err == memcache.ErrNotStored {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (c *memcacheDriver) Connection() interface{} {
 	return c.Client
 }
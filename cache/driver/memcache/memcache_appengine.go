@@ -3,6 +3,7 @@
 package memcache
 
 import (
+	"bytes"
 	"time"
 
 	"appengine"
@@ -52,6 +53,55 @@ func (c *memcacheDriver) Delete(key string) error {
 	return nil
 }
 
+func (c *memcacheDriver) Add(key string, b []byte, timeout int) (bool, error) {
+	item := &memcache.Item{Key: key, Value: b, Expiration: time.Duration(timeout) * time.Second}
+	err := memcache.Add(c.c, item)
+	if err == memcache.ErrNotStored {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (c *memcacheDriver) Increment(key string, delta uint64, timeout int) (uint64, error) {
+	return c.addCounter(key, int64(delta), timeout)
+}
+
+func (c *memcacheDriver) Decrement(key string, delta uint64, timeout int) (uint64, error) {
+	return c.addCounter(key, -int64(delta), timeout)
+}
+
+func (c *memcacheDriver) addCounter(key string, delta int64, timeout int) (uint64, error) {
+	if timeout != 0 {
+		// memcache.Increment can't set the expiration
+		// of the counters it creates, so create the
+		// counter first if it doesn't exist.
+		if _, err := c.Add(key, []byte("0"), timeout); err != nil {
+			return 0, err
+		}
+	}
+	return memcache.Increment(c.c, key, delta, 0)
+}
+
+func (c *memcacheDriver) CompareAndSwap(key string, old []byte, b []byte, timeout int) (bool, error) {
+	item, err := memcache.Get(c.c, key)
+	if err != nil {
+		if err == memcache.ErrCacheMiss {
+			err = nil
+		}
+		return false, err
+	}
+	if !bytes.Equal(item.Value, old) {
+		return false, nil
+	}
+	item.Value = b
+	item.Expiration = time.Duration(timeout) * time.Second
+	err = memcache.CompareAndSwap(c.c, item)
+	if err == memcache.ErrCASConflict || err == memcache.ErrNotStored {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (c *memcacheDriver) Connection() interface{} {
 	return c
 }
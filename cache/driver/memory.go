@@ -1,6 +1,7 @@
 package driver
 
 import (
+	"bytes"
 	"fmt"
 	"runtime"
 	"sort"
@@ -59,12 +60,16 @@ type MemoryDriver struct {
 }
 
 func (d *MemoryDriver) Set(key string, b []byte, timeout int) error {
-	var expires int64
-	if timeout != 0 {
-		expires = time.Now().Unix() + int64(timeout)
-	}
-	prevSize := uint64(0)
 	cache.Lock()
+	d.setLocked(key, b, expiration(timeout))
+	d.unlockAndPrune()
+	return nil
+}
+
+// setLocked stores the given data, must be called
+// with the cache lock held.
+func (d *MemoryDriver) setLocked(key string, b []byte, expires int64) {
+	prevSize := uint64(0)
 	if prev := cache.items[key]; prev != nil {
 		prevSize = uint64(len(prev.data))
 	}
@@ -73,6 +78,24 @@ func (d *MemoryDriver) Set(key string, b []byte, timeout int) error {
 		expires: expires,
 	}
 	cache.size += uint64(len(b)) - prevSize
+}
+
+// getLocked returns the item for the given key if it
+// exists and it hasn't expired. It must be called with
+// the cache lock held for writing.
+func (d *MemoryDriver) getLocked(key string) *item {
+	it := cache.items[key]
+	if it != nil && it.expires != 0 && it.expires < time.Now().Unix() {
+		delete(cache.items, key)
+		cache.size -= uint64(len(it.data))
+		return nil
+	}
+	return it
+}
+
+// unlockAndPrune releases the cache lock and prunes the
+// cache if it's over its maximum size.
+func (d *MemoryDriver) unlockAndPrune() {
 	if d.maxSize > 0 && cache.size > d.maxSize {
 		d.mu.Lock()
 		// Unlock before sending over the channel,
@@ -81,12 +104,63 @@ func (d *MemoryDriver) Set(key string, b []byte, timeout int) error {
 		// cache lock to be released while the send
 		// might be blocking waiting for the pruneWorker.
 		cache.Unlock()
-		d.prune <- struct{}{}
+		if d.prune != nil {
+			d.prune <- struct{}{}
+		}
 		d.mu.Unlock()
-		return nil
+		return
 	}
 	cache.Unlock()
-	return nil
+}
+
+func (d *MemoryDriver) Add(key string, b []byte, timeout int) (bool, error) {
+	cache.Lock()
+	if d.getLocked(key) != nil {
+		cache.Unlock()
+		return false, nil
+	}
+	d.setLocked(key, b, expiration(timeout))
+	d.unlockAndPrune()
+	return true, nil
+}
+
+func (d *MemoryDriver) Increment(key string, delta uint64, timeout int) (uint64, error) {
+	return d.addCounter(key, delta, timeout, true)
+}
+
+func (d *MemoryDriver) Decrement(key string, delta uint64, timeout int) (uint64, error) {
+	return d.addCounter(key, delta, timeout, false)
+}
+
+func (d *MemoryDriver) addCounter(key string, delta uint64, timeout int, incr bool) (uint64, error) {
+	cache.Lock()
+	expires := expiration(timeout)
+	var prev []byte
+	if it := d.getLocked(key); it != nil {
+		prev = it.data
+		expires = it.expires
+	}
+	val, err := parseCounter(prev)
+	if err != nil {
+		cache.Unlock()
+		return 0, err
+	}
+	val = addCounter(val, delta, incr)
+	d.setLocked(key, formatCounter(val), expires)
+	d.unlockAndPrune()
+	return val, nil
+}
+
+func (d *MemoryDriver) CompareAndSwap(key string, old []byte, b []byte, timeout int) (bool, error) {
+	cache.Lock()
+	it := d.getLocked(key)
+	if it == nil || !bytes.Equal(it.data, old) {
+		cache.Unlock()
+		return false, nil
+	}
+	d.setLocked(key, b, expiration(timeout))
+	d.unlockAndPrune()
+	return true, nil
 }
 
 func (d *MemoryDriver) Get(key string) ([]byte, error) {
@@ -194,6 +268,15 @@ func (d *MemoryDriver) pruneCache() {
 	}
 }
 
+// expiration returns the Unix time when an item set
+// with the given timeout expires, or 0 if it doesn't.
+func expiration(timeout int) int64 {
+	if timeout != 0 {
+		return time.Now().Unix() + int64(timeout)
+	}
+	return 0
+}
+
 func openMemoryDriver(url *config.URL) (Driver, error) {
 	mdrv := &MemoryDriver{}
 	if ms := url.Fragment.Get("max_size"); ms != "" {
@@ -11,6 +11,7 @@ package redis
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gnd.la/cache/driver"
@@ -32,6 +33,38 @@ const (
 	DefaultIdleTimeout = 300
 )
 
+var (
+	// counterScript increments (or decrements, when the
+	// third argument is negative) a counter, setting
+	// its expiration when it's created. Counters never
+	// go below zero.
+	counterScript = redis.NewScript(1, `
+local exists = redis.call('EXISTS', KEYS[1])
+local v = redis.call('INCRBY', KEYS[1], ARGV[1])
+if v < 0 then
+	redis.call('INCRBY', KEYS[1], -v)
+	v = 0
+end
+if exists == 0 and tonumber(ARGV[2]) > 0 then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+return v
+`)
+	// casScript sets the key to the second argument if its
+	// current value is equal to the first one.
+	casScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SETEX', KEYS[1], ARGV[3], ARGV[2])
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+)
+
 type redisDriver struct {
 	pool *redis.Pool
 }
@@ -100,6 +133,49 @@ func (r *redisDriver) Delete(key string) error {
 	return err
 }
 
+func (r *redisDriver) Add(key string, b []byte, timeout int) (bool, error) {
+	args := []interface{}{key, b, "NX"}
+	if timeout != 0 {
+		args = append(args, "EX", int32(timeout))
+	}
+	conn := r.pool.Get()
+	reply, err := conn.Do("SET", args...)
+	conn.Close()
+	if err != nil {
+		return false, err
+	}
+	// SET NX returns nil when the key already exists
+	return reply != nil, nil
+}
+
+func (r *redisDriver) Increment(key string, delta uint64, timeout int) (uint64, error) {
+	return r.addCounter(key, int64(delta), timeout)
+}
+
+func (r *redisDriver) Decrement(key string, delta uint64, timeout int) (uint64, error) {
+	return r.addCounter(key, -int64(delta), timeout)
+}
+
+func (r *redisDriver) addCounter(key string, delta int64, timeout int) (uint64, error) {
+	conn := r.pool.Get()
+	val, err := redis.Int64(counterScript.Do(conn, key, delta, timeout))
+	conn.Close()
+	if err != nil {
+		if e, ok := err.(redis.Error); ok && strings.Contains(string(e), "not an integer") {
+			return 0, driver.ErrNotNumeric
+		}
+		return 0, err
+	}
+	return uint64(val), nil
+}
+
+func (r *redisDriver) CompareAndSwap(key string, old []byte, b []byte, timeout int) (bool, error) {
+	conn := r.pool.Get()
+	swapped, err := redis.Int64(casScript.Do(conn, key, old, b, timeout))
+	conn.Close()
+	return swapped == 1, err
+}
+
 func (r *redisDriver) Connection() interface{} {
 	return r.pool
 }
@@ -14,6 +14,19 @@
 // Users with more advanced requirements should write their own Mediator
 // implementation.
 //
+// Mediators which implement TaggedMediator might also associate tags
+// with the cached responses, which can then be removed from the cache
+// using Layer.InvalidateTag, without flushing the whole cache.
+//
+// Concurrent requests for a response which is not cached only run
+// the handler once per process, sharing its response. Mediators which
+// implement GraceMediator might also indicate that expired responses
+// should be served while a single request regenerates them.
+//
+// Cached responses with a 200 status code include the ETag and Last-Modified
+// headers, so clients can revalidate them. Requests with a matching
+// If-None-Match or If-Modified-Since header receive a 304 response.
+//
 //  cache, err := myapp.Cache()
 //  if err != nil {
 //	panic(err)
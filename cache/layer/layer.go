@@ -5,11 +5,13 @@ import (
 	"errors"
 	"net/http"
 	"os"
+	"time"
 
 	"gnd.la/app"
 	"gnd.la/cache"
 	"gnd.la/encoding/codec"
 	"gnd.la/internal"
+	"gnd.la/internal/httpserve"
 	"gnd.la/log"
 )
 
@@ -56,6 +58,12 @@ func (la *Layer) Mediator() Mediator {
 	return la.mediator
 }
 
+// InvalidateTag removes from the cache all the responses which were
+// cached with the given tag. See TaggedMediator.
+func (la *Layer) InvalidateTag(tag string) error {
+	return la.cache.InvalidateTag(tag)
+}
+
 // Wrap takes a app.Handler and returns a new app.Handler
 // wrapped by the Layer. Responses will be cached according
 // to what the Layer's Mediator indicates. Note that when
@@ -74,7 +82,24 @@ func (la *Layer) Wrap(handler app.Handler) app.Handler {
 			return
 		}
 		key := la.mediator.Key(ctx)
-		data, _ := la.cache.GetBytes(key)
+		var opts *cache.LoadOptions
+		if gm, ok := la.mediator.(GraceMediator); ok {
+			opts = &cache.LoadOptions{
+				Grace: gm.GracePeriod(ctx),
+				Lock:  gm.Lock(ctx),
+			}
+		}
+		// Concurrent requests for the same key wait for the one
+		// running the handler and then serve its response, while
+		// requests for a stale response are served immediately.
+		ran := false
+		data, _ := la.cache.GetOrSetBytes(key, opts, func() (*cache.Item, error) {
+			ran = true
+			return la.run(ctx, handler), nil
+		})
+		if ran {
+			return
+		}
 		if data != nil {
 			// has cached data
 			var response *cachedResponse
@@ -86,31 +111,54 @@ func (la *Layer) Wrap(handler app.Handler) app.Handler {
 					header[k] = v
 				}
 				header["X-Gondola-From-Layer"] = fromLayer
+				if response.StatusCode == http.StatusOK && httpserve.NotModified(ctx.R, header) {
+					httpserve.WriteNotModified(ctx)
+					return
+				}
 				ctx.WriteHeader(response.StatusCode)
 				ctx.Write(response.Data)
 				return
 			}
 		}
-
-		rw := ctx.ResponseWriter
-		w := newWriter(rw)
-		ctx.ResponseWriter = w
+		// Response couldn't be cached
 		handler(ctx)
-		ctx.ResponseWriter = rw
-		if la.mediator.Cache(ctx, w.statusCode, w.header) {
-			response := &cachedResponse{w.header, w.statusCode, w.buf.Bytes()}
-			data, err := layerCodec.Encode(response)
-			if err == nil {
-				ctx.Set(internal.LayerCachedKey, true)
-				expiration := la.mediator.Expires(ctx, w.statusCode, w.header)
-				la.cache.SetBytes(key, data, expiration)
-			} else {
-				log.Errorf("Error encoding cached response: %v", err)
-			}
-		}
 	}
 }
 
+// run runs the handler and returns the item to be
+// cached for its response, or nil if the Mediator
+// indicates that the response should not be cached.
+func (la *Layer) run(ctx *app.Context, handler app.Handler) *cache.Item {
+	rw := ctx.ResponseWriter
+	w := newWriter(rw)
+	ctx.ResponseWriter = w
+	handler(ctx)
+	ctx.ResponseWriter = rw
+	if !la.mediator.Cache(ctx, w.statusCode, w.header) {
+		return nil
+	}
+	body := w.buf.Bytes()
+	if w.statusCode == http.StatusOK && w.header != nil {
+		// Let clients revalidate responses served from the cache
+		httpserve.SetValidators(w.header, httpserve.BodyETag(body), time.Now())
+	}
+	response := &cachedResponse{w.header, w.statusCode, body}
+	data, err := layerCodec.Encode(response)
+	if err != nil {
+		log.Errorf("Error encoding cached response: %v", err)
+		return nil
+	}
+	ctx.Set(internal.LayerCachedKey, true)
+	item := &cache.Item{
+		Data:    data,
+		Timeout: la.mediator.Expires(ctx, w.statusCode, w.header),
+	}
+	if tm, ok := la.mediator.(TaggedMediator); ok {
+		item.Tags = tm.Tags(ctx, w.statusCode, w.header)
+	}
+	return item
+}
+
 func init() {
 	gob.Register(&cachedResponse{})
 }
@@ -21,6 +21,33 @@ type Mediator interface {
 	Expires(ctx *app.Context, responseCode int, outgoingHeaders http.Header) int
 }
 
+// TaggedMediator is implemented by Mediators which associate tags
+// with the cached responses. Responses cached with a given tag
+// might be removed from the cache by calling Layer.InvalidateTag
+// (e.g. tag your pages with the models they render and invalidate
+// the tag when a model changes).
+type TaggedMediator interface {
+	Mediator
+	// Tags returns the tags for the response with the given context,
+	// code and headers. It's only called for responses which are cached.
+	Tags(ctx *app.Context, responseCode int, outgoingHeaders http.Header) []string
+}
+
+// GraceMediator is implemented by Mediators which allow serving
+// expired responses while they're being regenerated. Only one
+// request per process (or across all processes, when Lock returns
+// true) runs the handler for an expired response, while the rest
+// are served the stale one. See cache.LoadOptions for more details.
+type GraceMediator interface {
+	Mediator
+	// GracePeriod returns the number of seconds a response is kept
+	// in the cache after it expires (see Mediator.Expires).
+	GracePeriod(ctx *app.Context) int
+	// Lock returns wheter a lock stored in the cache should be used
+	// to make sure only one process regenerates the response.
+	Lock(ctx *app.Context) bool
+}
+
 // SimpleMediator implements a Mediator which caches GET and HEAD
 // request with a 200 response code for a fixed time and skips
 // the cache if any of the indicated cookies are present. Cache keys
@@ -31,6 +58,15 @@ type SimpleMediator struct {
 	SkipCookies []string
 	// Expiration indicates the cache expiration for cached requests.
 	Expiration int
+	// TagFunc, if non-nil, returns the tags associated with
+	// the cached responses. See TaggedMediator.
+	TagFunc func(ctx *app.Context) []string
+	// Grace indicates the number of seconds expired responses
+	// might be served while they're regenerated. See GraceMediator.
+	Grace int
+	// CrossProcessLock indicates if responses should be regenerated
+	// by only one process at a time. See GraceMediator.
+	CrossProcessLock bool
 }
 
 func (m *SimpleMediator) Skip(ctx *app.Context) bool {
@@ -57,3 +93,18 @@ func (m *SimpleMediator) Cache(ctx *app.Context, responseCode int, outgoingHeade
 func (m *SimpleMediator) Expires(ctx *app.Context, responseCode int, outgoingHeaders http.Header) int {
 	return m.Expiration
 }
+
+func (m *SimpleMediator) GracePeriod(ctx *app.Context) int {
+	return m.Grace
+}
+
+func (m *SimpleMediator) Lock(ctx *app.Context) bool {
+	return m.CrossProcessLock
+}
+
+func (m *SimpleMediator) Tags(ctx *app.Context, responseCode int, outgoingHeaders http.Header) []string {
+	if m.TagFunc != nil {
+		return m.TagFunc(ctx)
+	}
+	return nil
+}
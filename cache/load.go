@@ -0,0 +1,291 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"gnd.la/app/profile"
+)
+
+const (
+	// lockTimeout is the maximum number of seconds a
+	// lock acquired by GetOrSetBytes is held.
+	lockTimeout = 30
+	// lockPollInterval is the interval used to check
+	// for the item while another process loads it.
+	lockPollInterval = 50 * time.Millisecond
+	lockKeySuffix    = ":gondola-lock"
+)
+
+var (
+	// staleMagic is prepended to the data of items stored
+	// with a grace period, followed by their soft expiration.
+	staleMagic      = []byte("\x00gndstl\x01")
+	errInvalidStale = errors.New("invalid item with grace period")
+)
+
+// LoadOptions specify how GetOrSet and GetOrSetBytes
+// handle expired items.
+type LoadOptions struct {
+	// Grace is the number of seconds an item is kept in the cache
+	// after its timeout expires. During this period, the stale item
+	// is returned to all the callers except the one which loads
+	// the new value, avoiding making every caller wait for it.
+	Grace int
+	// Lock indicates if a lock stored in the cache should be used
+	// to make sure only one process loads an item at a time, rather
+	// than only one goroutine per process. Locking requires a driver
+	// with support for atomic operations, it's ignored otherwise.
+	Lock bool
+}
+
+// Item represents an item returned by the loader
+// function passed to GetOrSetBytes.
+type Item struct {
+	// Data is the data to be stored in the cache.
+	Data []byte
+	// Timeout is the item timeout, see Set.
+	Timeout int
+	// Tags are the item tags, see SetTagged.
+	Tags []string
+}
+
+// flightCall represents a load in progress.
+type flightCall struct {
+	wg   sync.WaitGroup
+	item *Item
+	err  error
+}
+
+// flightGroup coalesces the loads for the same key,
+// so only one of them happens at a time in each process.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do calls fn for the given key, unless there's another call in
+// progress for the same key. In that case, if wait is true, do waits
+// for it and returns its results, otherwise it returns immediately
+// with ok = false.
+func (g *flightGroup) do(key string, wait bool, fn func() (*Item, error)) (item *Item, err error, ok bool) {
+	g.mu.Lock()
+	if c := g.calls[key]; c != nil {
+		g.mu.Unlock()
+		if !wait {
+			return nil, nil, false
+		}
+		c.wg.Wait()
+		return c.item, c.err, true
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	c := new(flightCall)
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+	defer func() {
+		c.wg.Done()
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+	}()
+	c.item, c.err = fn()
+	return c.item, c.err, true
+}
+
+// GetOrSet retrieves the object associated with the given key and
+// decodes it into obj, like Get does. If the object is not found,
+// loader is called to obtain it and the result is stored in the cache
+// with the given timeout and then decoded into obj. Concurrent calls
+// to GetOrSet with the same key in the same process are coalesced, so
+// loader is only called once and its result is shared. Use the grace
+// and lock options in the cache configuration to set the LoadOptions
+// used by GetOrSet.
+func (c *Cache) GetOrSet(key string, obj interface{}, timeout int, loader func() (interface{}, error)) error {
+	b, err := c.GetOrSetBytes(key, &c.loadOptions, func() (*Item, error) {
+		object, err := loader()
+		if err != nil {
+			return nil, err
+		}
+		data, err := c.codecEncode(key, object)
+		if err != nil {
+			return nil, err
+		}
+		return &Item{Data: data, Timeout: timeout}, nil
+	})
+	if err != nil {
+		return err
+	}
+	if cerr := c.codec.Decode(b, obj); cerr != nil {
+		derr := &cacheError{
+			op:    "decoding object",
+			key:   key,
+			codec: true,
+			err:   cerr,
+		}
+		c.error(derr)
+		return derr
+	}
+	return nil
+}
+
+// GetOrSetBytes returns the data associated with the given key or,
+// if it's not found, the data returned by loader, which is also
+// stored in the cache. If loader returns a nil *Item, nothing is
+// stored and GetOrSetBytes returns nil data and a nil error.
+//
+// Concurrent calls with the same key in the same process are coalesced,
+// so loader is only called once. If opts is non-nil and specifies a grace
+// period, items are kept in the cache for Grace more seconds after they
+// expire. Once an item expires, the first caller calls loader while the
+// rest receive the stale item. If opts.Lock is true, a lock in the cache
+// is used to make sure only one process calls loader. Callers which find
+// a locked key without a stale item wait until the key is loaded by the
+// other process (or until the lock expires).
+func (c *Cache) GetOrSetBytes(key string, opts *LoadOptions, loader func() (*Item, error)) ([]byte, error) {
+	if profile.On && profile.Profiling() {
+		defer profile.Start(cache).Note("GET OR SET", key).End()
+	}
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+	b, stale, err := c.getBytes(key)
+	if err == nil && !stale {
+		return b, nil
+	}
+	load := func() (*Item, error) {
+		if opts.Lock {
+			locked, err := c.lock(key)
+			if err != nil {
+				return nil, err
+			}
+			if !locked {
+				if stale {
+					return &Item{Data: b}, nil
+				}
+				if data, found := c.waitForLoad(key); found {
+					return &Item{Data: data}, nil
+				}
+			}
+			if locked {
+				defer c.unlock(key)
+			}
+		}
+		item, err := loader()
+		if err != nil || item == nil {
+			return item, err
+		}
+		grace := 0
+		if item.Timeout > 0 {
+			grace = opts.Grace
+		}
+		if err := c.store(key, item.Data, item.Timeout, grace, item.Tags); err != nil {
+			c.warningf("error storing loaded key %s: %s", key, err)
+		}
+		return item, nil
+	}
+	item, err, ok := c.flights.do(key, !stale, load)
+	if !ok {
+		// Stale item, being loaded by another goroutine
+		return b, nil
+	}
+	if err != nil || item == nil {
+		return nil, err
+	}
+	return item.Data, nil
+}
+
+// lock acquires the lock for loading the given key, returning
+// true iff it was acquired. Drivers without support for atomic
+// operations always succeed.
+func (c *Cache) lock(key string) (bool, error) {
+	a, err := c.atomic()
+	if err != nil {
+		return true, nil
+	}
+	locked, err := a.Add(c.backendKey(key+lockKeySuffix), []byte{1}, lockTimeout)
+	if err == ErrNotImplemented {
+		return true, nil
+	}
+	return locked, c.atomicError("acquiring lock", key, err)
+}
+
+func (c *Cache) unlock(key string) {
+	if err := c.driver.Delete(c.backendKey(key + lockKeySuffix)); err != nil {
+		c.warningf("error releasing lock for key %s: %s", key, err)
+	}
+}
+
+// waitForLoad waits until the given key is loaded by another
+// process or its lock expires, returning the loaded data.
+func (c *Cache) waitForLoad(key string) ([]byte, bool) {
+	lk := c.backendKey(key + lockKeySuffix)
+	deadline := time.Now().Add(lockTimeout * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(lockPollInterval)
+		if b, stale, err := c.getBytes(key); err == nil && !stale {
+			return b, true
+		}
+		if l, err := c.driver.Get(lk); err != nil || l == nil {
+			// Lock released without storing the item
+			break
+		}
+	}
+	return nil, false
+}
+
+// store encodes b using the pipe and stores it with the given
+// timeout, grace period and tags (see SetTagged).
+func (c *Cache) store(key string, b []byte, timeout int, grace int, tags []string) error {
+	b, err := c.pipeEncode(key, b)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if len(tags) > 0 {
+		if err := c.writeTags(&buf, key, tags); err != nil {
+			return err
+		}
+	}
+	if grace > 0 && timeout > 0 {
+		buf.Write(staleMagic)
+		writeTaggedBytes(&buf, nil, uint64(time.Now().Add(time.Duration(timeout)*time.Second).UnixNano()))
+		timeout += grace
+	}
+	buf.Write(b)
+	k := c.backendKey(key)
+	if err := c.driver.Set(k, buf.Bytes(), timeout); err != nil {
+		serr := &cacheError{
+			op:  "setting key",
+			key: key,
+			err: err,
+		}
+		c.error(serr)
+		return serr
+	}
+	if len(tags) > 0 || grace > 0 {
+		c.debugf("Set key %s (%d bytes) with tags %v and grace %d, expiring in %d", k, len(b), tags, grace, timeout)
+	} else {
+		c.debugf("Set key %s (%d bytes), expiring in %d", k, len(b), timeout)
+	}
+	return nil
+}
+
+// parseStale returns the data for an item which might have been stored
+// with a grace period, indicating if the item has expired.
+func parseStale(b []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(b, staleMagic) {
+		return b, false, nil
+	}
+	b = b[len(staleMagic):]
+	expires, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, false, errInvalidStale
+	}
+	return b[n:], uint64(time.Now().UnixNano()) >= expires, nil
+}
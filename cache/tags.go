@@ -0,0 +1,265 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gnd.la/app/profile"
+	"gnd.la/cache/driver"
+)
+
+const tagKeyPrefix = "gondola:tag:"
+
+var (
+	// taggedMagic is prepended to the data of tagged items,
+	// followed by the tags and their generations.
+	taggedMagic      = []byte("\x00gndtag\x01")
+	errInvalidTagged = errors.New("invalid tagged item")
+	generationSeq    uint32
+)
+
+// tagKey returns the frontend key which stores the
+// generation for the given tag.
+func tagKey(tag string) string {
+	return tagKeyPrefix + tag
+}
+
+// newGeneration returns a new generation for a tag. Generations
+// are based on the current time, so they don't repeat even if
+// the key for a tag is evicted from the cache. A sequence number
+// is appended to avoid collisions on systems with a coarse clock.
+func newGeneration() []byte {
+	gen := strconv.AppendInt(nil, time.Now().UnixNano(), 10)
+	gen = append(gen, '-')
+	return strconv.AppendUint(gen, uint64(atomic.AddUint32(&generationSeq, 1)), 10)
+}
+
+// tagGenerations returns the current generations for the given tags,
+// creating the ones which don't exist yet if create is true.
+func (c *Cache) tagGenerations(tags []string, create bool) (map[string][]byte, error) {
+	keys := make([]string, len(tags))
+	for ii, v := range tags {
+		keys[ii] = c.backendKey(tagKey(v))
+	}
+	data, err := c.driver.GetMulti(keys)
+	if err != nil {
+		return nil, err
+	}
+	generations := make(map[string][]byte, len(tags))
+	for ii, v := range tags {
+		gen := data[keys[ii]]
+		if gen == nil && create {
+			if gen, err = c.createGeneration(keys[ii]); err != nil {
+				return nil, err
+			}
+		}
+		if gen != nil {
+			generations[v] = gen
+		}
+	}
+	return generations, nil
+}
+
+// createGeneration stores a new generation in the given backend
+// key, unless another one was created in the meantime, and
+// returns the generation stored in the key.
+func (c *Cache) createGeneration(key string) ([]byte, error) {
+	gen := newGeneration()
+	if a, ok := c.driver.(driver.Atomic); ok {
+		added, err := a.Add(key, gen, 0)
+		if err != driver.ErrNotImplemented {
+			if err != nil {
+				return nil, err
+			}
+			if !added {
+				return c.driver.Get(key)
+			}
+			return gen, nil
+		}
+	}
+	return gen, c.driver.Set(key, gen, 0)
+}
+
+// SetTagged works like Set, but associates the object with the
+// given tags. Calling InvalidateTag with any of these tags makes
+// the object disappear from the cache. Tags are implemented using
+// generation counters, so they work with any cache driver. Note
+// that retrieving a tagged object requires an additional trip to
+// the cache to check the current generations of its tags.
+func (c *Cache) SetTagged(key string, object interface{}, timeout int, tags ...string) error {
+	b, err := c.codecEncode(key, object)
+	if err != nil {
+		return err
+	}
+	return c.SetBytesTagged(key, b, timeout, tags...)
+}
+
+// SetBytesTagged works like SetTagged, but stores the given
+// []byte without encoding it. See also SetBytes.
+func (c *Cache) SetBytesTagged(key string, b []byte, timeout int, tags ...string) error {
+	if len(tags) == 0 {
+		return c.SetBytes(key, b, timeout)
+	}
+	if profile.On && profile.Profiling() {
+		defer profile.Start(cache).Note("SET TAGGED", key).End()
+	}
+	return c.store(key, b, timeout, 0, tags)
+}
+
+// writeTags writes the header for an item with the given tags
+// into buf, using the current tag generations.
+func (c *Cache) writeTags(buf *bytes.Buffer, key string, tags []string) error {
+	generations, err := c.tagGenerations(tags, true)
+	if err != nil {
+		terr := &cacheError{
+			op:  "getting tag generations",
+			key: key,
+			err: err,
+		}
+		c.error(terr)
+		return terr
+	}
+	buf.Write(taggedMagic)
+	writeTaggedBytes(buf, nil, uint64(len(tags)))
+	for _, v := range tags {
+		writeTaggedBytes(buf, []byte(v), 0)
+		writeTaggedBytes(buf, generations[v], 0)
+	}
+	return nil
+}
+
+// InvalidateTag removes from the cache all the objects associated
+// with the given tag (see SetTagged).
+func (c *Cache) InvalidateTag(tag string) error {
+	if profile.On && profile.Profiling() {
+		defer profile.Start(cache).Note("INVALIDATE TAG", tag).End()
+	}
+	if err := c.driver.Set(c.backendKey(tagKey(tag)), newGeneration(), 0); err != nil {
+		ierr := &cacheError{
+			op:  "invalidating tag",
+			key: tag,
+			err: err,
+		}
+		c.error(ierr)
+		return ierr
+	}
+	c.debugf("Invalidated tag %s", tag)
+	return nil
+}
+
+// writeTaggedBytes writes either a length prefixed
+// []byte (if b is non-nil) or the given number.
+func writeTaggedBytes(buf *bytes.Buffer, b []byte, n uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	if b != nil {
+		n = uint64(len(b))
+	}
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], n)])
+	if b != nil {
+		buf.Write(b)
+	}
+}
+
+// taggedItem represents an item stored with SetTagged.
+type taggedItem struct {
+	tags        []string
+	generations [][]byte
+	data        []byte
+}
+
+// parseTagged returns the tagged item stored in b or nil
+// if b was not stored with SetTagged.
+func parseTagged(b []byte) (*taggedItem, error) {
+	if !bytes.HasPrefix(b, taggedMagic) {
+		return nil, nil
+	}
+	b = b[len(taggedMagic):]
+	count, n := binary.Uvarint(b)
+	if n <= 0 || count > uint64(len(b)) {
+		return nil, errInvalidTagged
+	}
+	b = b[n:]
+	item := &taggedItem{
+		tags:        make([]string, count),
+		generations: make([][]byte, count),
+	}
+	next := func() ([]byte, error) {
+		size, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < size {
+			return nil, errInvalidTagged
+		}
+		v := b[n : n+int(size)]
+		b = b[n+int(size):]
+		return v, nil
+	}
+	for ii := range item.tags {
+		tag, err := next()
+		if err != nil {
+			return nil, err
+		}
+		gen, err := next()
+		if err != nil {
+			return nil, err
+		}
+		item.tags[ii] = string(tag)
+		item.generations[ii] = gen
+	}
+	item.data = b
+	return item, nil
+}
+
+// untag returns the data for the given items, removing the tagged
+// ones which have been invalidated. The keys in items are only used
+// to identify them, so they might be either frontend or backend keys
+// (GetMulti uses the latter). Items are modified in place.
+func (c *Cache) untag(items map[string][]byte) error {
+	var tagged map[string]*taggedItem
+	var tags []string
+	seen := make(map[string]bool)
+	for k, v := range items {
+		item, err := parseTagged(v)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			continue
+		}
+		if tagged == nil {
+			tagged = make(map[string]*taggedItem)
+		}
+		tagged[k] = item
+		for _, t := range item.tags {
+			if !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	if len(tagged) == 0 {
+		return nil
+	}
+	generations, err := c.tagGenerations(tags, false)
+	if err != nil {
+		return err
+	}
+	for k, v := range tagged {
+		valid := true
+		for ii, t := range v.tags {
+			if !bytes.Equal(generations[t], v.generations[ii]) {
+				valid = false
+				break
+			}
+		}
+		if valid {
+			items[k] = v.data
+		} else {
+			delete(items, k)
+			c.debugf("Key %s was invalidated by its tags", k)
+		}
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"gnd.la/app"
 	"gnd.la/log"
+	"gnd.la/orm/migrations"
 
 	"gopkgs.com/vfs.v1"
 )
@@ -108,6 +109,58 @@ func renderTemplate(ctx *app.Context) {
 	}
 }
 
+func migrate(ctx *app.Context) {
+	var dryRun bool
+	ctx.ParseParamValue("dry-run", &dryRun)
+	opts := &migrations.Options{}
+	if dryRun {
+		opts.DryRun = os.Stdout
+	}
+	o := ctx.Orm().Orm
+	switch cmd := ctx.IndexValue(0); cmd {
+	case "":
+		var to int64
+		ctx.ParseParamValue("to", &to)
+		applied, err := migrations.Migrate(o, to, opts)
+		for _, v := range applied {
+			log.Infof("applied migration %s", v)
+		}
+		if err != nil {
+			panic(err)
+		}
+		if len(applied) == 0 {
+			log.Infof("no pending migrations")
+		}
+	case "status":
+		statuses, err := migrations.Status(o, opts)
+		if err != nil {
+			panic(err)
+		}
+		for _, v := range statuses {
+			status := "pending"
+			if !v.Applied.IsZero() {
+				status = "applied " + v.Applied.Format("2006-01-02 15:04:05")
+			}
+			if v.Migration == nil {
+				status += " (not registered)"
+			}
+			fmt.Printf("%d\t%s\t%s\n", v.Version, v.Name, status)
+		}
+	case "rollback":
+		steps := 1
+		ctx.ParseParamValue("steps", &steps)
+		reverted, err := migrations.Rollback(o, steps, opts)
+		for _, v := range reverted {
+			log.Infof("rolled back migration %s", v)
+		}
+		if err != nil {
+			panic(err)
+		}
+	default:
+		UsageErrorf("unknown migrate command %q", cmd)
+	}
+}
+
 func init() {
 	Register(catFile, &Options{
 		Help:  "Prints a file from the blobstore to the stdout",
@@ -116,6 +169,15 @@ func init() {
 	Register(makeAssets, &Options{
 		Help: "Pre-compile and bundle all app assets",
 	})
+	Register(migrate, &Options{
+		Help:  "Apply, roll back or list the migrations registered with gnd.la/orm/migrations",
+		Usage: "[status|rollback]",
+		Flags: Flags(
+			BoolFlag("dry-run", false, "Print the statements the migrations would execute rather than running them"),
+			IntFlag("to", 0, "Apply the pending migrations up to this version. If zero, apply all of them"),
+			IntFlag("steps", 1, "Number of migrations to roll back"),
+		),
+	})
 	Register(printResources, &Options{Name: "_print-resources"})
 	Register(renderTemplate, &Options{
 		Name:  "_render-template",
@@ -4,16 +4,28 @@
 // Any registered codec can be used by both gnd.la/cache and
 // gnd.la/orm.
 //
-// This package provides the "gob" and "json" codecs, which encode
-// the data using encoding/gob and encoding/json, respectivelly.
+// This package provides the "gob", "json" and "xml" codecs, which encode
+// the data using encoding/gob, encoding/json and encoding/xml, respectivelly,
+// as well as the "csv" codec, which encodes structs and slices of structs
+// as CSV (using the field names as the header).
 // Check gnd.la/cache and gnd.la/orm to learn how to use codecs with
 // Gondola's cache and ORM.
 //
+// Codecs with a MediaType are also used for content negotiation by
+// gnd.la/app.NegotiatedHandler, so registering a new codec with a
+// MediaType makes it available as a response format.
+//
 // Users might define their own codecs by implementing a Codec
 // struct and registering it with Register().
 package codec
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+
 	"gnd.la/util/structs"
 )
 
@@ -37,6 +49,34 @@ type Codec struct {
 	Decode func(data []byte, v interface{}) error
 	// Binary indicates if the codec returns binary or text data
 	Binary bool
+	// MediaType is the MIME type of the encoded data (e.g.
+	// application/json). It's optional, but only codecs
+	// with a MediaType are used for content negotiation.
+	MediaType string
+}
+
+// UnsupportedTypeError is returned by the Encode function of a
+// Codec when it can't encode values of the given type (e.g. the
+// "csv" codec with a map).
+type UnsupportedTypeError struct {
+	Codec string
+	Type  reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("can't encode %s as %s", e.Type, e.Codec)
+}
+
+// IsUnsupportedType returns true iff err indicates that a Codec
+// can't encode a value because of its type. Besides *UnsupportedTypeError,
+// it also recognizes the errors returned by encoding/json and encoding/xml
+// for unsupported types.
+func IsUnsupportedType(err error) bool {
+	switch err.(type) {
+	case *UnsupportedTypeError, *json.UnsupportedTypeError, *xml.UnsupportedTypeError:
+		return true
+	}
+	return false
 }
 
 // Register registers a codec to be made available for
@@ -54,6 +94,17 @@ func Get(name string) *Codec {
 	return codecs[name]
 }
 
+// Names returns the names of the registered codecs, sorted
+// alphabetically.
+func Names() []string {
+	names := make([]string, 0, len(codecs))
+	for k := range codecs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // FromTag returns the pipe for a given field tag.
 func FromTag(t *structs.Tag) *Codec {
 	return codecs[t.CodecName()]
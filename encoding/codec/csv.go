@@ -0,0 +1,201 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"gnd.la/util/structs"
+	"gnd.la/util/types"
+)
+
+var (
+	csvCodec     = &Codec{Encode: csvMarshal, Decode: csvUnmarshal, MediaType: "text/csv"}
+	csvTags      = []string{"csv"}
+	stringsSlice = reflect.TypeOf([][]string(nil))
+)
+
+// csvMarshal encodes a [][]string, a struct or a slice of structs
+// as CSV. Structs are encoded with a header, which contains the names
+// of their fields (those can be changed with the "csv" struct tag).
+func csvMarshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if rows, ok := v.([][]string); ok {
+		if err := w.WriteAll(rows); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	var items []reflect.Value
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		for ii := 0; ii < val.Len(); ii++ {
+			items = append(items, val.Index(ii))
+		}
+	case reflect.Struct:
+		items = append(items, val)
+	default:
+		return nil, &UnsupportedTypeError{Codec: "CSV", Type: reflect.TypeOf(v)}
+	}
+	typ := val.Type()
+	if val.Kind() != reflect.Struct {
+		typ = typ.Elem()
+	}
+	s, err := structs.NewStruct(typ, csvTags)
+	if err != nil {
+		if err == structs.ErrNoStruct {
+			// Slice of non-structs
+			return nil, &UnsupportedTypeError{Codec: "CSV", Type: reflect.TypeOf(v)}
+		}
+		return nil, fmt.Errorf("can't encode %T as CSV: %s", v, err)
+	}
+	w.Write(s.MNames)
+	row := make([]string, len(s.Indexes))
+	for _, item := range items {
+		for item.Kind() == reflect.Ptr && !item.IsNil() {
+			item = item.Elem()
+		}
+		if item.Kind() != reflect.Struct {
+			// nil pointer
+			continue
+		}
+		for ii, idx := range s.Indexes {
+			row[ii] = ""
+			if f := csvField(item, idx, false); f.IsValid() {
+				row[ii] = types.ToString(f.Interface())
+			}
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvUnmarshal decodes CSV data into a *[][]string or into a pointer
+// to a slice of structs. In the latter case, the first row must be a
+// header with the field names, as written by csvMarshal.
+func csvUnmarshal(data []byte, v interface{}) error {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return err
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return fmt.Errorf("can't decode CSV into %T - must be a non-nil pointer", v)
+	}
+	val = val.Elem()
+	if val.Type() == stringsSlice {
+		val.Set(reflect.ValueOf(rows))
+		return nil
+	}
+	if val.Kind() != reflect.Slice {
+		return fmt.Errorf("can't decode CSV into %T", v)
+	}
+	elem := val.Type().Elem()
+	s, err := structs.NewStruct(elem, csvTags)
+	if err != nil {
+		return fmt.Errorf("can't decode CSV into %T: %s", v, err)
+	}
+	val.Set(reflect.MakeSlice(val.Type(), 0, len(rows)))
+	if len(rows) == 0 {
+		return nil
+	}
+	header := rows[0]
+	for _, row := range rows[1:] {
+		item := reflect.New(s.Type)
+		for ii, value := range row {
+			if ii >= len(header) {
+				break
+			}
+			n, ok := s.MNameMap[header[ii]]
+			if !ok {
+				continue
+			}
+			if err := csvSet(csvField(item.Elem(), s.Indexes[n], true), value); err != nil {
+				return fmt.Errorf("error decoding CSV field %s: %s", header[ii], err)
+			}
+		}
+		if elem.Kind() != reflect.Ptr {
+			item = item.Elem()
+		}
+		val.Set(reflect.Append(val, item))
+	}
+	return nil
+}
+
+// csvField returns the field at the given index. If alloc is
+// false and it finds a nil pointer, it returns an invalid Value.
+func csvField(val reflect.Value, index []int, alloc bool) reflect.Value {
+	for _, idx := range index {
+		for val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		val = val.Field(idx)
+	}
+	return val
+}
+
+func csvSet(val reflect.Value, value string) error {
+	if val.Kind() == reflect.Ptr {
+		if value == "" {
+			return nil
+		}
+		val.Set(reflect.New(val.Type().Elem()))
+		val = val.Elem()
+	}
+	if value == "" {
+		val.Set(reflect.Zero(val.Type()))
+		return nil
+	}
+	switch types.Kind(val.Kind()) {
+	case types.Int:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		val.SetInt(i)
+	case types.Uint:
+		u, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		val.SetUint(u)
+	case types.Float:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f)
+	case types.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		val.SetBool(b)
+	case types.String:
+		val.SetString(value)
+	default:
+		return fmt.Errorf("can't decode into %s", val.Type())
+	}
+	return nil
+}
+
+func init() {
+	Register("csv", csvCodec)
+}
@@ -0,0 +1,60 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+)
+
+type csvAddress struct {
+	City string
+}
+
+type csvItem struct {
+	Id      int64   `csv:"id"`
+	Name    string  `csv:"name"`
+	Score   float64 `csv:"score"`
+	Active  bool    `csv:"active"`
+	Address *csvAddress
+}
+
+func TestCSV(t *testing.T) {
+	items := []*csvItem{
+		{Id: 1, Name: "foo, bar", Score: 1.5, Active: true, Address: &csvAddress{City: "Madrid"}},
+		{Id: 2, Name: "baz"},
+	}
+	data, err := csvCodec.Encode(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "id,name,score,active,address_city\n1,\"foo, bar\",1.5,true,Madrid\n2,baz,0,false,\n"
+	if string(data) != expected {
+		t.Errorf("expecting CSV %q, got %q", expected, string(data))
+	}
+	var decoded []*csvItem
+	if err := csvCodec.Decode(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	// Nil pointers are allocated when decoding
+	items[1].Address = &csvAddress{}
+	if !reflect.DeepEqual(items, decoded) {
+		t.Errorf("expecting %+v, got %+v", items, decoded)
+	}
+	var rows [][]string
+	if err := csvCodec.Decode(data, &rows); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1][1] != "foo, bar" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+	if _, err := csvCodec.Encode(1); err == nil {
+		t.Error("expecting an error when encoding an int as CSV")
+	}
+}
+
+func TestCSVUnsupported(t *testing.T) {
+	for _, v := range []interface{}{map[string]int{"foo": 1}, []int{1, 2}, 3} {
+		if _, err := csvCodec.Encode(v); !IsUnsupportedType(err) {
+			t.Errorf("expecting an unsupported type error when encoding %T, got %v", v, err)
+		}
+	}
+}
@@ -5,7 +5,7 @@ import (
 )
 
 var (
-	jsonCodec = &Codec{Encode: json.Marshal, Decode: json.Unmarshal}
+	jsonCodec = &Codec{Encode: json.Marshal, Decode: json.Unmarshal, MediaType: "application/json"}
 )
 
 func init() {
@@ -0,0 +1,13 @@
+package codec
+
+import (
+	"encoding/xml"
+)
+
+var (
+	xmlCodec = &Codec{Encode: xml.Marshal, Decode: xml.Unmarshal, MediaType: "application/xml"}
+)
+
+func init() {
+	Register("xml", xmlCodec)
+}
@@ -23,6 +23,12 @@ type Field struct {
 	sval   reflect.Value
 	pos    int
 	err    error
+	// elem is the struct for the elements of FIELDSET fields
+	elem     *structs.Struct
+	elements [][]*Field
+	// extra is true for the fields in the empty elements
+	// rendered by the extra option of a FIELDSET
+	extra bool
 }
 
 func (f *Field) String() string {
@@ -55,3 +61,24 @@ func (f *Field) HasAddOns() bool {
 func (f *Field) Err() error {
 	return f.err
 }
+
+// Multiple returns true iff the field accepts multiple values,
+// which happens for SELECT and CHECKBOXES fields backed by a slice.
+func (f *Field) Multiple() bool {
+	return f.Type.HasChoices() && f.s.Types[f.pos].Kind() == reflect.Slice
+}
+
+// Elements returns the fields for each element in a FIELDSET field,
+// in order. For fields with any other type, it returns nil.
+func (f *Field) Elements() [][]*Field {
+	return f.elements
+}
+
+func (f *Field) setPrefix(prefix string) {
+	f.prefix = prefix
+	for _, e := range f.elements {
+		for _, v := range e {
+			v.setPrefix(prefix)
+		}
+	}
+}
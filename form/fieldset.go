@@ -0,0 +1,294 @@
+package form
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gnd.la/form/input"
+	"gnd.la/html"
+	"gnd.la/i18n"
+	"gnd.la/util/structs"
+)
+
+const (
+	// Same value used by net/http
+	defaultMaxMemory = 32 << 20
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// elemStructType returns the struct type for the elements
+// of the given slice type, or nil if its elements are not
+// structs (or pointers to structs).
+func elemStructType(typ reflect.Type) reflect.Type {
+	elem := typ.Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() == reflect.Struct && elem != timeType {
+		return elem
+	}
+	return nil
+}
+
+// elemValue returns the struct value for the given slice
+// element, allocating it if it's a nil pointer.
+func elemValue(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// elementNames returns the name and the HTML name for the
+// field named qname in the element at the given index.
+func (f *Form) elementNames(field *Field, index int, qname string) (string, string) {
+	name := fmt.Sprintf("%s.%d.%s", field.Name, index, qname)
+	htmlName := fmt.Sprintf("%s.%d.%s", field.HTMLName, index, f.toHTMLName(qname))
+	return name, htmlName
+}
+
+// makeElements creates the fields for each element
+// in the slice backing the given FIELDSET field.
+func (f *Form) makeElements(field *Field) error {
+	field.elements = nil
+	for ii := 0; ii < field.value.Len(); ii++ {
+		fields, err := f.makeElement(field, elemValue(field.value.Index(ii)), ii, field.extra)
+		if err != nil {
+			return err
+		}
+		field.elements = append(field.elements, fields)
+	}
+	return nil
+}
+
+// makeElement creates the fields for the element of a FIELDSET
+// field with the given value, using index in their names. If extra
+// is true, the element is an empty one added by the extra option.
+func (f *Form) makeElement(field *Field, sval reflect.Value, index int, extra bool) ([]*Field, error) {
+	var fields []*Field
+	for ii, qname := range field.elem.QNames {
+		name, htmlName := f.elementNames(field, index, qname)
+		sub, err := f.newField(field.elem, ii, sval, name, htmlName)
+		if err != nil {
+			return nil, err
+		}
+		if sub != nil {
+			sub.setPrefix(field.prefix)
+			sub.extra = extra
+			fields = append(fields, sub)
+		}
+	}
+	return fields, nil
+}
+
+// renameElements updates the names of the fields in the elements
+// of the given FIELDSET field to match their positions.
+func (f *Form) renameElements(field *Field) {
+	for ii, e := range field.elements {
+		for _, v := range e {
+			v.Name, v.HTMLName = f.elementNames(field, ii, v.s.QNames[v.pos])
+			v.id = toHTMLId(v.HTMLName)
+			if v.Type == FIELDSET {
+				f.renameElements(v)
+			}
+		}
+	}
+}
+
+func (f *Form) parseForm() {
+	if f.ctx.R.Form == nil {
+		// Parses the non-multipart forms too
+		f.ctx.R.ParseMultipartForm(defaultMaxMemory)
+	}
+}
+
+// fieldsetIndexes returns the indexes of the elements submitted for
+// the given FIELDSET field, in increasing order. Elements without any
+// non-empty value (e.g. an unused extra element) are ignored.
+func (f *Form) fieldsetIndexes(field *Field) []int {
+	f.parseForm()
+	prefix := field.HTMLName + "."
+	elements := make(map[int]bool)
+	add := func(key string, nonEmpty bool) {
+		if !strings.HasPrefix(key, prefix) {
+			return
+		}
+		rest := key[len(prefix):]
+		p := strings.IndexByte(rest, '.')
+		if p < 0 {
+			return
+		}
+		if idx, err := strconv.Atoi(rest[:p]); err == nil && idx >= 0 {
+			elements[idx] = elements[idx] || nonEmpty
+		}
+	}
+	for k, v := range f.ctx.R.Form {
+		nonEmpty := false
+		for _, s := range v {
+			if strings.TrimSpace(s) != "" {
+				nonEmpty = true
+				break
+			}
+		}
+		add(k, nonEmpty)
+	}
+	if mf := f.ctx.R.MultipartForm; mf != nil {
+		for k, v := range mf.File {
+			add(k, len(v) > 0)
+		}
+	}
+	var indexes []int
+	for k, v := range elements {
+		if v {
+			indexes = append(indexes, k)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// validateFieldset parses and validates the submitted elements for the
+// given FIELDSET field. Each element field stores its own error, while
+// errors which affect the whole field are stored in the FIELDSET.
+func (f *Form) validateFieldset(field *Field) {
+	indexes := f.fieldsetIndexes(field)
+	slice := reflect.MakeSlice(field.value.Type(), len(indexes), len(indexes))
+	field.value.Set(slice)
+	field.elements = nil
+	for ii, idx := range indexes {
+		fields, err := f.makeElement(field, elemValue(slice.Index(ii)), idx, field.extra)
+		if err != nil {
+			// Can't happen, the same fields were created with the form
+			panic(err)
+		}
+		for _, v := range fields {
+			f.validateField(v)
+		}
+		field.elements = append(field.elements, fields)
+	}
+	f.renameElements(field)
+	if len(indexes) == 0 && field.Tag().Required() {
+		label := field.Label.TranslatedString(f.ctx)
+		if f.NamelessErrors {
+			label = ""
+		}
+		field.err = i18n.TranslatedError(input.RequiredInputError(label), f.ctx)
+		return
+	}
+	if err := structs.Validate(field.sval.Addr().Interface(), field.s.QNames[field.pos], f.ctx); err != nil {
+		field.err = i18n.TranslatedError(err, f.ctx)
+	}
+}
+
+// parseMultiple parses the values for a SELECT or CHECKBOXES
+// field backed by a slice.
+func (f *Form) parseMultiple(field *Field, label string) error {
+	f.parseForm()
+	values := f.ctx.R.Form[field.HTMLName]
+	choices := f.fieldChoices(field)
+	typ := field.value.Type()
+	result := reflect.MakeSlice(typ, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || v == NotChosen {
+			continue
+		}
+		if !hasChoice(choices, v) {
+			return i18n.Errorfc("form", "%v is not a valid choice", v).Err(f.ctx)
+		}
+		elem := reflect.New(typ.Elem())
+		if err := input.Parse(v, elem.Interface()); err != nil {
+			return i18n.TranslatedError(err, f.ctx)
+		}
+		result = reflect.Append(result, elem.Elem())
+	}
+	tag := field.Tag()
+	if result.Len() == 0 && (tag.Required() || !tag.Optional()) {
+		return i18n.TranslatedError(input.RequiredInputError(label), f.ctx)
+	}
+	field.value.Set(result)
+	return nil
+}
+
+// hasChoice returns true iff value matches the
+// value of any of the given choices.
+func hasChoice(choices []*Choice, value string) bool {
+	for _, c := range choices {
+		if value == toHTMLValue(c.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isChosen returns true iff the given choice value is the value of
+// the field or, for fields with multiple values, one of them.
+func isChosen(field *Field, value interface{}) bool {
+	if field.Multiple() {
+		for ii := 0; ii < field.value.Len(); ii++ {
+			if reflect.DeepEqual(value, field.value.Index(ii).Interface()) {
+				return true
+			}
+		}
+		return false
+	}
+	return reflect.DeepEqual(value, field.Value())
+}
+
+// renderFieldset renders the elements of a FIELDSET field. If the field
+// has the extra option, that number of empty elements is also rendered.
+func (f *Form) renderFieldset(buf *bytes.Buffer, field *Field) error {
+	elements := field.elements
+	if extra, ok := field.Tag().IntValue("extra"); ok {
+		for ii := 0; ii < extra; ii++ {
+			fields, err := f.makeElement(field, reflect.New(field.elem.Type).Elem(), len(elements), true)
+			if err != nil {
+				return err
+			}
+			elements = append(elements, fields)
+		}
+	}
+	legend := field.Label.TranslatedString(f.ctx)
+	fr, _ := f.renderer.(FieldsetRenderer)
+	for ii, e := range elements {
+		if fr != nil {
+			if err := fr.BeginFieldset(buf, field, legend, ii); err != nil {
+				return err
+			}
+		} else {
+			f.openTag(buf, "fieldset", html.Attrs{"id": fmt.Sprintf("%s_%d", field.Id(), ii)})
+			f.openTag(buf, "legend", nil)
+			buf.WriteString(html.Escape(legend))
+			f.closeTag(buf, "legend")
+		}
+		for _, v := range e {
+			if err := f.renderField(buf, v); err != nil {
+				return err
+			}
+		}
+		if fr != nil {
+			if err := fr.EndFieldset(buf, field, ii); err != nil {
+				return err
+			}
+		} else {
+			f.closeTag(buf, "fieldset")
+		}
+	}
+	if err := field.Err(); err != nil && f.renderer != nil {
+		if err := f.renderer.WriteError(buf, field, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package form
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gnd.la/app"
+	"gnd.la/html"
+)
+
+type fieldsetAddress struct {
+	Street string `form:",singleline"`
+	City   string `form:",singleline,optional"`
+}
+
+type fieldsetUser struct {
+	Name      string   `form:",singleline"`
+	Tags      []string `form:",checkboxes,optional"`
+	Languages []int    `form:",select"`
+	Home      fieldsetAddress
+	Addresses []*fieldsetAddress `form:",extra=1"`
+}
+
+func (u *fieldsetUser) FieldChoices(ctx *app.Context, field *Field) []*Choice {
+	switch field.Name {
+	case "Tags":
+		return []*Choice{{Name: "A", Value: "a"}, {Name: "B", Value: "b"}, {Name: "C", Value: "c"}}
+	case "Languages":
+		return []*Choice{{Name: "Go", Value: 1}, {Name: "C", Value: 2}, {Name: "Python", Value: 3}}
+	}
+	return nil
+}
+
+// errorsRenderer records the fields with errors
+type errorsRenderer struct {
+	errors []string
+}
+
+func (r *errorsRenderer) BeginField(w io.Writer, field *Field) error { return nil }
+func (r *errorsRenderer) BeginLabel(w io.Writer, field *Field, label string, pos int) error {
+	return nil
+}
+func (r *errorsRenderer) LabelAttributes(field *Field, pos int) (html.Attrs, error) { return nil, nil }
+func (r *errorsRenderer) EndLabel(w io.Writer, field *Field, pos int) error         { return nil }
+func (r *errorsRenderer) BeginInput(w io.Writer, field *Field, placeholder string, pos int) error {
+	return nil
+}
+func (r *errorsRenderer) FieldAttributes(field *Field, pos int) (html.Attrs, error) { return nil, nil }
+func (r *errorsRenderer) EndInput(w io.Writer, field *Field, pos int) error         { return nil }
+func (r *errorsRenderer) WriteAddOn(w io.Writer, field *Field, addon *AddOn) error  { return nil }
+func (r *errorsRenderer) WriteError(w io.Writer, field *Field, err error) error {
+	r.errors = append(r.errors, field.Name)
+	return nil
+}
+func (r *errorsRenderer) WriteHelp(w io.Writer, field *Field, help string) error { return nil }
+func (r *errorsRenderer) EndField(w io.Writer, field *Field) error               { return nil }
+
+func fieldsetForm(t *testing.T, method string, values url.Values, obj interface{}, r Renderer, fn func(*Form)) {
+	a := app.New()
+	a.Handle("^/$", func(ctx *app.Context) {
+		f := NewOpts(ctx, &Options{Renderer: r}, obj)
+		f.DisableCSRF = true
+		fn(f)
+	})
+	var body string
+	if values != nil {
+		body = values.Encode()
+	}
+	req, _ := http.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	a.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFieldsetRender(t *testing.T) {
+	user := &fieldsetUser{
+		Tags:      []string{"b"},
+		Languages: []int{1, 3},
+		Addresses: []*fieldsetAddress{{Street: "Main"}},
+	}
+	fieldsetForm(t, "GET", nil, user, nil, func(f *Form) {
+		html, err := f.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(html)
+		for _, v := range []string{
+			`name="home.street"`,
+			`name="addresses.0.street"`,
+			`value="Main"`,
+			// Extra element
+			`name="addresses.1.street"`,
+			`<select `,
+			`multiple="multiple"`,
+		} {
+			if !strings.Contains(s, v) {
+				t.Errorf("expecting %s in rendered form %s", v, s)
+			}
+		}
+		if strings.Contains(s, `name="addresses.2.street"`) {
+			t.Errorf("unexpected third element in rendered form %s", s)
+		}
+		if tag := inputTag(s, "addresses.0.street"); !strings.Contains(tag, `required="required"`) {
+			t.Errorf("expecting required in %s", tag)
+		}
+		// The extra element might be left empty
+		if tag := inputTag(s, "addresses.1.street"); strings.Contains(tag, "required") {
+			t.Errorf("unexpected required in extra element %s", tag)
+		}
+		if n := strings.Count(s, `checked="checked"`); n != 1 {
+			t.Errorf("expecting 1 checked checkbox, got %d", n)
+		}
+		if n := strings.Count(s, `selected="selected"`); n != 2 {
+			t.Errorf("expecting 2 selected options, got %d", n)
+		}
+	})
+}
+
+func TestFieldsetParse(t *testing.T) {
+	values := url.Values{
+		"name":                []string{"Alice"},
+		"tags":                []string{"a", "c"},
+		"languages":           []string{"1", "3"},
+		"home.street":         []string{"Home"},
+		"addresses.0.street":  []string{"First"},
+		"addresses.0.city":    []string{"Madrid"},
+		"addresses.3.street":  []string{"Second"},
+		"addresses.4.street":  []string{""},
+		"addresses.4.city":    []string{""},
+		"addresses.x.street":  []string{"Invalid"},
+		"addresses.10.street": []string{"Third"},
+	}
+	user := &fieldsetUser{}
+	fieldsetForm(t, "POST", values, user, nil, func(f *Form) {
+		if !f.IsValid() {
+			t.Errorf("expecting valid form, got errors in %v", f.Fields())
+		}
+		field, err := f.FieldByName("Addresses")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(field.Elements()); n != 3 {
+			t.Errorf("expecting 3 elements, got %d", n)
+		}
+		if _, err := f.FieldByName("Addresses.2.Street"); err != nil {
+			t.Error(err)
+		}
+	})
+	expected := &fieldsetUser{
+		Name:      "Alice",
+		Tags:      []string{"a", "c"},
+		Languages: []int{1, 3},
+		Home:      fieldsetAddress{Street: "Home"},
+		Addresses: []*fieldsetAddress{{Street: "First", City: "Madrid"}, {Street: "Second"}, {Street: "Third"}},
+	}
+	if !reflect.DeepEqual(user, expected) {
+		t.Errorf("expecting %+v, got %+v", expected, user)
+	}
+}
+
+func TestFieldsetErrors(t *testing.T) {
+	values := url.Values{
+		"name":               []string{"Bob"},
+		"tags":               []string{"z"},
+		"home.street":        []string{"Home"},
+		"addresses.0.street": []string{"First"},
+		"addresses.1.city":   []string{"Paris"},
+	}
+	r := &errorsRenderer{}
+	fieldsetForm(t, "POST", values, &fieldsetUser{}, r, func(f *Form) {
+		if f.IsValid() {
+			t.Fatal("expecting invalid form")
+		}
+		var errs []string
+		for _, v := range f.Fields() {
+			if v.Err() != nil {
+				errs = append(errs, v.Name)
+			}
+			for _, e := range v.Elements() {
+				for _, ef := range e {
+					if ef.Err() != nil {
+						errs = append(errs, ef.Name)
+					}
+				}
+			}
+		}
+		expected := []string{"Tags", "Languages", "Addresses.1.Street"}
+		if !reflect.DeepEqual(errs, expected) {
+			t.Errorf("expecting errors in %v, got %v", expected, errs)
+		}
+		html, err := f.RenderOnly("Addresses")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := string(html); !strings.Contains(s, `value="Paris"`) {
+			t.Errorf("expecting submitted values in rendered form %s", s)
+		}
+		if expected := []string{"Addresses.1.Street"}; !reflect.DeepEqual(r.errors, expected) {
+			t.Errorf("expecting rendered errors in %v, got %v", expected, r.errors)
+		}
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"html/template"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"gnd.la/app"
 	"gnd.la/crypto/password"
@@ -47,57 +49,68 @@ func (f *Form) validate() {
 		panic(err)
 	}
 	for _, v := range f.fields {
-		inp := f.ctx.FormValue(v.HTMLName)
-		label := v.Label.TranslatedString(f.ctx)
-		if f.NamelessErrors {
-			label = ""
-		}
-		if v.Type.HasChoices() {
-			if inp == NotChosen {
-				v.err = i18n.Errorfc("form", "You must choose a value").Err(f.ctx)
-				continue
-			}
-			// Verify that the input mathces one of the available choices
-			choices := f.fieldChoices(v)
-			found := false
-			for _, c := range choices {
-				if inp == toHTMLValue(c.Value) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				v.err = i18n.Errorfc("form", "%v is not a valid choice", inp).Err(f.ctx)
-				continue
-			}
+		f.validateField(v)
+	}
+}
+
+func (f *Form) validateField(v *Field) {
+	if v.Type == FIELDSET {
+		f.validateFieldset(v)
+		return
+	}
+	inp := f.ctx.FormValue(v.HTMLName)
+	label := v.Label.TranslatedString(f.ctx)
+	if f.NamelessErrors {
+		label = ""
+	}
+	if v.Multiple() {
+		if err := f.parseMultiple(v, label); err != nil {
+			v.err = err
+			return
 		}
-		if v.Type == FILE {
-			file, header, err := f.ctx.R.FormFile(v.HTMLName)
-			if err != nil && !v.Tag().Optional() {
-				v.err = input.RequiredInputError(label)
-				continue
-			}
-			if file != nil && header != nil {
-				value := File([]interface{}{file, header})
-				v.value.Set(reflect.ValueOf(value))
-			}
-		} else {
-			if err := input.InputNamed(label, inp, v.SettableValue(), v.Tag(), true); err != nil {
-				v.err = i18n.TranslatedError(err, f.ctx)
-				continue
-			}
+	} else if v.Type.HasChoices() {
+		if inp == NotChosen {
+			v.err = i18n.Errorfc("form", "You must choose a value").Err(f.ctx)
+			return
+		}
+		// Verify that the input mathces one of the available choices
+		if !hasChoice(f.fieldChoices(v), inp) {
+			v.err = i18n.Errorfc("form", "%v is not a valid choice", inp).Err(f.ctx)
+			return
+		}
+	}
+	if v.Type == FILE {
+		file, header, err := f.ctx.R.FormFile(v.HTMLName)
+		if err != nil && !v.Tag().Optional() {
+			v.err = input.RequiredInputError(label)
+			return
+		}
+		if file != nil && header != nil {
+			value := File([]interface{}{file, header})
+			v.value.Set(reflect.ValueOf(value))
 		}
-		if err := structs.Validate(v.sval.Addr().Interface(), v.Name, f.ctx); err != nil {
+	} else if !v.Multiple() {
+		if err := input.InputNamed(label, inp, v.SettableValue(), v.Tag(), true); err != nil {
 			v.err = i18n.TranslatedError(err, f.ctx)
-			continue
+			return
 		}
 	}
+	parent := v.sval
+	if idx := v.s.Indexes[v.pos]; len(idx) > 1 {
+		parent = fieldByIndex(v.sval, idx[:len(idx)-1])
+	}
+	if err := structs.ValidateRules(label, v.value, parent, v.Tag()); err != nil {
+		v.err = i18n.TranslatedError(err, f.ctx)
+		return
+	}
+	if err := structs.Validate(v.sval.Addr().Interface(), v.s.QNames[v.pos], f.ctx); err != nil {
+		v.err = i18n.TranslatedError(err, f.ctx)
+	}
 }
 
 func (f *Form) makeField(name string) (*Field, error) {
 	var s *structs.Struct
 	idx := -1
-	var fieldValue reflect.Value
 	var sval reflect.Value
 	for ii, v := range f.structs {
 		pos, ok := v.QNameMap[name]
@@ -108,29 +121,40 @@ func (f *Form) makeField(name string) (*Field, error) {
 			s = v
 			idx = pos
 			sval = f.values[ii]
-			fieldValue = fieldByIndex(sval, s.Indexes[pos])
-			// Check the validation function, so if the function is not valid
-			// the error is generated at form instantiation.
-			if _, err := structs.ValidationFunction(sval, name); err != nil {
-				return nil, err
-			}
 		}
 	}
 	if idx < 0 {
 		return nil, fmt.Errorf("can't map form field %q", name)
 	}
+	return f.newField(s, idx, sval, name, f.toHTMLName(name))
+}
+
+// newField returns the field at the given index in s, where sval is
+// the struct value. name and htmlName might differ from the field
+// name in s for fields inside a FIELDSET.
+func (f *Form) newField(s *structs.Struct, idx int, sval reflect.Value, name string, htmlName string) (*Field, error) {
+	qname := s.QNames[idx]
+	fieldValue := fieldByIndex(sval, s.Indexes[idx])
+	// Check the validation function, so if the function is not valid
+	// the error is generated at form instantiation.
+	if _, err := structs.ValidationFunction(sval, qname); err != nil {
+		return nil, err
+	}
 	tag := s.Tags[idx]
 	label := tag.Value("label")
 	if label == "" {
-		label = stringutil.CamelCaseToWords(name, " ")
+		label = stringutil.CamelCaseToWords(qname, " ")
 	}
 	var typ Type
+	var elem *structs.Struct
 	if tag.Has("hidden") {
 		typ = HIDDEN
 	} else if tag.Has("radio") {
 		typ = RADIO
 	} else if tag.Has("select") {
 		typ = SELECT
+	} else if tag.Has("checkboxes") {
+		typ = CHECKBOXES
 	} else {
 		switch s.Types[idx].Kind() {
 		case reflect.Func:
@@ -138,6 +162,8 @@ func (f *Form) makeField(name string) (*Field, error) {
 		case reflect.String:
 			if s.Types[idx] == reflect.TypeOf(password.Password("")) || tag.Has("password") {
 				typ = PASSWORD
+			} else if st := stringType(tag); st != 0 {
+				typ = st
 			} else {
 				if ml, ok := tag.MaxLength(); ok && ml > 0 {
 					typ = TEXT
@@ -154,23 +180,56 @@ func (f *Form) makeField(name string) (*Field, error) {
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
 			reflect.Float32, reflect.Float64:
-			typ = TEXT
-		default:
+			if tag.Has("range") {
+				typ = RANGE
+			} else if tag.Has("text") || tag.Has("singleline") {
+				typ = TEXT
+			} else {
+				typ = NUMBER
+			}
+		case reflect.Struct:
+			if s.Types[idx] != timeType {
+				return nil, fmt.Errorf("field %q has invalid type %v", name, s.Types[idx])
+			}
+			if tag.Has("date") {
+				typ = DATE
+			} else if tag.Has("time") {
+				typ = TIME
+			} else {
+				typ = DATETIME
+			}
+		case reflect.Slice:
 			if s.Types[idx] == fileType {
 				typ = FILE
 				break
 			}
+			if et := elemStructType(s.Types[idx]); et != nil {
+				var err error
+				if elem, err = structs.NewStruct(et, formTags); err != nil {
+					return nil, fmt.Errorf("field %q has invalid element type %v: %s", name, et, err)
+				}
+				typ = FIELDSET
+				break
+			}
+			return nil, fmt.Errorf("field %q of type %v requires either the select or the checkboxes option", name, s.Types[idx])
+		default:
 			return nil, fmt.Errorf("field %q has invalid type %v", name, s.Types[idx])
 		}
 	}
+	isSlice := s.Types[idx].Kind() == reflect.Slice
+	if typ == RADIO && isSlice {
+		return nil, fmt.Errorf("field %q of type %v can't use the radio option, use select or checkboxes", name, s.Types[idx])
+	}
+	if typ == CHECKBOXES && !isSlice {
+		return nil, fmt.Errorf("field %q of type %v can't use the checkboxes option, it requires a slice", name, s.Types[idx])
+	}
 	// Check if the struct implements the ChoicesProvider interface
-	if typ == RADIO || typ == SELECT {
+	if typ.HasChoices() {
 		container := sval.Addr().Interface()
 		if _, ok := container.(ChoicesProvider); !ok {
 			return nil, fmt.Errorf("field %q requires choices, but %T does not implement ChoicesProvider", name, container)
 		}
 	}
-	htmlName := f.toHTMLName(name)
 	field := &Field{
 		Type:        typ,
 		Name:        name,
@@ -178,22 +237,45 @@ func (f *Form) makeField(name string) (*Field, error) {
 		Label:       i18n.String(label),
 		Placeholder: i18n.String(tag.Value("placeholder")),
 		Help:        i18n.String(tag.Value("help")),
-		id:          htmlName,
+		id:          toHTMLId(htmlName),
 		value:       fieldValue,
 		s:           s,
 		sval:        sval,
 		pos:         idx,
+		elem:        elem,
+	}
+	if typ == FIELDSET {
+		if err := f.makeElements(field); err != nil {
+			return nil, err
+		}
 	}
 	return field, nil
 }
 
 func (f *Form) lookupField(name string) (*Field, error) {
-	for _, v := range f.fields {
+	if field := lookupField(f.fields, name); field != nil {
+		return field, nil
+	}
+	return nil, fmt.Errorf("form has no field named %q", name)
+}
+
+// lookupField finds the field with the given name in fields,
+// including the fields inside the elements of FIELDSET fields
+// (e.g. Addresses.0.City).
+func lookupField(fields []*Field, name string) *Field {
+	for _, v := range fields {
 		if v.Name == name {
-			return v, nil
+			return v
+		}
+		if v.Type == FIELDSET && strings.HasPrefix(name, v.Name+".") {
+			for _, e := range v.elements {
+				if field := lookupField(e, name); field != nil {
+					return field
+				}
+			}
 		}
 	}
-	return nil, fmt.Errorf("form has no field named %q", name)
+	return nil
 }
 
 func (f *Form) makeFields(names []string) error {
@@ -354,14 +436,8 @@ func (f *Form) writeField(buf *bytes.Buffer, field *Field) error {
 	}
 	var err error
 	switch field.Type {
-	case TEXT:
-		err = f.writeInput(buf, "text", field)
-	case PASSWORD:
-		err = f.writeInput(buf, "password", field)
-	case HIDDEN:
-		err = f.writeInput(buf, "hidden", field)
-	case FILE:
-		err = f.writeInput(buf, "file", field)
+	case TEXT, PASSWORD, HIDDEN, FILE, EMAIL, NUMBER, RANGE, DATE, DATETIME, TIME, URL, TEL, COLOR, SEARCH:
+		err = f.writeInput(buf, field.Type.InputType(), field)
 	case TEXTAREA:
 		attrs := html.Attrs{
 			"id":   field.Id(),
@@ -370,6 +446,7 @@ func (f *Form) writeField(buf *bytes.Buffer, field *Field) error {
 		if _, ok := field.Tag().IntValue("rows"); ok {
 			attrs["rows"] = field.Tag().Value("rows")
 		}
+		addValidationAttributes(field, attrs)
 		if err := f.prepareFieldAttributes(field, attrs, -1); err != nil {
 			return err
 		}
@@ -378,7 +455,11 @@ func (f *Form) writeField(buf *bytes.Buffer, field *Field) error {
 		f.closeTag(buf, "textarea")
 	case CHECKBOX:
 		err = f.writeInput(buf, "checkbox", field)
-	case RADIO:
+	case RADIO, CHECKBOXES:
+		itype := "radio"
+		if field.Type == CHECKBOXES {
+			itype = "checkbox"
+		}
 		for ii, v := range f.fieldChoices(field) {
 			var value interface{}
 			id := fmt.Sprintf("%s_%d", field.Id(), ii)
@@ -391,7 +472,7 @@ func (f *Form) writeField(buf *bytes.Buffer, field *Field) error {
 			attrs := html.Attrs{
 				"id":   id,
 				"name": field.HTMLName,
-				"type": "radio",
+				"type": itype,
 			}
 			if v.Value != nil {
 				attrs["value"] = toHTMLValue(v.Value)
@@ -399,9 +480,10 @@ func (f *Form) writeField(buf *bytes.Buffer, field *Field) error {
 			} else {
 				value = v.Name
 			}
-			if reflect.DeepEqual(value, field.Value()) {
+			if isChosen(field, value) {
 				attrs["checked"] = "checked"
 			}
+			addValidationAttributes(field, attrs)
 			if err := f.prepareFieldAttributes(field, attrs, ii); err != nil {
 				return err
 			}
@@ -418,9 +500,10 @@ func (f *Form) writeField(buf *bytes.Buffer, field *Field) error {
 			"id":   field.Id(),
 			"name": field.HTMLName,
 		}
-		if field.Tag().Has("multiple") {
+		if field.Multiple() || field.Tag().Has("multiple") {
 			attrs["multiple"] = "multiple"
 		}
+		addValidationAttributes(field, attrs)
 		if err := f.prepareFieldAttributes(field, attrs, -1); err != nil {
 			return err
 		}
@@ -434,7 +517,7 @@ func (f *Form) writeField(buf *bytes.Buffer, field *Field) error {
 			} else {
 				value = v.Name
 			}
-			if reflect.DeepEqual(value, field.Value()) {
+			if isChosen(field, value) {
 				oattrs["selected"] = "selected"
 			}
 			if err := f.prepareFieldAttributes(field, attrs, ii); err != nil {
@@ -494,6 +577,7 @@ func (f *Form) writeInput(buf *bytes.Buffer, itype string, field *Field) error {
 		"type": itype,
 		"name": field.HTMLName,
 	}
+	addValidationAttributes(field, attrs)
 	if err := f.prepareFieldAttributes(field, attrs, -1); err != nil {
 		return err
 	}
@@ -502,17 +586,12 @@ func (f *Form) writeInput(buf *bytes.Buffer, itype string, field *Field) error {
 		if t, ok := types.IsTrue(field.value.Interface()); t && ok {
 			attrs["checked"] = "checked"
 		}
-	case TEXT, PASSWORD, HIDDEN:
-		attrs["value"] = html.Escape(types.ToString(field.Value()))
+	case FILE:
+	default:
+		attrs["value"] = html.Escape(inputValue(field))
 		if field.Placeholder != "" {
 			attrs["placeholder"] = html.Escape(field.Placeholder.TranslatedString(f.ctx))
 		}
-		if ml, ok := field.Tag().MaxLength(); ok {
-			attrs["maxlength"] = strconv.Itoa(ml)
-		}
-	case FILE:
-	default:
-		panic("unreachable")
 	}
 	f.openTag(buf, "input", attrs)
 	if field.Type == CHECKBOX {
@@ -527,7 +606,71 @@ func (f *Form) writeInput(buf *bytes.Buffer, itype string, field *Field) error {
 	return nil
 }
 
+// inputValue returns the value attribute for
+// fields rendered as an <input>.
+func inputValue(field *Field) string {
+	if layout, ok := timeTypes[field.Type]; ok {
+		v := reflect.Indirect(field.value)
+		if v.IsValid() && v.Type() == timeType {
+			t := v.Interface().(time.Time)
+			if t.IsZero() {
+				return ""
+			}
+			// form/input parses values without a time zone as UTC
+			return t.UTC().Format(layout)
+		}
+	}
+	return types.ToString(field.Value())
+}
+
+// addValidationAttributes adds the HTML5 validation attributes
+// for the constraints in the field tag, so browsers can check
+// the input before submitting the form. Fields in extra FIELDSET
+// elements don't get any, since those elements might be left empty.
+func addValidationAttributes(field *Field, attrs html.Attrs) {
+	if field.extra {
+		return
+	}
+	switch field.Type {
+	case HIDDEN, CHECKBOX, CHECKBOXES, FIELDSET:
+		return
+	}
+	tag := field.Tag()
+	if field.Type != RANGE && field.Type != COLOR && (tag.Required() || !tag.Optional()) {
+		attrs["required"] = "required"
+	}
+	if field.Type.IsText() {
+		if ml, ok := tag.MaxLength(); ok {
+			attrs["maxlength"] = strconv.Itoa(ml)
+		}
+		if ml, ok := tag.MinLength(); ok {
+			attrs["minlength"] = strconv.Itoa(ml)
+		}
+		if pattern := tag.Value("pattern"); pattern != "" {
+			attrs["pattern"] = pattern
+		} else if tag.Alphanumeric() {
+			attrs["pattern"] = "[a-zA-Z0-9]+"
+		}
+	}
+	if field.Type == NUMBER || field.Type == RANGE {
+		if min := tag.Value("min"); min != "" {
+			attrs["min"] = min
+		}
+		if max := tag.Value("max"); max != "" {
+			attrs["max"] = max
+		}
+		if step := tag.Value("step"); step != "" {
+			attrs["step"] = step
+		} else if types.Kind(field.s.Types[field.pos].Kind()) == types.Float {
+			attrs["step"] = "any"
+		}
+	}
+}
+
 func (f *Form) renderField(buf *bytes.Buffer, field *Field) (err error) {
+	if field.Type == FIELDSET {
+		return f.renderFieldset(buf, field)
+	}
 	if provider, ok := field.sval.Addr().Interface().(AddOnProvider); ok {
 		field.addons = provider.FieldAddOns(f.ctx, field)
 	}
@@ -564,8 +707,31 @@ func (f *Form) renderField(buf *bytes.Buffer, field *Field) (err error) {
 	return
 }
 
+// stringType returns the Type selected by the options in
+// the given tag for a string field, or 0 if there's none.
+func stringType(tag *structs.Tag) Type {
+	for _, v := range stringTypes {
+		if tag.Has(v.option) {
+			return v.typ
+		}
+	}
+	return 0
+}
+
+// toHTMLName returns the name used in the HTML for the field
+// with the given name. Fields in nested structs are prefixed with
+// the nested field name (e.g. Address.City becomes address.city).
 func (f *Form) toHTMLName(name string) string {
-	return stringutil.CamelCaseToLower(name, "_")
+	parts := strings.Split(name, ".")
+	for ii, v := range parts {
+		parts[ii] = stringutil.CamelCaseToLower(v, "_")
+	}
+	return strings.Join(parts, ".")
+}
+
+// toHTMLId returns the id attribute for the given HTML name.
+func toHTMLId(htmlName string) string {
+	return strings.Replace(htmlName, ".", "_", -1)
 }
 
 func (f *Form) render(fields []*Field) (template.HTML, error) {
@@ -664,7 +830,7 @@ func (f *Form) SetId(id string) {
 	f.id = id
 	p := id + "_"
 	for _, v := range f.fields {
-		v.prefix = p
+		v.setPrefix(p)
 	}
 }
 
@@ -51,27 +51,85 @@ func InputNamed(name string, input string, out interface{}, tag *structs.Tag, re
 			return RequiredInputError(name)
 		}
 		if maxlen, ok := tag.MaxLength(); ok && len(input) > maxlen {
-			if name != "" {
-				return i18n.Errorfc("form", "%s is too long (maximum length is %d)", name, maxlen)
-			}
-			return i18n.Errorfc("form", "too long (maximum length is %d)", maxlen)
+			return tooLongError(name, maxlen)
 		}
 		if minlen, ok := tag.MinLength(); ok && len(input) < minlen {
-			if name != "" {
-				return i18n.Errorfc("form", "%s is too short (minimum length is %d)", name, minlen)
-			}
-			return i18n.Errorfc("form", "too short (minimum length is %d)", minlen)
+			return tooShortError(name, minlen)
 		}
 		if tag.Alphanumeric() && len(input) > 0 && !alphanumericRe.MatchString(input) {
-			if name != "" {
-				return i18n.Errorfc("form", "%s must be alphanumeric", name)
-			}
-			return i18n.Errorfc("form", "must be alphanumeric")
+			return alphanumericError(name)
 		}
 	}
 	return nil
 }
 
+// Check validates an already parsed value (e.g. decoded from JSON) using
+// the same constraints supported by InputNamed. Since there's no input string,
+// a value is considered empty when it's the zero value for its type, while
+// max_length and min_length apply to the length of strings, slices and maps.
+func Check(name string, v reflect.Value, tag *structs.Tag, required bool) error {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Bool || tag == nil {
+		return nil
+	}
+	if ((required && !tag.Optional()) || tag.Required()) && isZero(v) {
+		return RequiredInputError(name)
+	}
+	var length int
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		length = v.Len()
+	default:
+		return nil
+	}
+	if maxlen, ok := tag.MaxLength(); ok && length > maxlen {
+		return tooLongError(name, maxlen)
+	}
+	if minlen, ok := tag.MinLength(); ok && length < minlen {
+		return tooShortError(name, minlen)
+	}
+	if v.Kind() == reflect.String && tag.Alphanumeric() && length > 0 && !alphanumericRe.MatchString(v.String()) {
+		return alphanumericError(name)
+	}
+	return nil
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+	}
+	t, _ := types.IsTrueVal(v)
+	return !t
+}
+
+func tooLongError(name string, maxlen int) error {
+	if name != "" {
+		return i18n.Errorfc("form", "%s is too long (maximum length is %d)", name, maxlen)
+	}
+	return i18n.Errorfc("form", "too long (maximum length is %d)", maxlen)
+}
+
+func tooShortError(name string, minlen int) error {
+	if name != "" {
+		return i18n.Errorfc("form", "%s is too short (minimum length is %d)", name, minlen)
+	}
+	return i18n.Errorfc("form", "too short (minimum length is %d)", minlen)
+}
+
+func alphanumericError(name string) error {
+	if name != "" {
+		return i18n.Errorfc("form", "%s must be alphanumeric", name)
+	}
+	return i18n.Errorfc("form", "must be alphanumeric")
+}
+
 // Input is a shorthand for InputNamed("", ...).
 func Input(input string, out interface{}, tag *structs.Tag, required bool) error {
 	return InputNamed("", input, out, tag, required)
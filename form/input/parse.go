@@ -8,10 +8,23 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
 	parserInterface = reflect.TypeOf((*Parser)(nil)).Elem()
+	timeType        = reflect.TypeOf(time.Time{})
+	// timeLayouts are the layouts accepted for time.Time values,
+	// which include the ones used by the HTML5 datetime-local,
+	// date and time inputs.
+	timeLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02",
+		"15:04:05",
+		"15:04",
+	}
 )
 
 // Parser is the interface implemented by types
@@ -27,9 +40,14 @@ type Parser interface {
 //     Parse("27.5", &f)
 //     var width uint
 //     Parse("57", &width)
-// Supported types are: string, bool, u?int(8|16|32|64)? and float(32|64). If
-// the parsed value would overflow the given type, the maximum value
-// (or minimum, if it's negative) for the type will be set.
+// Supported types are: string, bool, u?int(8|16|32|64)?, float(32|64) and
+// time.Time. If the parsed value would overflow the given type, the maximum value
+// (or minimum, if it's negative) for the type will be set. Times are parsed
+// using RFC 3339 or the formats used by the HTML5 datetime-local, date and
+// time inputs (e.g. 2006-01-02T15:04, 2006-01-02 or 15:04). Times without
+// a timezone (which includes all the HTML5 formats) are interpreted as UTC,
+// so gnd.la/form also renders time.Time values in UTC, to make them round
+// trip.
 // If arg implements the Parser interface, its Parse method will
 // be used instead.
 func Parse(val string, arg interface{}) error {
@@ -65,6 +83,15 @@ func parse(val string, v reflect.Value) error {
 		v.Set(reflect.Zero(v.Type()))
 		return nil
 	}
+	if v.Type() == timeType {
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, val); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return i18n.Errorf("invalid date or time %q", val)
+	}
 	switch v.Type().Kind() {
 	case reflect.Bool:
 		res := false
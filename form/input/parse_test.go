@@ -3,6 +3,7 @@ package input
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 type ParseCase struct {
@@ -24,6 +25,10 @@ func TestParse(t *testing.T) {
 		{"2000", reflect.TypeOf(int8(0)), int8(127)},
 		{"56.950000", reflect.TypeOf(float64(0)), 56.95},
 		{"foo", reflect.TypeOf("bar"), "foo"},
+		{"2015-03-21", reflect.TypeOf(time.Time{}), time.Date(2015, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"2015-03-21T17:30", reflect.TypeOf(time.Time{}), time.Date(2015, 3, 21, 17, 30, 0, 0, time.UTC)},
+		{"17:30:15", reflect.TypeOf(time.Time{}), time.Date(0, 1, 1, 17, 30, 15, 0, time.UTC)},
+		{"", reflect.TypeOf(time.Time{}), time.Time{}},
 	}
 	for _, v := range cases {
 		val := reflect.New(v.Type)
@@ -53,6 +53,24 @@ type Renderer interface {
 	EndField(w io.Writer, field *Field) error
 }
 
+// FieldsetRenderer might be optionally implemented by a Renderer
+// to customize how the elements of FIELDSET fields (slices of structs)
+// are rendered. If the Renderer doesn't implement it, each element is
+// wrapped in a <fieldset> with a <legend> containing the field label.
+// The fields inside each element are rendered using the Renderer
+// interface, like the rest of the fields, so their errors are reported
+// per element. Errors affecting the whole FIELDSET field are reported
+// with WriteError, after all the elements have been rendered.
+type FieldsetRenderer interface {
+	// BeginFieldset is called before rendering the fields of each element,
+	// where pos is the element position. Renderers should use the provided
+	// legend string rather than the Label field on the Field struct, because
+	// the former is already translated.
+	BeginFieldset(w io.Writer, field *Field, legend string, pos int) error
+	// EndFieldset is called after rendering the fields of each element.
+	EndFieldset(w io.Writer, field *Field, pos int) error
+}
+
 var (
 	rendererFunc func() Renderer
 )
@@ -19,11 +19,99 @@ const (
 	SELECT
 	// <input type="file">
 	FILE
+	// Multiple <input type="checkbox"> with the same name,
+	// used for slices of choices.
+	CHECKBOXES
+	// A <fieldset> for each element in a slice of structs
+	FIELDSET
+	// <input type="email">
+	EMAIL
+	// <input type="number">
+	NUMBER
+	// <input type="range">
+	RANGE
+	// <input type="date">
+	DATE
+	// <input type="datetime-local">. Since the input has no
+	// time zone, values are rendered and parsed in UTC, so
+	// users see and enter UTC times.
+	DATETIME
+	// <input type="time">. Like DATETIME, the value is
+	// rendered and parsed in UTC.
+	TIME
+	// <input type="url">
+	URL
+	// <input type="tel">
+	TEL
+	// <input type="color">
+	COLOR
+	// <input type="search">
+	SEARCH
+)
+
+var (
+	inputTypes = map[Type]string{
+		TEXT:     "text",
+		PASSWORD: "password",
+		HIDDEN:   "hidden",
+		CHECKBOX: "checkbox",
+		FILE:     "file",
+		EMAIL:    "email",
+		NUMBER:   "number",
+		RANGE:    "range",
+		DATE:     "date",
+		DATETIME: "datetime-local",
+		TIME:     "time",
+		URL:      "url",
+		TEL:      "tel",
+		COLOR:    "color",
+		SEARCH:   "search",
+	}
+	// stringTypes are the types which might be selected
+	// with an option for string fields, in order of priority
+	stringTypes = []struct {
+		option string
+		typ    Type
+	}{
+		{"email", EMAIL},
+		{"url", URL},
+		{"tel", TEL},
+		{"color", COLOR},
+		{"search", SEARCH},
+		{"date", DATE},
+		{"datetime", DATETIME},
+		{"time", TIME},
+	}
+	// timeTypes are the layouts used for
+	// the values of time.Time fields
+	timeTypes = map[Type]string{
+		DATE:     "2006-01-02",
+		DATETIME: "2006-01-02T15:04",
+		TIME:     "15:04",
+	}
 )
 
 // HasChoices returns wheter the type has multiple
-// choices, which corresponds to RADIO and SELECT
-// elements.
+// choices, which corresponds to RADIO, SELECT and
+// CHECKBOXES elements.
 func (t Type) HasChoices() bool {
-	return t == RADIO || t == SELECT
+	return t == RADIO || t == SELECT || t == CHECKBOXES
+}
+
+// InputType returns the type attribute for types rendered
+// as an <input> (e.g. "email" for EMAIL) or the empty
+// string for types which use other elements.
+func (t Type) InputType() string {
+	return inputTypes[t]
+}
+
+// IsText returns true iff the type accepts free form text,
+// and hence supports the minlength, maxlength, placeholder
+// and pattern attributes.
+func (t Type) IsText() bool {
+	switch t {
+	case TEXT, PASSWORD, TEXTAREA, EMAIL, URL, TEL, SEARCH:
+		return true
+	}
+	return false
 }
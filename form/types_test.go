@@ -0,0 +1,164 @@
+package form
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type typesEvent struct {
+	Name     string    `form:",singleline,max_length=20,pattern=[a-z]+"`
+	Email    string    `form:",email,optional"`
+	Website  string    `form:",url,optional"`
+	Seats    int       `form:",min=1,max=10"`
+	Price    float64   `form:",optional"`
+	Volume   int       `form:",range,min=0,max=11,step=1"`
+	Day      time.Time `form:",date"`
+	Starts   time.Time
+	Opens    time.Time `form:",time,optional"`
+	Password string    `form:",password,min_length=8,optional"`
+}
+
+// inputTag returns the <input> with the given name in s
+func inputTag(s string, name string) string {
+	re := regexp.MustCompile(`<input [^>]*name="` + regexp.QuoteMeta(name) + `"[^>]*>`)
+	return re.FindString(s)
+}
+
+var inputTypesTests = []struct {
+	name     string
+	expected []string
+	missing  []string
+}{
+	{"name", []string{`type="text"`, `required="required"`, `maxlength="20"`, `pattern="[a-z]+"`, `value="party"`}, nil},
+	{"email", []string{`type="email"`}, []string{"required"}},
+	{"website", []string{`type="url"`}, nil},
+	{"seats", []string{`type="number"`, `required="required"`, `min="1"`, `max="10"`, `value="5"`}, []string{"step"}},
+	{"price", []string{`type="number"`, `step="any"`}, []string{"required"}},
+	{"volume", []string{`type="range"`, `min="0"`, `max="11"`, `step="1"`}, []string{"required"}},
+	{"day", []string{`type="date"`, `value="2015-03-07"`}, nil},
+	{"starts", []string{`type="datetime-local"`, `value="2015-03-07T21:30"`}, nil},
+	{"opens", []string{`type="time"`, `value=""`}, []string{"required"}},
+	{"password", []string{`type="password"`, `minlength="8"`}, []string{"required"}},
+}
+
+func TestInputTypes(t *testing.T) {
+	event := &typesEvent{
+		Name:   "party",
+		Seats:  5,
+		Day:    time.Date(2015, 3, 7, 0, 0, 0, 0, time.UTC),
+		Starts: time.Date(2015, 3, 7, 21, 30, 0, 0, time.UTC),
+	}
+	fieldsetForm(t, "GET", nil, event, nil, func(f *Form) {
+		html, err := f.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(html)
+		for _, v := range inputTypesTests {
+			tag := inputTag(s, v.name)
+			if tag == "" {
+				t.Errorf("no input named %s in rendered form %s", v.name, s)
+				continue
+			}
+			for _, e := range v.expected {
+				if !strings.Contains(tag, e) {
+					t.Errorf("expecting %s in %s", e, tag)
+				}
+			}
+			for _, m := range v.missing {
+				if strings.Contains(tag, m) {
+					t.Errorf("unexpected %s in %s", m, tag)
+				}
+			}
+		}
+	})
+}
+
+func TestInputTimeRoundTrip(t *testing.T) {
+	starts := time.Date(2015, 3, 7, 23, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+	event := &typesEvent{
+		Name:   "party",
+		Seats:  5,
+		Day:    time.Date(2015, 3, 7, 0, 0, 0, 0, time.UTC),
+		Starts: starts,
+	}
+	var value string
+	fieldsetForm(t, "GET", nil, event, nil, func(f *Form) {
+		html, err := f.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := regexp.MustCompile(`value="([^"]*)"`).FindStringSubmatch(inputTag(string(html), "starts"))
+		if m == nil {
+			t.Fatalf("no value for starts in rendered form %s", html)
+		}
+		value = m[1]
+	})
+	values := url.Values{
+		"name":     []string{"party"},
+		"seats":    []string{"5"},
+		"volume":   []string{"11"},
+		"day":      []string{"2015-03-07"},
+		"starts":   []string{value},
+		"password": []string{"12345678"},
+	}
+	parsed := &typesEvent{}
+	fieldsetForm(t, "POST", values, parsed, nil, func(f *Form) {
+		if !f.IsValid() {
+			for _, v := range f.Fields() {
+				if v.Err() != nil {
+					t.Errorf("error in field %s: %s", v.Name, v.Err())
+				}
+			}
+		}
+	})
+	if !parsed.Starts.Equal(starts) {
+		t.Errorf("expecting starts %s after round trip, got %s (rendered as %q)", starts, parsed.Starts, value)
+	}
+}
+
+func TestInputTypesParse(t *testing.T) {
+	values := url.Values{
+		"name":     []string{"party"},
+		"seats":    []string{"3"},
+		"volume":   []string{"11"},
+		"day":      []string{"2015-03-07"},
+		"starts":   []string{"2015-03-07T21:30"},
+		"opens":    []string{"20:00"},
+		"password": []string{"12345678"},
+	}
+	event := &typesEvent{}
+	fieldsetForm(t, "POST", values, event, nil, func(f *Form) {
+		if !f.IsValid() {
+			for _, v := range f.Fields() {
+				if v.Err() != nil {
+					t.Errorf("error in field %s: %s", v.Name, v.Err())
+				}
+			}
+		}
+	})
+	if y, m, d := event.Day.Date(); y != 2015 || m != time.March || d != 7 {
+		t.Errorf("expecting day 2015-03-07, got %s", event.Day)
+	}
+	if h, m := event.Starts.Hour(), event.Starts.Minute(); h != 21 || m != 30 {
+		t.Errorf("expecting starts at 21:30, got %s", event.Starts)
+	}
+	if h := event.Opens.Hour(); h != 20 {
+		t.Errorf("expecting opens at 20:00, got %s", event.Opens)
+	}
+	values.Set("seats", "20")
+	values.Set("name", "Party")
+	fieldsetForm(t, "POST", values, &typesEvent{}, nil, func(f *Form) {
+		if f.IsValid() {
+			t.Fatal("expecting invalid form")
+		}
+		for _, v := range []string{"Name", "Seats"} {
+			if field, _ := f.FieldByName(v); field == nil || field.Err() == nil {
+				t.Errorf("expecting an error in field %s", v)
+			}
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package paginator
+
+import (
+	"html/template"
+	"net/url"
+
+	"gnd.la/html"
+)
+
+// CursorFunc receives the base URL (which might be relative or
+// absolute), a cursor and whether the cursor is used to request
+// the next page (true) or the previous one (false) and returns
+// the URL for the requested page, as a string.
+type CursorFunc func(base string, cursor string, next bool) string
+
+// QueryCursor returns a function which adds the cursor to the
+// base URL as the query parameter named after for the next
+// page or as the one named before for the previous page.
+func QueryCursor(after string, before string) CursorFunc {
+	return func(base string, cursor string, next bool) string {
+		u, err := url.Parse(base)
+		if err != nil {
+			return base
+		}
+		values := u.Query()
+		values.Del(after)
+		values.Del(before)
+		if next {
+			values.Set(after, cursor)
+		} else {
+			values.Set(before, cursor)
+		}
+		u.RawQuery = values.Encode()
+		return u.String()
+	}
+}
+
+// CursorPaginator is a paginator for results paginated using
+// cursors (e.g. gnd.la/orm.Query.After and gnd.la/orm.Query.Before)
+// rather than page numbers. Since the number of pages is not known,
+// it only renders the links to the previous and next pages.
+type CursorPaginator struct {
+	Base string
+	// Prev is the cursor for the previous page. If
+	// empty, the previous link is disabled.
+	Prev string
+	// Next is the cursor for the next page. If
+	// empty, the next link is disabled.
+	Next  string
+	Pager Pager
+	Func  CursorFunc
+}
+
+func (p *CursorPaginator) appendNode(parent *html.Node, cursor string, flags int) {
+	node := &html.Node{Tag: "a", Attrs: html.Attrs{}}
+	if cursor != "" {
+		node.Attrs["href"] = p.Func(p.Base, cursor, flags&NEXT != 0)
+	} else {
+		flags |= DISABLED
+	}
+	if n := p.Pager.Node(node, 0, flags); n != nil {
+		parent.AppendChild(n)
+	}
+}
+
+func (p *CursorPaginator) Render() template.HTML {
+	root := p.Pager.Root()
+	parent := root
+	for parent.Children != nil {
+		parent = parent.LastChild()
+	}
+	p.appendNode(parent, p.Prev, PREVIOUS)
+	p.appendNode(parent, p.Next, NEXT)
+	return root.HTML()
+}
+
+// NewCursor returns a new CursorPaginator with the given base URL,
+// cursors for the previous and next pages, Pager and CursorFunc.
+func NewCursor(base, prev, next string, pager Pager, f CursorFunc) *CursorPaginator {
+	return &CursorPaginator{
+		Base:  base,
+		Prev:  prev,
+		Next:  next,
+		Pager: pager,
+		Func:  f,
+	}
+}
+
+// NewSimpleCursor returns a new CursorPaginator using a SimplePager
+// with the given texts for the next and previous links.
+func NewSimpleCursor(base, prev, next string, nextText, prevText string, f CursorFunc) *CursorPaginator {
+	pager := &SimplePager{
+		Tag:  "div",
+		Next: nextText,
+		Prev: prevText,
+	}
+	return NewCursor(base, prev, next, pager, f)
+}
@@ -3,8 +3,10 @@
 package httpserve
 
 import (
+	"hash/fnv"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -34,3 +36,75 @@ func NeverExpires(w http.ResponseWriter) {
 	header.Add("Cache-Control", "max-age="+maxCacheControlAgeValue)
 	header.Add("Expires", maxExpiresValue)
 }
+
+// ETag returns a strong entity tag for the given fnv64a hash.
+func ETag(hash uint64) string {
+	return `"` + strconv.FormatUint(hash, 16) + `"`
+}
+
+// BodyETag returns a strong entity tag for the given response body.
+func BodyETag(b []byte) string {
+	h := fnv.New64a()
+	h.Write(b)
+	return ETag(h.Sum64())
+}
+
+// SetValidators sets the ETag and Last-Modified headers in the given
+// http.Header, unless they've been already set. Empty etags and
+// zero times are ignored.
+func SetValidators(header http.Header, etag string, modTime time.Time) {
+	if etag != "" && header.Get("ETag") == "" {
+		header.Set("ETag", etag)
+	}
+	if !modTime.IsZero() && header.Get("Last-Modified") == "" {
+		header.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+}
+
+// NotModified returns true iff the given GET or HEAD request can be
+// responded with a 304 status code, because the ETag or Last-Modified
+// headers in the response headers match the request If-None-Match
+// or If-Modified-Since. As specified by RFC 7232, If-Modified-Since
+// is ignored when the request includes If-None-Match.
+func NotModified(r *http.Request, header http.Header) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := strings.TrimPrefix(header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, v := range strings.Split(inm, ",") {
+			v = strings.TrimSpace(v)
+			if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	lm := header.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modTime, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modTime.After(since)
+}
+
+// WriteNotModified writes a 304 response to w, removing the
+// headers which don't apply to responses without a body.
+func WriteNotModified(w http.ResponseWriter) {
+	header := w.Header()
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Del("Content-Range")
+	w.WriteHeader(http.StatusNotModified)
+}
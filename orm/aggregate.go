@@ -0,0 +1,375 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gnd.la/app/profile"
+	"gnd.la/orm/driver"
+	"gnd.la/orm/query"
+	"gnd.la/util/types"
+)
+
+var (
+	timeLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999-07:00",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02",
+	}
+)
+
+// Aggregate represents an aggregate function applied to the
+// results of a Query. Use Count, CountField, CountDistinct, Sum,
+// Avg, Min or Max to create an Aggregate and pass it to
+// Query.Aggregate.
+//
+// Each Aggregate has a name, which is used for storing its result
+// into the output and for referencing it from the Having
+// condition and Sort. By default, the name is the function
+// name followed by the field name (e.g. SumScore for Sum("Score")),
+// but it can be changed using As.
+type Aggregate struct {
+	fn    driver.AggregateFunc
+	field string
+	name  string
+}
+
+// Func returns the function used to compute the aggregate.
+func (a *Aggregate) Func() driver.AggregateFunc {
+	return a.fn
+}
+
+// Field returns the field the aggregate is computed on.
+func (a *Aggregate) Field() string {
+	return a.field
+}
+
+// Name returns the name of the aggregate. See As.
+func (a *Aggregate) Name() string {
+	return a.name
+}
+
+// As sets the name of the aggregate and returns it. e.g.
+//
+//  orm.Sum("Score").As("Total")
+func (a *Aggregate) As(name string) *Aggregate {
+	a.name = name
+	return a
+}
+
+func newAggregate(fn driver.AggregateFunc, prefix string, field string) *Aggregate {
+	name := field
+	if p := strings.LastIndexAny(name, "|."); p >= 0 {
+		name = name[p+1:]
+	}
+	return &Aggregate{fn: fn, field: field, name: prefix + name}
+}
+
+// Count returns an Aggregate which counts the number of
+// rows in each group, named Count.
+func Count() *Aggregate {
+	return &Aggregate{fn: driver.COUNT, name: "Count"}
+}
+
+// CountField returns an Aggregate which counts the number of
+// non-null values for the given field, named Count<field>.
+func CountField(field string) *Aggregate {
+	return newAggregate(driver.COUNT, "Count", field)
+}
+
+// CountDistinct returns an Aggregate which counts the number
+// of distinct values for the given field, named
+// CountDistinct<field>.
+func CountDistinct(field string) *Aggregate {
+	return newAggregate(driver.COUNT_DISTINCT, "CountDistinct", field)
+}
+
+// Sum returns an Aggregate which adds the values of the given
+// field, named Sum<field>.
+func Sum(field string) *Aggregate {
+	return newAggregate(driver.SUM, "Sum", field)
+}
+
+// Avg returns an Aggregate which averages the values of the
+// given field, named Avg<field>.
+func Avg(field string) *Aggregate {
+	return newAggregate(driver.AVG, "Avg", field)
+}
+
+// Min returns an Aggregate which selects the minimum value
+// of the given field, named Min<field>.
+func Min(field string) *Aggregate {
+	return newAggregate(driver.MIN, "Min", field)
+}
+
+// Max returns an Aggregate which selects the maximum value
+// of the given field, named Max<field>.
+func Max(field string) *Aggregate {
+	return newAggregate(driver.MAX, "Max", field)
+}
+
+// aggregateOut stores the results of an aggregate query into
+// the value passed to Query.Aggregate.
+type aggregateOut struct {
+	names []string
+	// slice to append the results to, if any
+	slice reflect.Value
+	// type of the elements to store
+	typ reflect.Type
+	// single element to store the result into,
+	// if slice is not valid
+	single reflect.Value
+	isMap  bool
+}
+
+func newAggregateOut(out interface{}, names []string) (*aggregateOut, error) {
+	val := reflect.ValueOf(out)
+	if !val.IsValid() || val.Kind() != reflect.Ptr || val.IsNil() {
+		return nil, fmt.Errorf("argument to Aggregate() must be a non-nil pointer, not %T", out)
+	}
+	a := &aggregateOut{names: names}
+	elem := val.Elem()
+	if elem.Kind() == reflect.Slice {
+		a.slice = elem
+		a.typ = elem.Type().Elem()
+	} else {
+		a.single = elem
+		a.typ = elem.Type()
+	}
+	styp := a.typ
+	if styp.Kind() == reflect.Ptr {
+		styp = styp.Elem()
+	}
+	switch {
+	case styp.Kind() == reflect.Map && styp.Key().Kind() == reflect.String && styp.Elem().Kind() == reflect.Interface:
+		a.isMap = true
+	case styp.Kind() == reflect.Struct:
+		for _, v := range names {
+			if _, ok := styp.FieldByName(v); !ok {
+				return nil, fmt.Errorf("type %s has no field named %s to store the aggregate result", styp, v)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("can't store aggregate results into %T - use a struct or a map[string]interface{}", out)
+	}
+	return a, nil
+}
+
+func (a *aggregateOut) store(values []interface{}) error {
+	var val reflect.Value
+	if a.slice.IsValid() {
+		val = reflect.New(a.typ).Elem()
+	} else {
+		val = a.single
+	}
+	dst := val
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		dst = dst.Elem()
+	}
+	if a.isMap {
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for ii, v := range a.names {
+			if b, ok := values[ii].([]byte); ok {
+				values[ii] = string(b)
+			}
+			dst.SetMapIndex(reflect.ValueOf(v), reflect.ValueOf(&values[ii]).Elem())
+		}
+	} else {
+		for ii, v := range a.names {
+			if err := setAggregateValue(dst.FieldByName(v), values[ii]); err != nil {
+				return fmt.Errorf("error storing %s: %s", v, err)
+			}
+		}
+	}
+	if a.slice.IsValid() {
+		a.slice.Set(reflect.Append(a.slice, val))
+	}
+	return nil
+}
+
+// setAggregateValue stores the value returned from the database
+// into dst, performing the required conversions, since the types
+// returned by aggregate expressions vary among backends.
+func setAggregateValue(dst reflect.Value, src interface{}) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if b, ok := src.([]byte); ok {
+		if dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(b)
+			return nil
+		}
+		src = string(b)
+	}
+	typ := dst.Type()
+	switch {
+	case types.IsInt(typ):
+		v, err := types.ToInt64(src)
+		if err != nil {
+			f, ferr := types.ToFloat(src)
+			if ferr != nil {
+				return err
+			}
+			v = int64(f)
+		}
+		dst.SetInt(v)
+	case types.IsUint(typ):
+		v, err := types.ToUint64(src)
+		if err != nil {
+			return err
+		}
+		dst.SetUint(v)
+	case types.IsFloat(typ):
+		v, err := types.ToFloat(src)
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(v)
+	case typ.Kind() == reflect.String:
+		dst.SetString(types.ToString(src))
+	case typ == reflect.TypeOf(time.Time{}):
+		switch x := src.(type) {
+		case time.Time:
+			dst.Set(reflect.ValueOf(x))
+			return nil
+		case int64:
+			// Backends storing times as UNIX timestamps (e.g. sqlite)
+			dst.Set(reflect.ValueOf(time.Unix(x, 0).UTC()))
+			return nil
+		case string:
+			for _, v := range timeLayouts {
+				if t, err := time.Parse(v, x); err == nil {
+					dst.Set(reflect.ValueOf(t))
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("can't convert %v to time.Time", src)
+	default:
+		sv := reflect.ValueOf(src)
+		if !sv.Type().ConvertibleTo(typ) {
+			return fmt.Errorf("can't convert %T to %s", src, typ)
+		}
+		dst.Set(sv.Convert(typ))
+	}
+	return nil
+}
+
+// GroupBy sets the fields used for grouping the results
+// of the aggregates computed with Aggregate. Calling GroupBy
+// multiple times adds more fields.
+func (q *Query) GroupBy(fields ...string) *Query {
+	q.groupBy = append(q.groupBy, fields...)
+	return q
+}
+
+// Having adds a condition to filter the groups produced by
+// Aggregate. Conditions might reference the aggregates by their
+// name as well as the fields used in GroupBy. e.g.
+//
+//  Having(orm.Gt("SumScore", 100))
+//
+// Like Filter, calling Having multiple times ANDs the conditions.
+func (q *Query) Having(qu query.Q) *Query {
+	if qu != nil {
+		if q.having == nil {
+			q.having = qu
+		} else {
+			q.having = And(q.having, qu)
+		}
+	}
+	return q
+}
+
+// Aggregate computes the given aggregates over the results of the
+// query, grouped by the fields set with GroupBy, and stores them in
+// out, which must be a pointer to a struct or a map[string]interface{}
+// (to store only the first result) or a pointer to a slice of them
+// (to store all the results). Grouped fields are stored using
+// their names (without the model name, if present), while aggregates
+// are stored using their names (see Aggregate). Results can be sorted
+// by the grouped fields or the aggregate names. e.g.
+//
+//  var results []struct {
+//	AuthorId int64
+//	Count    int
+//	Total    float64
+//  }
+//  err := o.Table(articleTable).GroupBy("AuthorId").Sort("Total", orm.DESC).
+//	Aggregate(&results, orm.Count(), orm.Sum("Score").As("Total"))
+//
+// Note that you have to set the table manually before calling
+// Aggregate and that not all drivers support aggregates (see
+// gnd.la/orm/driver.CAP_AGGREGATE).
+func (q *Query) Aggregate(out interface{}, aggs ...*Aggregate) error {
+	if q.err != nil {
+		return q.err
+	}
+	if err := q.ensureTable("Aggregate"); err != nil {
+		return err
+	}
+	if q.orm.driver.Capabilities()&driver.CAP_AGGREGATE == 0 {
+		return fmt.Errorf("ORM driver %T does not support aggregates", q.orm.driver)
+	}
+	names := make([]string, 0, len(q.groupBy)+len(aggs))
+	for _, v := range q.groupBy {
+		name := v
+		if p := strings.LastIndexAny(name, "|."); p >= 0 {
+			name = name[p+1:]
+		}
+		names = append(names, name)
+	}
+	agg := &driver.Aggregation{
+		Aggregates: make([]driver.Aggregate, len(aggs)),
+		GroupBy:    q.groupBy,
+		Having:     q.having,
+	}
+	for ii, v := range aggs {
+		agg.Aggregates[ii] = v
+		names = append(names, v.name)
+	}
+	res, err := newAggregateOut(out, names)
+	if err != nil {
+		return err
+	}
+	if profile.On && profile.Profiling() {
+		defer profile.Start(orm).Note("aggregate", q.model.String()).End()
+	}
+	limit := q.limit
+	if !res.slice.IsValid() {
+		limit = 1
+	}
+	iter := q.orm.conn.Aggregate(q.model, q.condition(), agg, q.sort, limit, q.offset)
+	values := make([]interface{}, len(names))
+	pointers := make([]interface{}, len(names))
+	for ii := range values {
+		pointers[ii] = &values[ii]
+	}
+	for iter.Next(pointers...) {
+		if err := res.store(values); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	if err := iter.Err(); err != nil {
+		iter.Close()
+		return err
+	}
+	return iter.Close()
+}
+
+// MustAggregate works like Aggregate, but panics if there's an error.
+func (q *Query) MustAggregate(out interface{}, aggs ...*Aggregate) {
+	if err := q.Aggregate(out, aggs...); err != nil {
+		panic(err)
+	}
+}
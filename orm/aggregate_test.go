@@ -0,0 +1,108 @@
+package orm
+
+import (
+	"testing"
+	"time"
+
+	"gnd.la/orm/driver"
+)
+
+type Score struct {
+	Id      int64 `orm:",primary_key,auto_increment"`
+	Player  string
+	Points  int
+	Created time.Time
+}
+
+type playerScore struct {
+	Player string
+	Count  int
+	Total  int64
+	Avg    float64
+	Max    int
+}
+
+func testAggregate(t *testing.T, o *Orm) {
+	tbl := o.mustRegister((*Score)(nil), &Options{
+		Table: "test_aggregate",
+	})
+	o.mustInitialize()
+	if o.Driver().Capabilities()&driver.CAP_AGGREGATE == 0 {
+		var res playerScore
+		if err := o.Table(tbl).Aggregate(&res, Count()); err == nil {
+			t.Error("expecting an error when the driver does not support aggregates")
+		}
+		return
+	}
+	scores := map[string][]int{
+		"alice": {10, 20, 30},
+		"bob":   {5, 5},
+		"carol": {50},
+	}
+	for k, v := range scores {
+		for _, p := range v {
+			o.MustInsert(&Score{Player: k, Points: p, Created: time.Now()})
+		}
+	}
+	var res []*playerScore
+	err := o.Table(tbl).GroupBy("Player").Sort("Total", DESC).Aggregate(&res, Count(),
+		Sum("Points").As("Total"), Avg("Points").As("Avg"), Max("Points").As("Max"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []playerScore{
+		{"alice", 3, 60, 20, 30},
+		{"carol", 1, 50, 50, 50},
+		{"bob", 2, 10, 5, 5},
+	}
+	if len(res) != len(expected) {
+		t.Fatalf("expecting %d results, got %d", len(expected), len(res))
+	}
+	for ii, v := range expected {
+		if *res[ii] != v {
+			t.Errorf("expecting %+v at index %d, got %+v", v, ii, *res[ii])
+		}
+	}
+	var having []playerScore
+	err = o.Table(tbl).Filter(Neq("Player", "carol")).GroupBy("Player").Having(Gt("Total", 20)).
+		Aggregate(&having, Count(), Sum("Points").As("Total"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(having) != 1 || having[0].Player != "alice" || having[0].Total != 60 {
+		t.Errorf("unexpected results with Having %+v", having)
+	}
+	var totals map[string]interface{}
+	if err := o.Table(tbl).Aggregate(&totals, CountDistinct("Player"), Min("Points"), Max("Created")); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := totals["CountDistinctPlayer"].(int64); !ok || c != 3 {
+		t.Errorf("expecting 3 distinct players, got %v", totals["CountDistinctPlayer"])
+	}
+	if m, ok := totals["MinPoints"].(int64); !ok || m != 5 {
+		t.Errorf("expecting min points 5, got %v", totals["MinPoints"])
+	}
+	var latest struct {
+		Created time.Time
+	}
+	if err := o.Table(tbl).Aggregate(&latest, Max("Created").As("Created")); err != nil {
+		t.Error(err)
+	} else if latest.Created.IsZero() {
+		t.Error("expecting non-zero time from Max(Created)")
+	}
+	var empty struct {
+		SumPoints int
+	}
+	if err := o.Table(tbl).Filter(Eq("Player", "dave")).Aggregate(&empty, Sum("Points")); err != nil {
+		t.Error(err)
+	}
+	if empty.SumPoints != 0 {
+		t.Errorf("expecting 0 points for missing player, got %d", empty.SumPoints)
+	}
+	var bad struct {
+		Foo int
+	}
+	if err := o.Table(tbl).Aggregate(&bad, Count()); err == nil {
+		t.Error("expecting an error when aggregating into a struct without the required fields")
+	}
+}
@@ -0,0 +1,220 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+
+	"gnd.la/app/profile"
+	"gnd.la/orm/driver"
+)
+
+type batchResult struct {
+	lastId   int64
+	affected int64
+}
+
+func (r *batchResult) LastInsertId() (int64, error) {
+	return r.lastId, nil
+}
+
+func (r *batchResult) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
+
+func (r *batchResult) add(res Result) {
+	if id, err := res.LastInsertId(); err == nil && id != 0 {
+		r.lastId = id
+	}
+	if aff, err := res.RowsAffected(); err == nil {
+		r.affected += aff
+	}
+}
+
+// InsertAll inserts all the objects in objs, which must be a slice
+// of objects (or pointers to objects) of the same registered model.
+// Drivers implementing driver.BatchInserter insert them using fewer
+// round trips (e.g. the SQL drivers use multi-row INSERT statements,
+// chunked to the maximum number of parameters supported by the
+// database), while the rest of them insert the objects one by one.
+// If the model has an auto incremented primary key, the ids assigned
+// by the database are set in the objects, as long as the driver is
+// able to determine them. Note that to receive the ids, the slice
+// must contain pointers or be addressable (e.g. []T or []*T are fine,
+// but []interface{} with non-pointer elements is not).
+//
+// If the driver supports transactions and the Orm is not already in
+// a transaction, the objects are inserted in a new one, so either all
+// of them or none are inserted. Objects of models implementing any of
+// the insert hooks (see BeforeInserter) are always inserted one by one,
+// so their hooks are called.
+func (o *Orm) InsertAll(objs interface{}) (Result, error) {
+	return o.batch(objs, false)
+}
+
+// MustInsertAll works like InsertAll, but panics if there's an error.
+func (o *Orm) MustInsertAll(objs interface{}) Result {
+	res, err := o.InsertAll(objs)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// UpsertAll works like InsertAll, but objects with the same primary
+// key than an existing one update it, as Save does. The model must
+// have a primary key, either simple or composite. When updating an
+// existing object, the field tagged with created (if any) keeps the
+// value already stored in the database. If several objects have the same primary key, only the
+// last one is saved. Objects of models implementing any of the insert
+// or update hooks are saved one by one using Save.
+func (o *Orm) UpsertAll(objs interface{}) (Result, error) {
+	return o.batch(objs, true)
+}
+
+// MustUpsertAll works like UpsertAll, but panics if there's an error.
+func (o *Orm) MustUpsertAll(objs interface{}) Result {
+	res, err := o.UpsertAll(objs)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (o *Orm) batch(objs interface{}, upsert bool) (Result, error) {
+	m, items, err := o.batchObjects(objs)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return &batchResult{}, nil
+	}
+	if upsert && m.fields.PrimaryKey < 0 && len(m.fields.CompositePrimaryKey) == 0 {
+		return nil, fmt.Errorf("can't upsert objects of type %v because it has no primary key", m.Type())
+	}
+	if profile.On && profile.Profiling() {
+		note := "insert all"
+		if upsert {
+			note = "upsert all"
+		}
+		defer profile.Start(orm).Note(note, m.name).End()
+	}
+	if o.inTransaction || o.driver.Capabilities()&driver.CAP_BEGIN == 0 {
+		return o.doBatch(m, items, upsert)
+	}
+	var res Result
+	err = o.Transaction(func(tx *Orm) error {
+		var err error
+		res, err = tx.doBatch(m, items, upsert)
+		return err
+	})
+	return res, err
+}
+
+// batchObjects returns the model and the objects in the given slice,
+// calling their Save method, if any.
+func (o *Orm) batchObjects(objs interface{}) (*model, []interface{}, error) {
+	val := reflect.ValueOf(objs)
+	for val.Kind() == reflect.Ptr && !val.IsNil() {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, nil, fmt.Errorf("argument must be a slice or an array, not %T", objs)
+	}
+	var m *model
+	items := make([]interface{}, val.Len())
+	for ii := range items {
+		item := val.Index(ii)
+		if item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+		if item.Kind() != reflect.Ptr && item.CanAddr() {
+			item = item.Addr()
+		}
+		obj := item.Interface()
+		im, err := o.model(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		if m == nil {
+			m = im
+		} else if im != m {
+			return nil, nil, fmt.Errorf("can't insert objects of different types (%v and %v) at once", m.Type(), im.Type())
+		}
+		if err := o.prepareSave(m, obj, nil); err != nil {
+			return nil, nil, err
+		}
+		items[ii] = obj
+	}
+	return m, items, nil
+}
+
+func (o *Orm) doBatch(m *model, objs []interface{}, upsert bool) (Result, error) {
+	bi, ok := o.conn.(driver.BatchInserter)
+	if !ok || hasBatchHooks(objs[0], upsert) {
+		res := &batchResult{}
+		for _, v := range objs {
+			var r Result
+			var err error
+			if upsert {
+				r, err = o.save(m, v)
+			} else {
+				r, err = o.insert(m, v)
+			}
+			if err != nil {
+				return nil, err
+			}
+			res.add(r)
+		}
+		return res, nil
+	}
+	var pkName string
+	pkVals := make([]reflect.Value, len(objs))
+	prepared := make([]interface{}, len(objs))
+	for ii, v := range objs {
+		obj, name, pkVal, err := o.prepareInsert(m, v)
+		if err != nil {
+			return nil, err
+		}
+		pkName = name
+		pkVals[ii] = pkVal
+		prepared[ii] = obj
+	}
+	var res Result
+	var ids []int64
+	var err error
+	if upsert {
+		res, ids, err = bi.UpsertAll(m, prepared)
+	} else {
+		res, ids, err = bi.InsertAll(m, prepared)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for ii, id := range ids {
+		if pkVal := pkVals[ii]; id != 0 && pkVal.IsValid() && pkVal.Int() == 0 {
+			o.setPrimaryKey(m, pkName, pkVal, id)
+		}
+	}
+	return res, nil
+}
+
+// hasBatchHooks returns true iff obj implements any of the
+// hooks which might be called by InsertAll or UpsertAll.
+func hasBatchHooks(obj interface{}, upsert bool) bool {
+	h := hookObject(obj)
+	if _, ok := h.(BeforeInserter); ok {
+		return true
+	}
+	if _, ok := h.(AfterInserter); ok {
+		return true
+	}
+	if upsert {
+		if _, ok := h.(BeforeUpdater); ok {
+			return true
+		}
+		if _, ok := h.(AfterUpdater); ok {
+			return true
+		}
+	}
+	return false
+}
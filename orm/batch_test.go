@@ -0,0 +1,139 @@
+package orm
+
+import (
+	"strconv"
+	"testing"
+
+	"gnd.la/orm/driver"
+)
+
+type BatchItem struct {
+	Id    int64 `orm:",primary_key,auto_increment"`
+	Value string
+	Extra int64 `orm:",omitempty"`
+}
+
+type BatchKeyed struct {
+	Key   string `orm:",primary_key"`
+	Value int
+}
+
+func testBatch(t *testing.T, o *Orm) {
+	tbl := o.mustRegister((*BatchItem)(nil), &Options{
+		Table: "test_batch",
+	})
+	keyed := o.mustRegister((*BatchKeyed)(nil), &Options{
+		Table: "test_batch_keyed",
+	})
+	o.mustInitialize()
+	// Enough items to require several statements, with some
+	// of them omitting the Extra field
+	const count = 1500
+	items := make([]*BatchItem, count)
+	for ii := range items {
+		items[ii] = &BatchItem{Value: strconv.Itoa(ii)}
+		if ii%100 == 0 {
+			items[ii].Extra = int64(ii)
+		}
+	}
+	res := o.MustInsertAll(items)
+	if aff, err := res.RowsAffected(); err != nil || aff != count {
+		t.Errorf("expecting %d affected rows, got %d (error %v)", count, aff, err)
+	}
+	if c := o.Table(tbl).MustCount(); c != count {
+		t.Errorf("expecting %d items, got %d", count, c)
+	}
+	ids := make(map[int64]bool)
+	for _, v := range items {
+		if v.Id == 0 || ids[v.Id] {
+			t.Fatalf("invalid or duplicate id %d", v.Id)
+		}
+		ids[v.Id] = true
+	}
+	for _, v := range []*BatchItem{items[0], items[1], items[count/2], items[count-1]} {
+		var loaded BatchItem
+		if _, err := o.One(Eq("BatchItem.Id", v.Id), &loaded); err != nil {
+			t.Fatal(err)
+		}
+		if loaded != *v {
+			t.Errorf("expecting item %+v, got %+v", v, loaded)
+		}
+	}
+	// Slices of values work too
+	values := []BatchItem{{Value: "a"}, {Value: "b"}}
+	o.MustInsertAll(values)
+	if values[0].Id == 0 || values[1].Id != values[0].Id+1 {
+		t.Errorf("unexpected ids %d and %d", values[0].Id, values[1].Id)
+	}
+	if _, err := o.InsertAll([]interface{}{&BatchItem{}, &BatchKeyed{}}); err == nil {
+		t.Error("expecting an error when inserting objects of different models")
+	}
+	// Upserts update the objects with an existing
+	// primary key and insert the rest.
+	items[0].Value = "updated"
+	o.MustUpsertAll([]*BatchItem{items[0], {Value: "new"}})
+	var loaded BatchItem
+	if _, err := o.One(Eq("BatchItem.Id", items[0].Id), &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Value != "updated" {
+		t.Errorf("expecting updated value, got %q", loaded.Value)
+	}
+	if c := o.Table(tbl).MustCount(); c != count+3 {
+		t.Errorf("expecting %d items, got %d", count+3, c)
+	}
+	o.MustInsertAll([]*BatchKeyed{{Key: "a", Value: 1}, {Key: "b", Value: 2}})
+	o.MustUpsertAll([]*BatchKeyed{{Key: "b", Value: 3}, {Key: "c", Value: 4}})
+	var kv []*BatchKeyed
+	if err := o.Table(keyed).Sort("Key", ASC).All(&kv); err != nil {
+		t.Fatal(err)
+	}
+	if len(kv) != 3 || kv[0].Value != 1 || kv[1].Value != 3 || kv[2].Value != 4 {
+		t.Errorf("unexpected objects after upsert %v", kv)
+	}
+	// The last object with the same key wins
+	o.MustUpsertAll([]*BatchKeyed{{Key: "d", Value: 5}, {Key: "a", Value: 6}, {Key: "d", Value: 7}})
+	kv = nil
+	if err := o.Table(keyed).Sort("Key", ASC).All(&kv); err != nil {
+		t.Fatal(err)
+	}
+	if len(kv) != 4 || kv[0].Value != 6 || kv[3].Key != "d" || kv[3].Value != 7 {
+		t.Errorf("unexpected objects after upsert with duplicate keys %v", kv)
+	}
+}
+
+func testBatchHooks(t *testing.T, o *Orm) {
+	o.mustRegister((*HookAccount)(nil), &Options{
+		Table: "test_batch_hook_accounts",
+	})
+	posts := o.mustRegister((*HookPost)(nil), &Options{
+		Table: "test_batch_hook_posts",
+	})
+	o.mustInitialize()
+	account := &HookAccount{}
+	o.MustInsert(account)
+	// Models with hooks are inserted one by one
+	o.MustInsertAll([]*HookPost{
+		{AccountId: account.Id, Title: "1"},
+		{AccountId: account.Id, Title: "2"},
+	})
+	var acc HookAccount
+	if _, err := o.One(Eq("HookAccount.Id", account.Id), &acc); err != nil {
+		t.Fatal(err)
+	}
+	if acc.Posts != 2 {
+		t.Errorf("expecting 2 posts, got %d", acc.Posts)
+	}
+	// The whole batch is rolled back when a hook fails
+	if _, err := o.InsertAll([]*HookPost{
+		{AccountId: account.Id, Title: "3"},
+		{AccountId: account.Id},
+	}); err != errNoTitle {
+		t.Errorf("expecting errNoTitle, got %v", err)
+	}
+	if o.driver.Capabilities()&driver.CAP_BEGIN != 0 {
+		if c := o.Table(posts).MustCount(); c != 2 {
+			t.Errorf("expecting 2 posts after failed batch, got %d", c)
+		}
+	}
+}
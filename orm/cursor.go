@@ -0,0 +1,193 @@
+package orm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gnd.la/orm/driver"
+	"gnd.la/orm/query"
+)
+
+var (
+	errNoCursorSort = errors.New("cursors require the query to be sorted, use Sort()")
+	errNoCursorRow  = errors.New("no current row, call Next() before Cursor()")
+)
+
+// Cursor is an opaque value which represents the position of an
+// object in the results of a sorted query. Cursors are obtained
+// from Iter.Cursor and they might be passed to Query.After and
+// Query.Before to implement keyset pagination which, unlike Offset,
+// doesn't require the database to skip over the previous results.
+// Cursors only contain URL safe characters, so they might be used
+// as query parameters without any escaping.
+type Cursor string
+
+// After makes the query return only the results which come after
+// the object represented by the given cursor, which must have been
+// obtained from a query with the same sort fields. Note that, in
+// order to obtain stable results, the sort fields should uniquely
+// identify each object (e.g. add a Sort by the primary key as the
+// last one). Passing an empty cursor has no effect.
+//
+// With more than one sort field, After requires a driver with support
+// for OR queries. Drivers without it (like datastore) only support
+// cursors with one sort field.
+func (q *Query) After(c Cursor) *Query {
+	q.cursor = c
+	q.cursorBefore = false
+	return q
+}
+
+// Before makes the query return only the results which come before
+// the object represented by the given cursor, which must have been
+// obtained from a query with the same sort fields. Before returns the
+// results closest to the cursor, so along with Limit it can be used
+// to obtain the previous page. When using All, the results are
+// returned in the order specified by Sort, while Iter returns them
+// in the reverse order (closest to the cursor first). See After for
+// more information.
+func (q *Query) Before(c Cursor) *Query {
+	q.cursor = c
+	q.cursorBefore = true
+	return q
+}
+
+// Cursor returns the cursor which represents the last result
+// returned by Next. This cursor might be passed to Query.After
+// or Query.Before to continue the iteration from this result
+// in a different query. Note that the query must be sorted.
+func (i *Iter) Cursor() (Cursor, error) {
+	if i.out == nil {
+		return "", errNoCursorRow
+	}
+	return i.q.cursorFor(i.out)
+}
+
+// cursorSort returns the sort used by the query, taking into
+// account if the sort must be reversed because of Before.
+func (q *Query) cursorSort() []driver.Sort {
+	if q.cursor == "" || !q.cursorBefore {
+		return q.sort
+	}
+	reversed := make([]driver.Sort, len(q.sort))
+	for ii, v := range q.sort {
+		reversed[ii] = &querySort{
+			field: v.Field(),
+			dir:   -v.Direction(),
+		}
+	}
+	return reversed
+}
+
+// cursorCondition returns the condition which selects the
+// results after (or before) the query cursor.
+func (q *Query) cursorCondition() (query.Q, error) {
+	if q.cursor == "" {
+		return nil, nil
+	}
+	values, err := q.decodeCursor(q.cursor)
+	if err != nil {
+		return nil, err
+	}
+	conditions := make([]query.Q, len(q.sort))
+	for ii, v := range q.sort {
+		var c query.Q
+		if (v.Direction() == driver.ASC) != q.cursorBefore {
+			c = Gt(v.Field(), values[ii])
+		} else {
+			c = Lt(v.Field(), values[ii])
+		}
+		if ii > 0 {
+			eqs := make([]query.Q, 0, ii+1)
+			for jj := 0; jj < ii; jj++ {
+				eqs = append(eqs, Eq(q.sort[jj].Field(), values[jj]))
+			}
+			c = And(append(eqs, c)...)
+		}
+		conditions[ii] = c
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	if q.orm.driver.Capabilities()&driver.CAP_OR == 0 {
+		return nil, fmt.Errorf("ORM driver %T does not support cursors with multiple sort fields", q.orm.driver)
+	}
+	return Or(conditions...), nil
+}
+
+// cursorFor returns the cursor for the given objects, which
+// must be the ones passed to Iter.Next.
+func (q *Query) cursorFor(out []interface{}) (Cursor, error) {
+	if len(q.sort) == 0 {
+		return "", errNoCursorSort
+	}
+	values := make([]interface{}, len(q.sort))
+	for ii, v := range q.sort {
+		val, err := q.sortValue(out, v.Field())
+		if err != nil {
+			return "", err
+		}
+		values[ii] = val.Interface()
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return Cursor(base64.RawURLEncoding.EncodeToString(data)), nil
+}
+
+// sortValue returns the value for the given sort field
+// in the objects passed to Iter.Next.
+func (q *Query) sortValue(out []interface{}, field string) (reflect.Value, error) {
+	idx := 0
+	for cur := q.model; cur != nil && idx < len(out); idx++ {
+		if n, err := cur.model.fieldIndex(field); err == nil {
+			obj := reflect.ValueOf(out[idx])
+			for obj.Kind() == reflect.Ptr && obj.Elem().Kind() == reflect.Ptr {
+				obj = obj.Elem()
+			}
+			val := q.orm.fieldByIndex(obj, cur.fields.Indexes[n])
+			if !val.IsValid() {
+				return val, fmt.Errorf("can't obtain value for sort field %q", field)
+			}
+			return val, nil
+		}
+		if cur.join == nil {
+			break
+		}
+		cur = cur.join.model
+	}
+	return reflect.Value{}, errCantMap(field)
+}
+
+// decodeCursor returns the values for the query sort fields
+// encoded in the given cursor.
+func (q *Query) decodeCursor(c Cursor) ([]interface{}, error) {
+	if len(q.sort) == 0 {
+		return nil, errNoCursorSort
+	}
+	data, err := base64.RawURLEncoding.DecodeString(string(c))
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw) != len(q.sort) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]interface{}, len(raw))
+	for ii, v := range q.sort {
+		_, typ, err := q.model.Map(v.Field())
+		if err != nil {
+			return nil, err
+		}
+		val := reflect.New(typ)
+		if err := json.Unmarshal(raw[ii], val.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[ii] = val.Elem().Interface()
+	}
+	return values, nil
+}
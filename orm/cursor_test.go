@@ -0,0 +1,98 @@
+package orm
+
+import (
+	"testing"
+
+	"gnd.la/orm/driver"
+)
+
+type CursorItem struct {
+	Id    int64 `orm:",primary_key,auto_increment"`
+	Group int
+	Name  string
+}
+
+func cursorPage(t *testing.T, q *Query, size int) ([]*CursorItem, Cursor, Cursor) {
+	var items []*CursorItem
+	var first, last Cursor
+	iter := q.Limit(size).Iter()
+	var item *CursorItem
+	for iter.Next(&item) {
+		c, err := iter.Cursor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == "" {
+			first = c
+		}
+		last = c
+		items = append(items, item)
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return items, first, last
+}
+
+func expectCursorItems(t *testing.T, items []*CursorItem, ids ...int64) {
+	if len(items) != len(ids) {
+		t.Errorf("expecting %d items, got %d", len(ids), len(items))
+		return
+	}
+	for ii, v := range items {
+		if v.Id != ids[ii] {
+			t.Errorf("expecting item %d at position %d, got %d", ids[ii], ii, v.Id)
+		}
+	}
+}
+
+func testCursor(t *testing.T, o *Orm) {
+	tbl := o.mustRegister((*CursorItem)(nil), &Options{
+		Table: "test_cursor",
+	})
+	o.mustInitialize()
+	// Ids 1 to 7. Groups are: 1 => 3, 5, 7; 2 => 2, 4, 6; 3 => 1
+	groups := []int{3, 2, 1, 2, 1, 2, 1}
+	items := make([]*CursorItem, len(groups))
+	for ii, v := range groups {
+		items[ii] = &CursorItem{Group: v}
+	}
+	o.MustInsertAll(items)
+	query := func() *Query {
+		return o.Table(tbl).Sort("Id", ASC)
+	}
+	page, _, last := cursorPage(t, query(), 3)
+	expectCursorItems(t, page, 1, 2, 3)
+	page, first, last := cursorPage(t, query().After(last), 3)
+	expectCursorItems(t, page, 4, 5, 6)
+	page, _, _ = cursorPage(t, query().After(last), 3)
+	expectCursorItems(t, page, 7)
+	// Previous page, in the right order with All
+	var prev []*CursorItem
+	if err := query().Before(first).Limit(3).All(&prev); err != nil {
+		t.Fatal(err)
+	}
+	expectCursorItems(t, prev, 1, 2, 3)
+	if err := o.Table(tbl).Sort("Id", DESC).Before(first).Limit(2).All(&prev); err != nil {
+		t.Fatal(err)
+	}
+	expectCursorItems(t, prev[3:], 6, 5)
+	// Multiple sort fields
+	if o.driver.Capabilities()&driver.CAP_OR != 0 {
+		multi := func() *Query {
+			return o.Table(tbl).Sort("Group", ASC).Sort("Id", DESC)
+		}
+		page, _, last = cursorPage(t, multi(), 2)
+		expectCursorItems(t, page, 7, 5)
+		page, _, last = cursorPage(t, multi().After(last), 2)
+		expectCursorItems(t, page, 3, 6)
+		page, _, _ = cursorPage(t, multi().After(last), 10)
+		expectCursorItems(t, page, 4, 2, 1)
+	}
+	if _, err := query().After("invalid").One(&CursorItem{}); err != ErrInvalidCursor {
+		t.Errorf("expecting ErrInvalidCursor, got %v", err)
+	}
+	if _, err := o.Table(tbl).After(last).One(&CursorItem{}); err == nil {
+		t.Error("expecting an error when using a cursor without sorting")
+	}
+}
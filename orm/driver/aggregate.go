@@ -0,0 +1,39 @@
+package driver
+
+import (
+	"gnd.la/orm/query"
+)
+
+// AggregateFunc indicates the function used to
+// compute an Aggregate.
+type AggregateFunc int
+
+const (
+	// These constants are documented in the gnd.la/orm package
+	COUNT AggregateFunc = iota + 1
+	COUNT_DISTINCT
+	SUM
+	AVG
+	MIN
+	MAX
+)
+
+type Aggregate interface {
+	// Func returns the function used to compute the aggregate.
+	Func() AggregateFunc
+	// Field returns the qualified name of the aggregated field.
+	// It's empty for COUNT aggregates, which count all the rows.
+	Field() string
+	// Name returns the name used to refer to the aggregate in
+	// the Having query and the sort fields.
+	Name() string
+}
+
+// Aggregation represents an aggregate query. When iterating over
+// the results, the Iter receives a pointer for each field in
+// GroupBy, followed by a pointer for each value in Aggregates.
+type Aggregation struct {
+	Aggregates []Aggregate
+	GroupBy    []string
+	Having     query.Q
+}
@@ -31,4 +31,11 @@ const (
 	CAP_DEFAULTS
 	// Can have database level defaults for TEXT fields (unbounded strings).
 	CAP_DEFAULTS_TEXT
+	// Can compute aggregates and group results.
+	CAP_AGGREGATE
+	// Can nest transactions using savepoints.
+	CAP_SAVEPOINT
+	// Enforces references using foreign keys, including
+	// their ON DELETE actions.
+	CAP_FOREIGN_KEY
 )
@@ -6,13 +6,30 @@ import (
 )
 
 type Conn interface {
-	Query(m Model, q query.Q, sort []Sort, limit int, offset int) Iter
+	Query(m Model, q query.Q, fields []string, sort []Sort, limit int, offset int) Iter
 	Count(m Model, q query.Q, limit int, offset int) (uint64, error)
 	Exists(m Model, q query.Q) (bool, error)
+	Aggregate(m Model, q query.Q, agg *Aggregation, sort []Sort, limit int, offset int) Iter
 	Insert(m Model, data interface{}) (Result, error)
 	Operate(m Model, q query.Q, ops []*operation.Operation) (Result, error)
-	Update(m Model, q query.Q, data interface{}) (Result, error)
+	Update(m Model, q query.Q, data interface{}, fields []string) (Result, error)
 	Upsert(m Model, q query.Q, data interface{}) (Result, error)
 	Delete(m Model, q query.Q) (Result, error)
 	Connection() interface{}
 }
+
+// BatchInserter is an optional interface which might be
+// implemented by a Conn to insert several objects of the same
+// model using fewer round trips to the database. Objects passed
+// to a Conn which doesn't implement it are inserted one by one.
+type BatchInserter interface {
+	// InsertAll inserts all the objects in objs, which are all
+	// of the model m. If the model has an autoincrement primary
+	// key and the ids assigned to the objects can be determined,
+	// they're returned in ids, in the same order than objs.
+	// Otherwise, ids is nil.
+	InsertAll(m Model, objs []interface{}) (res Result, ids []int64, err error)
+	// UpsertAll works like InsertAll, but objects with the same
+	// primary key than an existing one replace it.
+	UpsertAll(m Model, objs []interface{}) (res Result, ids []int64, err error)
+}
@@ -34,7 +34,10 @@ func (d *Driver) Initialize(ms []driver.Model) error {
 	return nil
 }
 
-func (d *Driver) Query(m driver.Model, q query.Q, sort []driver.Sort, limit int, offset int) driver.Iter {
+func (d *Driver) Query(m driver.Model, q query.Q, fields []string, sort []driver.Sort, limit int, offset int) driver.Iter {
+	if len(fields) > 0 {
+		return &Iter{err: fmt.Errorf("datastore driver does not support selecting fields")}
+	}
 	dq, err := d.makeQuery(m, q, sort, limit, offset)
 	if err != nil {
 		return &Iter{err: err}
@@ -60,6 +63,10 @@ func (d *Driver) Exists(m driver.Model, q query.Q) (bool, error) {
 	return c != 0, err
 }
 
+func (d *Driver) Aggregate(m driver.Model, q query.Q, agg *driver.Aggregation, sort []driver.Sort, limit int, offset int) driver.Iter {
+	return &Iter{err: fmt.Errorf("datastore driver does not support Aggregate")}
+}
+
 func (d *Driver) Insert(m driver.Model, data interface{}) (driver.Result, error) {
 	var id int64
 	fields := m.Fields()
@@ -99,11 +106,80 @@ func (d *Driver) Insert(m driver.Model, data interface{}) (driver.Result, error)
 	return &result{key: key, count: 1}, nil
 }
 
+// maxBatchSize is the maximum number of entities
+// which can be put in a single call to PutMulti.
+const maxBatchSize = 500
+
+func (d *Driver) InsertAll(m driver.Model, objs []interface{}) (driver.Result, []int64, error) {
+	fields := m.Fields()
+	name := m.Table()
+	parent := d.parentKey(m)
+	ids := make([]int64, len(objs))
+	var pkVals []reflect.Value
+	missing := 0
+	for ii, v := range objs {
+		var pkVal reflect.Value
+		if fields.PrimaryKey >= 0 {
+			if p := d.primaryKey(fields, v); p.IsValid() && types.Kind(p.Kind()) == types.Int {
+				ids[ii] = p.Int()
+				pkVal = p
+			}
+		}
+		if ids[ii] == 0 {
+			missing++
+		}
+		pkVals = append(pkVals, pkVal)
+	}
+	if missing > 0 {
+		// Allocate all the required ids at once
+		low, _, err := datastore.AllocateIDs(d.c, name, parent, missing)
+		if err != nil {
+			return nil, nil, err
+		}
+		for ii, v := range ids {
+			if v == 0 {
+				ids[ii] = low
+				if fields.AutoincrementPk && pkVals[ii].IsValid() {
+					pkVals[ii].SetInt(low)
+				}
+				low++
+			}
+		}
+	}
+	keys := make([]*datastore.Key, len(objs))
+	for ii, v := range ids {
+		keys[ii] = datastore.NewKey(d.c, name, "", v, parent)
+	}
+	for start := 0; start < len(objs); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(objs) {
+			end = len(objs)
+		}
+		log.Debugf("DATASTORE: put multi %d %s entities", end-start, name)
+		if _, err := datastore.PutMulti(d.c, keys[start:end], objs[start:end]); err != nil {
+			return nil, nil, err
+		}
+	}
+	var last *datastore.Key
+	if len(keys) > 0 {
+		last = keys[len(keys)-1]
+	}
+	return &result{key: last, count: len(objs)}, ids, nil
+}
+
+func (d *Driver) UpsertAll(m driver.Model, objs []interface{}) (driver.Result, []int64, error) {
+	// Put replaces any existing entity with the same key
+	return d.InsertAll(m, objs)
+}
+
 func (d *Driver) Operate(m driver.Model, q query.Q, ops []*operation.Operation) (driver.Result, error) {
 	return nil, fmt.Errorf("datastore driver does not support Operate")
 }
 
-func (d *Driver) Update(m driver.Model, q query.Q, data interface{}) (driver.Result, error) {
+func (d *Driver) Update(m driver.Model, q query.Q, data interface{}, fields []string) (driver.Result, error) {
+	if len(fields) > 0 {
+		return nil, fmt.Errorf("datastore driver does not support updating selected fields")
+	}
 	keys, err := d.getKeys(m, q)
 	if err != nil {
 		return nil, err
@@ -6,9 +6,42 @@ import (
 	"gnd.la/util/structs"
 )
 
+// ReferenceAction indicates what happens to the objects which
+// reference another one when the latter is deleted.
+type ReferenceAction int
+
+const (
+	// NoAction leaves the referencing objects untouched. Drivers
+	// with CAP_FOREIGN_KEY will fail to delete referenced objects.
+	NoAction ReferenceAction = iota
+	// Cascade deletes the referencing objects too.
+	Cascade
+	// SetNull sets the referencing field to NULL.
+	SetNull
+	// Restrict makes deleting a referenced object fail.
+	Restrict
+)
+
+// String returns the action as used in SQL (e.g. SET NULL).
+func (a ReferenceAction) String() string {
+	switch a {
+	case NoAction:
+		return "NO ACTION"
+	case Cascade:
+		return "CASCADE"
+	case SetNull:
+		return "SET NULL"
+	case Restrict:
+		return "RESTRICT"
+	}
+	return "unknown ReferenceAction"
+}
+
 type Reference struct {
 	Model Model
 	Field string
+	// Action performed when the referenced object is deleted.
+	OnDelete ReferenceAction
 }
 
 type Fields struct {
@@ -26,6 +59,16 @@ type Fields struct {
 	AutoincrementPk bool
 	// The fields which make the composite primary key, if any
 	CompositePrimaryKey []int
+	// The index of the field tagged with created, which is set
+	// to the current time on insert (-1 if there's no such field)
+	Created int
+	// The index of the field tagged with updated, which is set
+	// to the current time on insert and update (-1 if there's no
+	// such field)
+	Updated int
+	// The index of the field tagged with soft_delete, which marks
+	// the deleted objects (-1 if the model is deleted normally)
+	SoftDelete int
 	// Model methods called by the ORM
 	Methods *Methods
 	// Other models referenced by this model. The key
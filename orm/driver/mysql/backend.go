@@ -1,10 +1,13 @@
 package mysql
 
 import (
+	"bytes"
+	stdsql "database/sql"
 	"fmt"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"gnd.la/config"
@@ -29,6 +32,9 @@ var (
 
 type Backend struct {
 	sql.SqlBackend
+	mu sync.Mutex
+	// consecutive caches the results of consecutiveIds
+	consecutive map[*stdsql.DB]bool
 }
 
 func (b *Backend) Name() string {
@@ -67,8 +73,12 @@ func (b *Backend) DefineField(db *sql.DB, m driver.Model, table *sql.Table, fiel
 		refTable := ref.References.Table()
 		refField := ref.References.Field()
 		fkName := db.QuoteIdentifier(fmt.Sprintf("%s_%s_%s_%s", m.Table(), field.Name, refTable, refField))
-		cons = append(cons, fmt.Sprintf("FOREIGN KEY %s(%s) REFERENCES %s(%s)", fkName, db.QuoteIdentifier(field.Name),
-			db.QuoteIdentifier(refTable), db.QuoteIdentifier(refField)))
+		fk := fmt.Sprintf("FOREIGN KEY %s(%s) REFERENCES %s(%s)", fkName, db.QuoteIdentifier(field.Name),
+			db.QuoteIdentifier(refTable), db.QuoteIdentifier(refField))
+		if ref.OnDelete != driver.NoAction {
+			fk += " ON DELETE " + ref.OnDelete.String()
+		}
+		cons = append(cons, fk)
 	}
 	return strings.Replace(def, "AUTOINCREMENT", "AUTO_INCREMENT", -1), cons, nil
 }
@@ -90,6 +100,105 @@ func (b *Backend) AlterField(db *sql.DB, m driver.Model, table *sql.Table, oldFi
 	return err
 }
 
+func (b *Backend) RenameField(db *sql.DB, table string, oldName string, newName string) error {
+	// MySQL < 8.0 does not support RENAME COLUMN, so
+	// the field must be redefined using CHANGE COLUMN.
+	var typ, nullable, extra string
+	var def *string
+	err := db.QueryRow("SELECT COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA FROM INFORMATION_SCHEMA.COLUMNS "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?", table, oldName).Scan(&typ, &nullable, &def, &extra)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("table %s has no field named %s", table, oldName)
+		}
+		return err
+	}
+	fsql := fmt.Sprintf("%s %s", db.QuoteIdentifier(newName), typ)
+	if nullable != "YES" {
+		fsql += " NOT NULL"
+	}
+	if def != nil {
+		if _, err := strconv.ParseFloat(*def, 64); err == nil || strings.ToUpper(*def) == "CURRENT_TIMESTAMP" {
+			fsql += " DEFAULT " + *def
+		} else {
+			fsql += " DEFAULT " + db.QuoteString(*def)
+		}
+	}
+	if extra != "" {
+		fsql += " " + strings.ToUpper(extra)
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s", db.QuoteIdentifier(table), db.QuoteIdentifier(oldName), fsql))
+	return err
+}
+
+func (b *Backend) InsertAll(db *sql.DB, m driver.Model, query string, count int, args ...interface{}) (driver.Result, []int64, error) {
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ids []int64
+	if m.Fields().AutoincrementPk && b.consecutiveIds(db) {
+		// MySQL returns the id of the first inserted row.
+		if first, err := res.LastInsertId(); err == nil && first != 0 {
+			ids = make([]int64, count)
+			for ii := range ids {
+				ids[ii] = first + int64(ii)
+			}
+		}
+	}
+	return res, ids, nil
+}
+
+// consecutiveIds returns true iff the rows inserted by a multi-row
+// INSERT are assigned consecutive ids. InnoDB only guarantees this
+// with the traditional (0) and consecutive (1) lock modes. With the
+// interleaved (2) mode, which is the default since MySQL 8.0, the ids
+// of concurrent INSERTs might be interleaved, so they can't be
+// determined from the first one.
+func (b *Backend) consecutiveIds(db *sql.DB) bool {
+	key := db.DB()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	consecutive, ok := b.consecutive[key]
+	if !ok {
+		var mode int
+		if err := db.QueryRow("SELECT @@innodb_autoinc_lock_mode").Scan(&mode); err == nil {
+			consecutive = mode == 0 || mode == 1
+		}
+		if b.consecutive == nil {
+			b.consecutive = make(map[*stdsql.DB]bool)
+		}
+		b.consecutive[key] = consecutive
+	}
+	return consecutive
+}
+
+func (b *Backend) UpsertClause(db *sql.DB, keys []string, fields []string) (string, error) {
+	// MySQL uses the PRIMARY KEY or any UNIQUE index to detect conflicts,
+	// so keys are not required.
+	if len(fields) == 0 {
+		// Just ignore the conflicting rows
+		fields = keys[:1]
+	}
+	var buf bytes.Buffer
+	buf.WriteString("ON DUPLICATE KEY UPDATE ")
+	for ii, v := range fields {
+		if ii > 0 {
+			buf.WriteByte(',')
+		}
+		name := db.QuoteIdentifier(v)
+		buf.WriteString(name)
+		buf.WriteString("=VALUES(")
+		buf.WriteString(name)
+		buf.WriteByte(')')
+	}
+	return buf.String(), nil
+}
+
+func (b *Backend) MaxParameters() int {
+	return 65535
+}
+
 func (b *Backend) HasIndex(db *sql.DB, m driver.Model, idx *index.Index, name string) (bool, error) {
 	rows, err := db.Query("SHOW INDEX FROM ? WHERE Key_name = ?", m.Table(), name)
 	if err != nil {
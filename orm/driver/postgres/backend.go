@@ -87,6 +87,35 @@ func (b *Backend) Insert(db *sql.DB, m driver.Model, query string, args ...inter
 	return db.Exec(query, args...)
 }
 
+func (b *Backend) InsertAll(db *sql.DB, m driver.Model, query string, count int, args ...interface{}) (driver.Result, []int64, error) {
+	fields := m.Fields()
+	if !fields.AutoincrementPk {
+		res, err := db.Exec(query, args...)
+		return res, nil, err
+	}
+	rows, err := db.Query(query+" RETURNING "+fields.MNames[fields.PrimaryKey], args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	ids := make([]int64, 0, count)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return batchInsertResult(ids), ids, nil
+}
+
+func (b *Backend) MaxParameters() int {
+	return 65535
+}
+
 func (b *Backend) HasIndex(db *sql.DB, m driver.Model, idx *index.Index, name string) (bool, error) {
 	var exists int
 	err := db.QueryRow("SELECT 1 FROM pg_class WHERE relname = $1 AND relkind = 'i'", name).Scan(&exists)
@@ -0,0 +1,157 @@
+package sql
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"gnd.la/orm/driver"
+	"gnd.la/orm/query"
+)
+
+var (
+	int64Type = reflect.TypeOf(int64(0))
+)
+
+// aggregateModel wraps a driver.Model, mapping the aggregate
+// names to their SQL expressions, so they can be used in the
+// HAVING and ORDER BY clauses.
+type aggregateModel struct {
+	driver.Model
+	exprs map[string]string
+	types map[string]reflect.Type
+}
+
+func (m *aggregateModel) Map(qname string) (string, reflect.Type, error) {
+	if expr, ok := m.exprs[qname]; ok {
+		return expr, m.types[qname], nil
+	}
+	return m.Model.Map(qname)
+}
+
+func (d *Driver) Aggregate(m driver.Model, q query.Q, agg *driver.Aggregation, sort []driver.Sort, limit int, offset int) driver.Iter {
+	query, params, err := d.aggregate(m, q, agg, sort, limit, offset)
+	if err != nil {
+		return &aggregateIter{err: err}
+	}
+	rows, err := d.readDB().Query(buftos(query), params...)
+	if err != nil {
+		return &aggregateIter{err: err}
+	}
+	return &aggregateIter{rows: rows}
+}
+
+func (d *Driver) aggregateExpr(m driver.Model, agg driver.Aggregate) (string, reflect.Type, error) {
+	if agg.Func() == driver.COUNT && agg.Field() == "" {
+		return "COUNT(*)", int64Type, nil
+	}
+	dbName, typ, err := m.Map(agg.Field())
+	if err != nil {
+		return "", nil, err
+	}
+	switch agg.Func() {
+	case driver.COUNT:
+		return "COUNT(" + dbName + ")", int64Type, nil
+	case driver.COUNT_DISTINCT:
+		return "COUNT(DISTINCT " + dbName + ")", int64Type, nil
+	case driver.SUM:
+		return "SUM(" + dbName + ")", typ, nil
+	case driver.AVG:
+		return "AVG(" + dbName + ")", reflect.TypeOf(float64(0)), nil
+	case driver.MIN:
+		return "MIN(" + dbName + ")", typ, nil
+	case driver.MAX:
+		return "MAX(" + dbName + ")", typ, nil
+	}
+	return "", nil, fmt.Errorf("aggregate function %d is not supported", agg.Func())
+}
+
+func (d *Driver) aggregate(m driver.Model, q query.Q, agg *driver.Aggregation, sort []driver.Sort, limit int, offset int) (*bytes.Buffer, []interface{}, error) {
+	am := &aggregateModel{
+		Model: m,
+		exprs: make(map[string]string, len(agg.Aggregates)),
+		types: make(map[string]reflect.Type, len(agg.Aggregates)),
+	}
+	fields := make([]string, 0, len(agg.GroupBy)+len(agg.Aggregates))
+	groupBy := make([]string, len(agg.GroupBy))
+	for ii, v := range agg.GroupBy {
+		dbName, _, err := m.Map(v)
+		if err != nil {
+			return nil, nil, err
+		}
+		groupBy[ii] = dbName
+		fields = append(fields, dbName)
+	}
+	for _, v := range agg.Aggregates {
+		expr, typ, err := d.aggregateExpr(m, v)
+		if err != nil {
+			return nil, nil, err
+		}
+		if name := v.Name(); name != "" {
+			am.exprs[name] = expr
+			am.types[name] = typ
+		}
+		fields = append(fields, expr)
+	}
+	if len(fields) == 0 {
+		return nil, nil, fmt.Errorf("aggregate query on %s has no fields nor aggregates", m.Table())
+	}
+	buf := getBuffer()
+	var params []interface{}
+	if err := d.SelectStmt(buf, &params, fields, false, m); err != nil {
+		return nil, nil, err
+	}
+	qParams, err := d.where(buf, m, q, len(params))
+	if err != nil {
+		return nil, nil, err
+	}
+	params = append(params, qParams...)
+	if len(groupBy) > 0 {
+		buf.WriteString(" GROUP BY ")
+		for _, v := range groupBy {
+			buf.WriteString(v)
+			buf.WriteByte(',')
+		}
+		buf.Truncate(buf.Len() - 1)
+	}
+	if !isNil(agg.Having) {
+		buf.WriteString(" HAVING ")
+		if err := d.condition(buf, &params, am, agg.Having, 0); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := d.sortLimitOffset(buf, am, sort, limit, offset); err != nil {
+		return nil, nil, err
+	}
+	return buf, params, nil
+}
+
+// aggregateIter scans the results of an aggregate query
+// directly into the values passed to Next.
+type aggregateIter struct {
+	rows *sql.Rows
+	err  error
+}
+
+func (i *aggregateIter) Next(out ...interface{}) bool {
+	if i.err == nil && i.rows != nil && i.rows.Next() {
+		i.err = i.rows.Scan(out...)
+		return i.err == nil
+	}
+	return false
+}
+
+func (i *aggregateIter) Err() error {
+	if i.err == nil && i.rows != nil {
+		return i.rows.Err()
+	}
+	return i.err
+}
+
+func (i *aggregateIter) Close() error {
+	if i.rows != nil {
+		return i.rows.Close()
+	}
+	return nil
+}
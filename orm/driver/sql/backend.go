@@ -1,6 +1,7 @@
 package sql
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"strings"
@@ -53,9 +54,25 @@ type Backend interface {
 	AddFields(db *DB, m driver.Model, prevTable *Table, newTable *Table, fields []*Field) error
 	// Alter field changes oldField to newField, potentially including the name.
 	AlterField(db *DB, m driver.Model, table *Table, oldField *Field, newField *Field) error
+	// RenameField renames the field oldName to newName in the given table.
+	RenameField(db *DB, table string, oldName string, newName string) error
+	// DropField removes the field with the given name from the given table.
+	DropField(db *DB, table string, name string) error
 	// Insert performs an insert on the given database for the given model fields.
 	// Most drivers should just return db.Exec(query, args...).
 	Insert(*DB, driver.Model, string, ...interface{}) (driver.Result, error)
+	// InsertAll performs a multi-row insert of count rows on the given database for
+	// the given model fields. If the model has an autoincrement primary key and the
+	// backend is able to determine the ids assigned to the rows, they must be returned
+	// in the second value, in the same order than the rows. Otherwise, it must be nil.
+	InsertAll(db *DB, m driver.Model, query string, count int, args ...interface{}) (driver.Result, []int64, error)
+	// UpsertClause returns the clause which is appended to an INSERT statement to
+	// update the existing rows which conflict with the inserted ones on the given keys,
+	// setting the given fields to the inserted values. Note that fields might be empty.
+	UpsertClause(db *DB, keys []string, fields []string) (string, error)
+	// MaxParameters returns the maximum number of parameters which might be used
+	// in a single statement.
+	MaxParameters() int
 	// Returns the db type of the given field (e.g. INTEGER)
 	FieldType(reflect.Type, *structs.Tag) (string, error)
 	// Types that need to be transformed (e.g. sqlite transforms time.Time and bool to integer)
@@ -76,6 +93,26 @@ type Backend interface {
 	TransformOutValue(reflect.Value) (interface{}, error)
 }
 
+// TableRebuilder is implemented by backends which alter some tables by
+// rebuilding them (e.g. sqlite), which requires disabling foreign keys,
+// so dropping the previous table doesn't trigger their actions. Foreign
+// keys can't be disabled inside a transaction, so callers which alter
+// tables in a transaction (like gnd.la/orm/migrations) must disable them
+// on a dedicated connection (see DB.Conn) before starting it and check
+// them before committing it.
+type TableRebuilder interface {
+	// ForeignKeys returns whether foreign keys
+	// are enabled in the given connection.
+	ForeignKeys(db *DB) (bool, error)
+	// SetForeignKeys enables or disables foreign keys in
+	// the given connection. It has no effect inside a
+	// transaction.
+	SetForeignKeys(db *DB, enabled bool) error
+	// CheckForeignKeys returns an error if any foreign
+	// key in the database is violated.
+	CheckForeignKeys(db *DB) error
+}
+
 const placeholders = "?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?"
 
 type SqlBackend struct {
@@ -158,6 +195,9 @@ func (b *SqlBackend) Inspect(db *DB, m driver.Model, schema string) (*Table, err
 		fields = append(fields, &f)
 		fieldsByName[f.Name] = &f
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	// Field constraints
 	cq := fmt.Sprintf("SELECT C.CONSTRAINT_NAME, CONSTRAINT_TYPE, COLUMN_NAME "+
 		"FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS C JOIN "+
@@ -192,10 +232,14 @@ func (b *SqlBackend) Inspect(db *DB, m driver.Model, schema string) (*Table, err
 			return nil, fmt.Errorf("unknown constraint type %s on field %s in table %s", constraintType, name, m.Table())
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	if len(foreignKeys) > 0 {
 		// Resolve FKs
 		fks := strings.Join(generic.Map(generic.Keys(foreignKeys).([]string), db.QuoteString).([]string), ", ")
-		fq := fmt.Sprintf("SELECT CONSTRAINT_NAME, TABLE_NAME, COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE WHERE CONSTRAINT_NAME IN (%s)", fks)
+		fq := fmt.Sprintf("SELECT CONSTRAINT_NAME, TABLE_NAME, COLUMN_NAME FROM INFORMATION_SCHEMA.KEY_COLUMN_USAGE "+
+			"WHERE CONSTRAINT_NAME IN (%s) AND CONSTRAINT_SCHEMA = %s", fks, s)
 		rows, err := db.Query(fq)
 		if err != nil {
 			return nil, err
@@ -213,6 +257,30 @@ func (b *SqlBackend) Inspect(db *DB, m driver.Model, schema string) (*Table, err
 			field := fieldsByName[fieldName]
 			field.Constraints = append(field.Constraints, &Constraint{Type: ConstraintForeignKey, References: MakeReference(tableName, columnName)})
 		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		// Resolve ON DELETE actions
+		dq := fmt.Sprintf("SELECT CONSTRAINT_NAME, DELETE_RULE FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS "+
+			"WHERE CONSTRAINT_NAME IN (%s) AND CONSTRAINT_SCHEMA = %s", fks, s)
+		rows, err = db.Query(dq)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var constraintName string
+			var rule string
+			if err := rows.Scan(&constraintName, &rule); err != nil {
+				return nil, err
+			}
+			if ref := fieldsByName[foreignKeys[constraintName]].Constraint(ConstraintForeignKey); ref != nil {
+				ref.OnDelete = ParseReferenceAction(rule)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
 	}
 	return &Table{Fields: fields}, nil
 }
@@ -237,6 +305,9 @@ func (b *SqlBackend) DefineField(db *DB, m driver.Model, table *Table, f *Field)
 	if ref := f.Constraint(ConstraintForeignKey); ref != nil {
 		s += fmt.Sprintf(" REFERENCES %s(%s)",
 			db.QuoteIdentifier(ref.References.Table()), db.QuoteIdentifier(ref.References.Field()))
+		if ref.OnDelete != driver.NoAction {
+			s += " ON DELETE " + ref.OnDelete.String()
+		}
 	}
 	return s, nil, nil
 }
@@ -294,10 +365,57 @@ func (b *SqlBackend) AlterField(db *DB, m driver.Model, table *Table, oldField *
 	return fmt.Errorf("SQL backend %s can't ALTER fields", db.Backend().Name())
 }
 
+func (b *SqlBackend) RenameField(db *DB, table string, oldName string, newName string) error {
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", db.QuoteIdentifier(table),
+		db.QuoteIdentifier(oldName), db.QuoteIdentifier(newName)))
+	return err
+}
+
+func (b *SqlBackend) DropField(db *DB, table string, name string) error {
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", db.QuoteIdentifier(table), db.QuoteIdentifier(name)))
+	return err
+}
+
 func (b *SqlBackend) Insert(db *DB, m driver.Model, query string, args ...interface{}) (driver.Result, error) {
 	return db.Exec(query, args...)
 }
 
+func (b *SqlBackend) InsertAll(db *DB, m driver.Model, query string, count int, args ...interface{}) (driver.Result, []int64, error) {
+	res, err := db.Exec(query, args...)
+	return res, nil, err
+}
+
+func (b *SqlBackend) UpsertClause(db *DB, keys []string, fields []string) (string, error) {
+	var buf bytes.Buffer
+	buf.WriteString("ON CONFLICT (")
+	for ii, v := range keys {
+		if ii > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(db.QuoteIdentifier(v))
+	}
+	buf.WriteByte(')')
+	if len(fields) == 0 {
+		buf.WriteString(" DO NOTHING")
+		return buf.String(), nil
+	}
+	buf.WriteString(" DO UPDATE SET ")
+	for ii, v := range fields {
+		if ii > 0 {
+			buf.WriteByte(',')
+		}
+		name := db.QuoteIdentifier(v)
+		buf.WriteString(name)
+		buf.WriteString("=excluded.")
+		buf.WriteString(name)
+	}
+	return buf.String(), nil
+}
+
+func (b *SqlBackend) MaxParameters() int {
+	return 999
+}
+
 func (b *SqlBackend) Transforms() []reflect.Type {
 	return nil
 }
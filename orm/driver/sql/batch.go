@@ -0,0 +1,277 @@
+package sql
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gnd.la/orm/driver"
+)
+
+type batchResult struct {
+	lastId   int64
+	affected int64
+}
+
+func (r *batchResult) LastInsertId() (int64, error) {
+	return r.lastId, nil
+}
+
+func (r *batchResult) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
+
+func (r *batchResult) add(res driver.Result) {
+	if id, err := res.LastInsertId(); err == nil && id != 0 {
+		r.lastId = id
+	}
+	if aff, err := res.RowsAffected(); err == nil {
+		r.affected += aff
+	}
+}
+
+type batchRow struct {
+	fields []string
+	values []interface{}
+	// index of the object in the batch
+	index int
+}
+
+// key returns a string which identifies the values of the given
+// keys in the row, or the empty string if the row doesn't include
+// all of them.
+func (r *batchRow) key(keys []string) string {
+	var buf bytes.Buffer
+	for _, k := range keys {
+		found := false
+		for ii, v := range r.fields {
+			if v == k {
+				buf.WriteString(keyValue(r.values[ii]))
+				buf.WriteByte(0)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ""
+		}
+	}
+	return buf.String()
+}
+
+// keyValue returns a string representation of v which is equal for
+// the values which are stored as the same value by the database.
+// Pointers are dereferenced, []byte compare by their contents and
+// time.Time by the instant they represent.
+func keyValue(v interface{}) string {
+	val := reflect.ValueOf(v)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return "nil"
+		}
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return "nil"
+	}
+	switch x := val.Interface().(type) {
+	case []byte:
+		return fmt.Sprintf("%q", x)
+	case time.Time:
+		return fmt.Sprintf("time(%d)", x.UnixNano())
+	}
+	return fmt.Sprintf("%#v", val.Interface())
+}
+
+// uniqueRows returns the rows without the ones which have the same
+// values for the given keys than a following row.
+func uniqueRows(rows []batchRow, keys []string) []batchRow {
+	last := make(map[string]int, len(rows))
+	for ii := range rows {
+		if k := rows[ii].key(keys); k != "" {
+			last[k] = ii
+		}
+	}
+	if len(last) == len(rows) {
+		return rows
+	}
+	unique := make([]batchRow, 0, len(rows))
+	for ii := range rows {
+		if k := rows[ii].key(keys); k == "" || last[k] == ii {
+			unique = append(unique, rows[ii])
+		}
+	}
+	return unique
+}
+
+func (d *Driver) InsertAll(m driver.Model, objs []interface{}) (driver.Result, []int64, error) {
+	return d.insertAll(m, objs, false)
+}
+
+func (d *Driver) UpsertAll(m driver.Model, objs []interface{}) (driver.Result, []int64, error) {
+	return d.insertAll(m, objs, true)
+}
+
+func (d *Driver) insertAll(m driver.Model, objs []interface{}, upsert bool) (driver.Result, []int64, error) {
+	fields := m.Fields()
+	var keys []string
+	if upsert {
+		if fields.PrimaryKey >= 0 {
+			keys = append(keys, fields.MNames[fields.PrimaryKey])
+		}
+		for _, v := range fields.CompositePrimaryKey {
+			keys = append(keys, fields.MNames[v])
+		}
+		if len(keys) == 0 {
+			return nil, nil, fmt.Errorf("can't upsert into %s because it has no primary key", m.Table())
+		}
+	}
+	rows := make([]batchRow, len(objs))
+	for ii, v := range objs {
+		_, names, values, err := d.saveParameters(m, v, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows[ii] = batchRow{names, values, ii}
+	}
+	if upsert {
+		// Some databases (e.g. postgres) can't update the same
+		// row twice in a statement, so only the last row for
+		// each key is kept, like saving them in order would do.
+		rows = uniqueRows(rows, keys)
+	}
+	var ids []int64
+	if fields.AutoincrementPk {
+		ids = make([]int64, len(objs))
+	}
+	res := &batchResult{}
+	maxParams := d.backend.MaxParameters()
+	for start := 0; start < len(rows); {
+		// Rows in the same statement must have the same fields,
+		// since some of them might be omitted when empty.
+		names := rows[start].fields
+		end := start + 1
+		params := len(names)
+		for end < len(rows) && params+len(names) <= maxParams && sameFields(names, rows[end].fields) {
+			params += len(names)
+			end++
+		}
+		if len(names) == 0 {
+			// No fields to insert, so we need a DEFAULT VALUES statement per row
+			for _, row := range rows[start:end] {
+				r, err := d.Insert(m, objs[row.index])
+				if err != nil {
+					return nil, nil, err
+				}
+				res.add(r)
+				if ids != nil {
+					if id, err := r.LastInsertId(); err == nil {
+						ids[row.index] = id
+					}
+				}
+			}
+			start = end
+			continue
+		}
+		chunk := rows[start:end]
+		values := make([]interface{}, 0, params)
+		buf := getBuffer()
+		buf.WriteString("INSERT INTO ")
+		buf.WriteByte('"')
+		buf.WriteString(m.Table())
+		buf.WriteString("\" (")
+		for ii, v := range names {
+			if ii > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('"')
+			buf.WriteString(v)
+			buf.WriteByte('"')
+		}
+		buf.WriteString(") VALUES ")
+		for ii, r := range chunk {
+			if ii > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('(')
+			for jj := range r.values {
+				if jj > 0 {
+					buf.WriteByte(',')
+				}
+				buf.WriteString(d.backend.Placeholder(len(values) + jj))
+			}
+			buf.WriteByte(')')
+			values = append(values, r.values...)
+		}
+		// If the rows don't include their keys, they can't conflict
+		// with existing ones, so there's no need to perform an upsert.
+		if upsert && containsFields(names, keys) {
+			var update []string
+			for _, v := range names {
+				if !hasField(keys, v) && (fields.Created < 0 || v != fields.MNames[fields.Created]) {
+					update = append(update, v)
+				}
+			}
+			clause, err := d.backend.UpsertClause(d.db, keys, update)
+			if err != nil {
+				putBuffer(buf)
+				return nil, nil, err
+			}
+			buf.WriteByte(' ')
+			buf.WriteString(clause)
+			r, err := d.db.Exec(buftos(buf), values...)
+			putBuffer(buf)
+			if err != nil {
+				return nil, nil, err
+			}
+			res.add(r)
+		} else {
+			r, chunkIds, err := d.backend.InsertAll(d.db, m, buftos(buf), len(chunk), values...)
+			putBuffer(buf)
+			if err != nil {
+				return nil, nil, err
+			}
+			res.add(r)
+			if ids != nil && len(chunkIds) == len(chunk) {
+				for ii, row := range chunk {
+					ids[row.index] = chunkIds[ii]
+				}
+			}
+		}
+		start = end
+	}
+	return res, ids, nil
+}
+
+func sameFields(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for ii, v := range a {
+		if b[ii] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func hasField(fields []string, name string) bool {
+	for _, v := range fields {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFields returns true iff all the fields
+// in sub are also in fields.
+func containsFields(fields []string, sub []string) bool {
+	for _, v := range sub {
+		if !hasField(fields, v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package sql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUniqueRows(t *testing.T) {
+	rows := []batchRow{
+		{fields: []string{"Key", "Value"}, values: []interface{}{"a", 1}, index: 0},
+		{fields: []string{"Value"}, values: []interface{}{2}, index: 1},
+		{fields: []string{"Key", "Value"}, values: []interface{}{"b", 3}, index: 2},
+		{fields: []string{"Key", "Value"}, values: []interface{}{"a", 4}, index: 3},
+		{fields: []string{"Value"}, values: []interface{}{5}, index: 4},
+	}
+	var indexes []int
+	for _, v := range uniqueRows(rows, []string{"Key"}) {
+		indexes = append(indexes, v.index)
+	}
+	if expected := []int{1, 2, 3, 4}; !reflect.DeepEqual(indexes, expected) {
+		t.Errorf("expecting rows %v, got %v", expected, indexes)
+	}
+	if unique := uniqueRows(rows[:3], []string{"Key"}); len(unique) != 3 {
+		t.Errorf("expecting 3 rows, got %d", len(unique))
+	}
+}
+
+func TestKeyValue(t *testing.T) {
+	a, b := "a", "a"
+	t1 := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	t2 := t1.In(time.FixedZone("CET", 3600))
+	equal := [][2]interface{}{
+		{&a, &b},
+		{&a, "a"},
+		{[]byte("a"), []byte("a")},
+		{t1, t2},
+		{nil, (*string)(nil)},
+	}
+	for _, v := range equal {
+		if k1, k2 := keyValue(v[0]), keyValue(v[1]); k1 != k2 {
+			t.Errorf("expecting equal keys for %v and %v, got %q and %q", v[0], v[1], k1, k2)
+		}
+	}
+	different := [][2]interface{}{
+		{[]byte("a"), []byte("b")},
+		{t1, t1.Add(time.Nanosecond)},
+		{nil, ""},
+	}
+	for _, v := range different {
+		if k1, k2 := keyValue(v[0]), keyValue(v[1]); k1 == k2 {
+			t.Errorf("expecting different keys for %v and %v, got %q", v[0], v[1], k1)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"database/sql"
 	"errors"
+	"fmt"
 	"hash/crc32"
+	"io"
 	"strings"
 	"sync"
 
@@ -31,6 +33,14 @@ type queryExecutor interface {
 	Executor
 }
 
+// dedicatedConn is a single connection from the pool,
+// see DB.Conn.
+type dedicatedConn interface {
+	queryExecutor
+	begin() (*sql.Tx, error)
+	Close() error
+}
+
 type cacheEntry struct {
 	sql  string
 	stmt *sql.Stmt
@@ -39,9 +49,17 @@ type cacheEntry struct {
 type DB struct {
 	// database/sql.DB
 	sqlDb *sql.DB
+	// non-nil only when bound to a single
+	// connection, see Conn.
+	dedicated dedicatedConn
 	// non-nil only when in transaction
 	tx     *sql.Tx
 	txDone bool
+	// non-empty only when in a nested transaction,
+	// see Begin.
+	savepoint string
+	// number of enclosing transactions
+	depth int
 	// might be eithr sqlDb or tx, depending on
 	// if we're inside a transaction or not.
 	conn                 queryExecutor
@@ -49,6 +67,9 @@ type DB struct {
 	replacesPlaceholders bool
 	mu                   sync.RWMutex
 	cache                map[uint32]cacheEntry
+
+	// non-nil when running in dry mode, see DryRun
+	dryRun io.Writer
 }
 
 func (d *DB) replacePlaceholders(query string) string {
@@ -87,6 +108,9 @@ func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 		query = d.replacePlaceholders(query)
 	}
 	d.driver.debugq(query, args)
+	if d.dryRun != nil {
+		return d.dryExec(query, args)
+	}
 	if len(args) > 0 {
 		if stmt := d.preparedStmt(query); stmt != nil {
 			return stmt.Exec(args...)
@@ -95,6 +119,18 @@ func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 	return d.conn.Exec(query, args...)
 }
 
+func (d *DB) dryExec(query string, args []interface{}) (sql.Result, error) {
+	query = strings.TrimSpace(query)
+	if len(args) > 0 {
+		if _, err := fmt.Fprintf(d.dryRun, "%s; -- %v\n", query, args); err != nil {
+			return nil, err
+		}
+	} else if _, err := fmt.Fprintf(d.dryRun, "%s;\n", query); err != nil {
+		return nil, err
+	}
+	return dryResult{}, nil
+}
+
 func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	if d.replacesPlaceholders {
 		query = d.replacePlaceholders(query)
@@ -122,11 +158,19 @@ func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
 	return d.conn.QueryRow(query, args...)
 }
 
+// Begin starts a new transaction. If the DB is already in a
+// transaction, the new one is nested into it using a savepoint.
 func (d *DB) Begin() (*DB, error) {
 	if d.tx != nil {
-		return nil, driver.ErrInTransaction
+		return d.beginSavepoint()
+	}
+	var tx *sql.Tx
+	var err error
+	if d.dedicated != nil {
+		tx, err = d.dedicated.begin()
+	} else {
+		tx, err = d.sqlDb.Begin()
 	}
-	tx, err := d.sqlDb.Begin()
 	if err != nil {
 		return nil, err
 	}
@@ -136,11 +180,95 @@ func (d *DB) Begin() (*DB, error) {
 	return &dc, nil
 }
 
+func (d *DB) beginSavepoint() (*DB, error) {
+	if d.txDone {
+		return nil, driver.ErrFinished
+	}
+	name := fmt.Sprintf("gondola_sp_%d", d.depth+1)
+	if _, err := d.Exec("SAVEPOINT " + name); err != nil {
+		return nil, err
+	}
+	// Don't copy the statement cache, since it's
+	// protected by the mutex in d.
+	return &DB{
+		sqlDb:                d.sqlDb,
+		dedicated:            d.dedicated,
+		tx:                   d.tx,
+		savepoint:            name,
+		depth:                d.depth + 1,
+		conn:                 d.conn,
+		driver:               d.driver,
+		replacesPlaceholders: d.replacesPlaceholders,
+		dryRun:               d.dryRun,
+	}, nil
+}
+
+// Conn returns a copy of the DB which runs all its statements, including
+// the transactions started with Begin, on the same connection from the
+// pool. This is required for settings which only affect the connection
+// they're executed in (e.g. PRAGMA statements in sqlite). The connection
+// is returned to the pool when Close is called on the returned DB.
+func (d *DB) Conn() (*DB, error) {
+	if d.tx != nil {
+		return nil, driver.ErrInTransaction
+	}
+	conn, err := openConn(d.sqlDb)
+	if err != nil {
+		return nil, err
+	}
+	// Don't copy the statement cache, since it's
+	// protected by the mutex in d.
+	return &DB{
+		sqlDb:                d.sqlDb,
+		dedicated:            conn,
+		conn:                 conn,
+		driver:               d.driver,
+		replacesPlaceholders: d.replacesPlaceholders,
+		dryRun:               d.dryRun,
+	}, nil
+}
+
+// InTransaction returns true iff the DB is
+// bound to a transaction.
+func (d *DB) InTransaction() bool {
+	return d.tx != nil
+}
+
+// DryRun returns a copy of the DB which writes the statements
+// passed to Exec to w rather than executing them. Query and
+// QueryRow are still executed, so the DB can be inspected
+// while running in dry mode.
+func (d *DB) DryRun(w io.Writer) *DB {
+	// Don't copy the statement cache, since it's
+	// protected by the mutex in d.
+	return &DB{
+		sqlDb:                d.sqlDb,
+		dedicated:            d.dedicated,
+		tx:                   d.tx,
+		txDone:               d.txDone,
+		savepoint:            d.savepoint,
+		depth:                d.depth,
+		conn:                 d.conn,
+		driver:               d.driver,
+		replacesPlaceholders: d.replacesPlaceholders,
+		dryRun:               w,
+	}
+}
+
+// IsDryRun returns true iff the DB was returned from DryRun.
+func (d *DB) IsDryRun() bool {
+	return d.dryRun != nil
+}
+
 func (d *DB) Commit() error {
 	if d.tx == nil {
 		return driver.ErrNotInTransaction
 	}
 	d.txDone = true
+	if d.savepoint != "" {
+		_, err := d.Exec("RELEASE SAVEPOINT " + d.savepoint)
+		return err
+	}
 	return d.tx.Commit()
 }
 
@@ -149,16 +277,26 @@ func (d *DB) Rollback() error {
 		return driver.ErrNotInTransaction
 	}
 	d.txDone = true
+	if d.savepoint != "" {
+		if _, err := d.Exec("ROLLBACK TO SAVEPOINT " + d.savepoint); err != nil {
+			return err
+		}
+		_, err := d.Exec("RELEASE SAVEPOINT " + d.savepoint)
+		return err
+	}
 	return d.tx.Rollback()
 }
 
 func (d *DB) Close() error {
 	if d.tx != nil {
 		if !d.txDone {
-			return d.tx.Rollback()
+			return d.Rollback()
 		}
 		return nil
 	}
+	if d.dedicated != nil {
+		return d.dedicated.Close()
+	}
 	return d.sqlDb.Close()
 }
 
@@ -182,6 +320,10 @@ func (d *DB) quoteWith(s string, q byte) string {
 }
 
 func (d *DB) preparedStmt(s string) *sql.Stmt {
+	if d.dedicated != nil && d.tx == nil {
+		// Cached statements run on any connection
+		return nil
+	}
 	key := crc32.ChecksumIEEE(internal.StringToBytes(s))
 	d.mu.RLock()
 	cached, ok := d.cache[key]
@@ -220,3 +362,8 @@ func (d *DB) Driver() *Driver {
 func (d *DB) Backend() Backend {
 	return d.driver.backend
 }
+
+type dryResult struct{}
+
+func (dryResult) LastInsertId() (int64, error) { return 0, nil }
+func (dryResult) RowsAffected() (int64, error) { return 0, nil }
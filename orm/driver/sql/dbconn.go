@@ -0,0 +1,42 @@
+// +build go1.9
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+)
+
+// sqlConn adapts a *sql.Conn to the
+// methods used by DB.
+type sqlConn struct {
+	c *sql.Conn
+}
+
+func (c sqlConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.c.ExecContext(context.Background(), query, args...)
+}
+
+func (c sqlConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.c.QueryContext(context.Background(), query, args...)
+}
+
+func (c sqlConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.c.QueryRowContext(context.Background(), query, args...)
+}
+
+func (c sqlConn) begin() (*sql.Tx, error) {
+	return c.c.BeginTx(context.Background(), nil)
+}
+
+func (c sqlConn) Close() error {
+	return c.c.Close()
+}
+
+func openConn(db *sql.DB) (dedicatedConn, error) {
+	c, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return sqlConn{c: c}, nil
+}
@@ -0,0 +1,12 @@
+// +build !go1.9
+
+package sql
+
+import (
+	"database/sql"
+	"errors"
+)
+
+func openConn(db *sql.DB) (dedicatedConn, error) {
+	return nil, errors.New("dedicated connections require Go 1.9 or newer")
+}
@@ -0,0 +1,20 @@
+// Package sql implements the common parts of the ORM drivers for
+// database/sql based databases, like gnd.la/orm/driver/sqlite,
+// gnd.la/orm/driver/postgres or gnd.la/orm/driver/mysql.
+//
+// The query parameters in the configuration URL are passed to the
+// database/sql driver as part of the DSN, so the options handled by
+// this package are read from the URL fragment instead:
+//
+//  - max_conns: maximum number of open connections.
+//  - max_idle_conns: maximum number of idle connections.
+//  - conn_max_lifetime: maximum amount of time a connection might be
+//    reused, as a time.Duration string (e.g. 5m). Ignored before Go 1.6.
+//  - replicas: DSNs of the read replicas, separated by |. See NewDriver.
+//
+// e.g.
+//
+//  postgres://dbname=foo host=primary#max_conns=20&conn_max_lifetime=5m
+//
+// The pool settings apply to both the primary database and its replicas.
+package sql
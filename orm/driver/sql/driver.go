@@ -27,16 +27,24 @@ var (
 )
 
 type Driver struct {
-	db         *DB
-	logger     *log.Logger
-	backend    Backend
-	transforms map[reflect.Type]struct{}
+	db *DB
+	// read replicas, nil when in a transaction
+	replicas    []*DB
+	nextReplica *uint32
+	logger      *log.Logger
+	backend     Backend
+	transforms  map[reflect.Type]struct{}
 }
 
 func (d *Driver) Check() error {
 	if err := d.db.sqlDb.Ping(); err != nil {
 		return err
 	}
+	for _, v := range d.replicas {
+		if err := v.sqlDb.Ping(); err != nil {
+			return err
+		}
+	}
 	return d.backend.Check(d.db)
 }
 
@@ -151,16 +159,68 @@ func (d *Driver) indexName(m driver.Model, idx *index.Index) (string, error) {
 	return s, nil
 }
 
-func (d *Driver) Query(m driver.Model, q query.Q, sort []driver.Sort, limit int, offset int) driver.Iter {
-	query, params, err := d.Select(nil, true, m, q, sort, limit, offset)
+func (d *Driver) Query(m driver.Model, q query.Q, fields []string, sort []driver.Sort, limit int, offset int) driver.Iter {
+	selected, err := d.fieldSet(m, fields)
+	if err != nil {
+		return &Iter{err: err}
+	}
+	var columns []string
+	if selected != nil {
+		if columns, err = d.selectedColumns(m, selected); err != nil {
+			return &Iter{err: err}
+		}
+	}
+	query, params, err := d.Select(columns, false, m, q, sort, limit, offset)
 	if err != nil {
 		return &Iter{err: err}
 	}
-	rows, err := d.db.Query(internal.BytesToString(query.Bytes()), params...)
+	rows, err := d.readDB().Query(internal.BytesToString(query.Bytes()), params...)
 	if err != nil {
 		return &Iter{err: err}
 	}
-	return &Iter{model: m, rows: rows, driver: d}
+	return &Iter{model: m, rows: rows, driver: d, selected: selected}
+}
+
+// fieldSet returns the set of quoted database names for the
+// given qualified field names. If no fields are provided, it
+// returns nil, which means all the fields.
+func (d *Driver) fieldSet(m driver.Model, fields []string) (map[string]struct{}, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	set := make(map[string]struct{}, len(fields))
+	for _, v := range fields {
+		dbName, _, err := m.Map(v)
+		if err != nil {
+			return nil, err
+		}
+		set[dbName] = struct{}{}
+	}
+	return set, nil
+}
+
+// selectedColumns returns the selected columns for m (which
+// might be joined) in the same order used by outValues.
+func (d *Driver) selectedColumns(m driver.Model, selected map[string]struct{}) ([]string, error) {
+	var columns []string
+	for cur := m; ; {
+		if !cur.Skip() {
+			for _, v := range cur.Fields().QuotedNames {
+				if _, ok := selected[v]; ok {
+					columns = append(columns, v)
+				}
+			}
+		}
+		join := cur.Join()
+		if join == nil {
+			break
+		}
+		cur = join.Model()
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no fields selected from %s", m.Table())
+	}
+	return columns, nil
 }
 
 func (d *Driver) Count(m driver.Model, q query.Q, limit int, offset int) (uint64, error) {
@@ -169,7 +229,7 @@ func (d *Driver) Count(m driver.Model, q query.Q, limit int, offset int) (uint64
 	if err != nil {
 		return 0, err
 	}
-	err = d.db.QueryRow(buftos(query), params...).Scan(&count)
+	err = d.readDB().QueryRow(buftos(query), params...).Scan(&count)
 	putBuffer(query)
 	return count, err
 }
@@ -180,7 +240,7 @@ func (d *Driver) Exists(m driver.Model, q query.Q) (bool, error) {
 		return false, err
 	}
 	var one uint64
-	err = d.db.QueryRow(buftos(query), params...).Scan(&one)
+	err = d.readDB().QueryRow(buftos(query), params...).Scan(&one)
 	putBuffer(query)
 	if err == sql.ErrNoRows {
 		err = nil
@@ -189,7 +249,7 @@ func (d *Driver) Exists(m driver.Model, q query.Q) (bool, error) {
 }
 
 func (d *Driver) Insert(m driver.Model, data interface{}) (driver.Result, error) {
-	_, fields, values, err := d.saveParameters(m, data)
+	_, fields, values, err := d.saveParameters(m, data, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -276,11 +336,18 @@ func (d *Driver) Operate(m driver.Model, q query.Q, ops []*operation.Operation)
 	return res, err
 }
 
-func (d *Driver) Update(m driver.Model, q query.Q, data interface{}) (driver.Result, error) {
-	_, fields, values, err := d.saveParameters(m, data)
+func (d *Driver) Update(m driver.Model, q query.Q, data interface{}, fieldNames []string) (driver.Result, error) {
+	selected, err := d.fieldSet(m, fieldNames)
+	if err != nil {
+		return nil, err
+	}
+	_, fields, values, err := d.saveParameters(m, data, selected)
 	if err != nil {
 		return nil, err
 	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no fields to update in %s", m.Table())
+	}
 	buf := getBuffer()
 	buf.WriteString("UPDATE ")
 	buf.WriteByte('"')
@@ -328,6 +395,12 @@ func (d *Driver) Delete(m driver.Model, q query.Q) (driver.Result, error) {
 }
 
 func (d *Driver) Close() error {
+	if d.db.dedicated != nil {
+		return d.db.Close()
+	}
+	for _, v := range d.replicas {
+		v.sqlDb.Close()
+	}
 	return d.db.sqlDb.Close()
 }
 
@@ -396,7 +469,7 @@ func (d *Driver) fieldByIndex(val reflect.Value, indexes []int, alloc bool) refl
 	return val
 }
 
-func (d *Driver) saveParameters(m driver.Model, data interface{}) (reflect.Value, []string, []interface{}, error) {
+func (d *Driver) saveParameters(m driver.Model, data interface{}, selected map[string]struct{}) (reflect.Value, []string, []interface{}, error) {
 	// data is guaranteed to be of m.Type()
 	val := driver.Direct(reflect.ValueOf(data))
 	fields := m.Fields()
@@ -406,6 +479,9 @@ func (d *Driver) saveParameters(m driver.Model, data interface{}) (reflect.Value
 	var err error
 	if d.transforms != nil {
 		for ii, v := range fields.Indexes {
+			if !isSelected(selected, fields.QuotedNames[ii]) {
+				continue
+			}
 			f := d.fieldByIndex(val, v, false)
 			if !f.IsValid() {
 				continue
@@ -449,6 +525,9 @@ func (d *Driver) saveParameters(m driver.Model, data interface{}) (reflect.Value
 		}
 	} else {
 		for ii, v := range fields.Indexes {
+			if !isSelected(selected, fields.QuotedNames[ii]) {
+				continue
+			}
 			f := d.fieldByIndex(val, v, false)
 			if !f.IsValid() {
 				continue
@@ -479,7 +558,10 @@ func (d *Driver) saveParameters(m driver.Model, data interface{}) (reflect.Value
 	return val, names, values, nil
 }
 
-func (d *Driver) outValues(m driver.Model, out interface{}) (reflect.Value, *driver.Fields, []interface{}, []*scanner, error) {
+// outValues returns the values to pass to Scan for storing the results
+// into out. If selected is non-nil, only the selected fields are scanned
+// and the scanners for the rest of the fields are nil.
+func (d *Driver) outValues(m driver.Model, out interface{}, selected map[string]struct{}) (reflect.Value, *driver.Fields, []interface{}, []*scanner, error) {
 	val := reflect.ValueOf(out)
 	if !val.IsValid() {
 		// Untyped nil pointer
@@ -513,14 +595,17 @@ func (d *Driver) outValues(m driver.Model, out interface{}) (reflect.Value, *dri
 		// Skipped model
 		return reflect.Value{}, nil, nil, nil, nil
 	}
-	values := make([]interface{}, len(fields.Indexes))
+	values := make([]interface{}, 0, len(fields.Indexes))
 	scanners := make([]*scanner, len(fields.Indexes))
 	for ii, v := range fields.Indexes {
+		if !isSelected(selected, fields.QuotedNames[ii]) {
+			continue
+		}
 		field := d.fieldByIndex(val, v, true)
 		tag := fields.Tags[ii]
 		s := newScanner(&field, tag, d.backend)
 		scanners[ii] = s
-		values[ii] = s
+		values = append(values, s)
 	}
 	return val, fields, values, scanners, nil
 }
@@ -598,6 +683,7 @@ func (d *Driver) makeTable(m driver.Model) (*Table, error) {
 			field.Constraints = append(field.Constraints, &Constraint{
 				Type:       ConstraintForeignKey,
 				References: MakeReference(ref.Model.Table(), fk),
+				OnDelete:   ref.OnDelete,
 			})
 		}
 		dbFields[ii] = field
@@ -847,12 +933,22 @@ func (d *Driver) Select(fields []string, quote bool, m driver.Model, q query.Q,
 		return nil, nil, err
 	}
 	params = append(params, qParams...)
+	if err := d.sortLimitOffset(buf, m, sort, limit, offset); err != nil {
+		return nil, nil, err
+	}
+	return buf, params, nil
+}
+
+// sortLimitOffset writes the ORDER BY, LIMIT and OFFSET clauses
+// to buf. Sort fields are mapped using m. Negative values for limit
+// and offset omit the corresponding clause.
+func (d *Driver) sortLimitOffset(buf *bytes.Buffer, m driver.Model, sort []driver.Sort, limit int, offset int) error {
 	if len(sort) > 0 {
 		buf.WriteString(" ORDER BY ")
 		for _, v := range sort {
 			dbName, _, err := m.Map(v.Field())
 			if err != nil {
-				return nil, nil, err
+				return err
 			}
 			buf.WriteString(dbName)
 			if v.Direction() == driver.DESC {
@@ -870,7 +966,7 @@ func (d *Driver) Select(fields []string, quote bool, m driver.Model, q query.Q,
 		buf.WriteString(" OFFSET ")
 		buf.WriteString(strconv.Itoa(offset))
 	}
-	return buf, params, nil
+	return nil
 }
 
 func (d *Driver) Begin() (driver.Tx, error) {
@@ -880,10 +976,26 @@ func (d *Driver) Begin() (driver.Tx, error) {
 	}
 	drv := *d
 	drv.db = tx
+	drv.replicas = nil
 	tx.driver = &drv
 	return &drv, nil
 }
 
+// Conn returns a copy of the driver bound to a single
+// connection from the pool, which is released by calling
+// Close. See DB.Conn for more details.
+func (d *Driver) Conn() (driver.Driver, error) {
+	db, err := d.db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	drv := *d
+	drv.db = db
+	drv.replicas = nil
+	db.driver = &drv
+	return &drv, nil
+}
+
 func (d *Driver) Commit() error {
 	return d.db.Commit()
 }
@@ -900,7 +1012,7 @@ func (d *Driver) Capabilities() driver.Capability {
 	return driver.CAP_JOIN | driver.CAP_OR | driver.CAP_TRANSACTION | driver.CAP_BEGIN |
 		driver.CAP_AUTO_ID | driver.CAP_AUTO_INCREMENT | driver.CAP_PK |
 		driver.CAP_COMPOSITE_PK | driver.CAP_UNIQUE | driver.CAP_DEFAULTS |
-		d.backend.Capabilities()
+		driver.CAP_AGGREGATE | driver.CAP_SAVEPOINT | driver.CAP_FOREIGN_KEY | d.backend.Capabilities()
 }
 
 func (d *Driver) HasFunc(fname string, retType reflect.Type) bool {
@@ -912,16 +1024,25 @@ func (d *Driver) Connection() interface{} {
 	return d.db
 }
 
+// NewDriver returns a new Driver using the given Backend and
+// configuration URL. Besides the pool settings (max_conns,
+// max_idle_conns and conn_max_lifetime, see the package
+// documentation), the URL fragment might include a replicas
+// option with the DSNs of read replicas separated by | (e.g. postgres://dbname=foo host=primary#replicas=dbname%3Dfoo+host%3Dreplica1|dbname%3Dfoo+host%3Dreplica2).
+// Query, Count, Exists and Aggregate are sent to the replicas in
+// round-robin order when not running in a transaction, while any
+// other operation uses the primary database. Note that replicas
+// might lag behind the primary, so reads which must observe
+// previous writes should be performed inside a transaction.
 func NewDriver(b Backend, url *config.URL) (*Driver, error) {
-	conn, err := sql.Open(b.Name(), url.ValueAndQuery())
+	conn, err := openDB(b, url.ValueAndQuery(), url)
 	if err != nil {
 		return nil, err
 	}
-	if mc, ok := url.Fragment.Int("max_conns"); ok {
-		setMaxConns(conn, mc)
-	}
-	if mic, ok := url.Fragment.Int("max_idle_conns"); ok {
-		conn.SetMaxIdleConns(mic)
+	replicas, err := openReplicas(b, url)
+	if err != nil {
+		conn.Close()
+		return nil, err
 	}
 	var transforms map[reflect.Type]struct{}
 	if tt := b.Transforms(); len(tt) > 0 {
@@ -931,8 +1052,12 @@ func NewDriver(b Backend, url *config.URL) (*Driver, error) {
 			transforms[v.Elem()] = struct{}{}
 		}
 	}
-	driver := &Driver{backend: b, transforms: transforms}
-	driver.db = &DB{sqlDb: conn, conn: conn, driver: driver, replacesPlaceholders: b.Placeholder(0) != "?"}
+	driver := &Driver{backend: b, transforms: transforms, nextReplica: new(uint32)}
+	replacesPlaceholders := b.Placeholder(0) != "?"
+	driver.db = &DB{sqlDb: conn, conn: conn, driver: driver, replacesPlaceholders: replacesPlaceholders}
+	for _, v := range replicas {
+		driver.replicas = append(driver.replicas, &DB{sqlDb: v, conn: v, driver: driver, replacesPlaceholders: replacesPlaceholders})
+	}
 	return driver, nil
 }
 
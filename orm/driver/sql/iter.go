@@ -7,10 +7,11 @@ import (
 )
 
 type Iter struct {
-	model  driver.Model
-	driver *Driver
-	rows   *sql.Rows
-	err    error
+	model    driver.Model
+	driver   *Driver
+	rows     *sql.Rows
+	selected map[string]struct{}
+	err      error
 }
 
 func (i *Iter) Next(out ...interface{}) bool {
@@ -28,7 +29,7 @@ func (i *Iter) Next(out ...interface{}) bool {
 			if isNil(v) {
 				continue
 			}
-			val, vfields, vvalues, vscanners, err := i.driver.outValues(model, v)
+			val, vfields, vvalues, vscanners, err := i.driver.outValues(model, v, i.selected)
 			if err != nil {
 				i.err = err
 				return false
@@ -59,7 +60,7 @@ func (i *Iter) Next(out ...interface{}) bool {
 			for _, p := range f.Pointers {
 				isNil := true
 				for jj, v := range f.Indexes {
-					if f.IsSubfield(v, p) && !vscanners[jj].Nil {
+					if f.IsSubfield(v, p) && vscanners[jj] != nil && !vscanners[jj].Nil {
 						isNil = false
 						nilVal = false
 						break
@@ -72,7 +73,7 @@ func (i *Iter) Next(out ...interface{}) bool {
 			}
 			if nilVal {
 				for _, v := range vscanners {
-					if !v.Nil {
+					if v != nil && !v.Nil {
 						nilVal = false
 						break
 					}
@@ -85,7 +86,9 @@ func (i *Iter) Next(out ...interface{}) bool {
 		}
 		for _, s := range scanners {
 			for _, v := range s {
-				scannerPool.Put(v)
+				if v != nil {
+					scannerPool.Put(v)
+				}
 			}
 		}
 		return i.err == nil
@@ -0,0 +1,11 @@
+// +build !go1.6
+
+package sql
+
+import (
+	"database/sql"
+	"time"
+)
+
+func setConnMaxLifetime(db *sql.DB, d time.Duration) {
+}
@@ -0,0 +1,88 @@
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gnd.la/config"
+)
+
+// replicasSeparator separates the DSNs in the replicas
+// option of the configuration URL.
+const replicasSeparator = "|"
+
+// openDB opens a *sql.DB for the given backend and DSN, applying
+// the pool settings in the configuration URL fragment:
+//
+//  max_conns: maximum number of open connections
+//  max_idle_conns: maximum number of idle connections
+//  conn_max_lifetime: maximum amount of time a connection might be reused, as a time.Duration string (e.g. 5m)
+func openDB(b Backend, dsn string, url *config.URL) (*sql.DB, error) {
+	var lifetime time.Duration
+	if lt := url.Fragment.Get("conn_max_lifetime"); lt != "" {
+		var err error
+		if lifetime, err = time.ParseDuration(lt); err != nil {
+			return nil, fmt.Errorf("invalid conn_max_lifetime %q: %s", lt, err)
+		}
+	}
+	conn, err := sql.Open(b.Name(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if mc, ok := url.Fragment.Int("max_conns"); ok {
+		setMaxConns(conn, mc)
+	}
+	if mic, ok := url.Fragment.Int("max_idle_conns"); ok {
+		conn.SetMaxIdleConns(mic)
+	}
+	if lifetime > 0 {
+		setConnMaxLifetime(conn, lifetime)
+	}
+	return conn, nil
+}
+
+// openReplicas opens the read replicas specified in the replicas
+// option of the configuration URL fragment, as a list of DSNs
+// separated by |. Each replica uses the same query parameters
+// as the primary database.
+func openReplicas(b Backend, url *config.URL) ([]*sql.DB, error) {
+	value := url.Fragment.Get("replicas")
+	if value == "" {
+		return nil, nil
+	}
+	var replicas []*sql.DB
+	for _, v := range strings.Split(value, replicasSeparator) {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		dsn := v
+		if q := url.Query.String(); q != "" {
+			dsn += "?" + q
+		}
+		conn, err := openDB(b, dsn, url)
+		if err != nil {
+			for _, r := range replicas {
+				r.Close()
+			}
+			return nil, err
+		}
+		replicas = append(replicas, conn)
+	}
+	return replicas, nil
+}
+
+// readDB returns the DB which should be used for read only
+// queries. Outside of transactions, queries are sent to the
+// replicas in round-robin order. Inside a transaction or when
+// there are no replicas, it returns the primary database.
+func (d *Driver) readDB() *DB {
+	if len(d.replicas) == 0 || d.db.tx != nil {
+		return d.db
+	}
+	n := atomic.AddUint32(d.nextReplica, 1)
+	return d.replicas[int(n%uint32(len(d.replicas)))]
+}
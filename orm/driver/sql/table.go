@@ -95,6 +95,8 @@ func (f *Field) Copy() *Field {
 type Constraint struct {
 	Type       ConstraintType
 	References Reference
+	// Only used by ConstraintForeignKey
+	OnDelete driver.ReferenceAction
 }
 
 func (c *Constraint) String() string {
@@ -106,11 +108,29 @@ func (c *Constraint) String() string {
 	case ConstraintPrimaryKey:
 		return "PRIMARY_KEY"
 	case ConstraintForeignKey:
+		if c.OnDelete != driver.NoAction {
+			return fmt.Sprintf("FOREIGN_KEY %s ON_DELETE %s", string(c.References), c.OnDelete)
+		}
 		return fmt.Sprintf("FOREIGN_KEY %s", string(c.References))
 	}
 	return fmt.Sprintf("unknown constraint type %d", int(c.Type))
 }
 
+// ParseReferenceAction returns the driver.ReferenceAction
+// for the given SQL action (e.g. CASCADE). Unknown actions
+// are returned as driver.NoAction.
+func ParseReferenceAction(action string) driver.ReferenceAction {
+	switch strings.ToUpper(strings.TrimSpace(action)) {
+	case "CASCADE":
+		return driver.Cascade
+	case "SET NULL":
+		return driver.SetNull
+	case "RESTRICT":
+		return driver.Restrict
+	}
+	return driver.NoAction
+}
+
 type Table struct {
 	Fields      []*Field
 	Constraints []*Constraint
@@ -39,3 +39,13 @@ func DescField(idx *index.Index, field string) bool {
 func buftos(buf *bytes.Buffer) string {
 	return buf.String()
 }
+
+// isSelected returns true iff name is in the selected set
+// or no selection has been made.
+func isSelected(selected map[string]struct{}, name string) bool {
+	if selected == nil {
+		return true
+	}
+	_, ok := selected[name]
+	return ok
+}
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -46,7 +47,11 @@ func (b *Backend) Func(fname string, retType reflect.Type) (string, error) {
 }
 
 func (b *Backend) Inspect(db *sql.DB, m driver.Model) (*sql.Table, error) {
-	name := db.QuoteString(m.Table())
+	return b.inspectTable(db, m.Table())
+}
+
+func (b *Backend) inspectTable(db *sql.DB, table string) (*sql.Table, error) {
+	name := db.QuoteString(table)
 	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", name))
 	if err != nil {
 		return nil, err
@@ -91,7 +96,11 @@ func (b *Backend) Inspect(db *sql.DB, m driver.Model) (*sql.Table, error) {
 			return nil, err
 		}
 		field := fieldsByName[from]
-		field.Constraints = append(field.Constraints, &sql.Constraint{Type: sql.ConstraintForeignKey, References: sql.MakeReference(table, to)})
+		field.Constraints = append(field.Constraints, &sql.Constraint{
+			Type:       sql.ConstraintForeignKey,
+			References: sql.MakeReference(table, to),
+			OnDelete:   sql.ParseReferenceAction(onDelete),
+		})
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -125,6 +134,9 @@ func (b *Backend) DefineField(db *sql.DB, m driver.Model, table *sql.Table, fiel
 	return def, constraints, err
 }
 
+// AddFields uses ALTER TABLE ... ADD COLUMN when the fields can be
+// added that way. Otherwise, the table is rebuilt with the new fields
+// (see rebuildTable).
 func (b *Backend) AddFields(db *sql.DB, m driver.Model, prevTable *sql.Table, newTable *sql.Table, fields []*sql.Field) error {
 	rewrite := false
 	for _, v := range fields {
@@ -141,9 +153,6 @@ func (b *Backend) AddFields(db *sql.DB, m driver.Model, prevTable *sql.Table, ne
 		if err != nil {
 			return err
 		}
-		if _, err := db.Exec(createSql); err != nil {
-			return err
-		}
 		fieldNames := generic.Map(prevTable.Fields, func(f *sql.Field) string { return f.Name }).([]string)
 		// The previous table might have fields that we're not part
 		// of the new table.
@@ -153,19 +162,431 @@ func (b *Backend) AddFields(db *sql.DB, m driver.Model, prevTable *sql.Table, ne
 		}
 		fieldNames = generic.Filter(fieldNames, func(n string) bool { return fieldSet[n] }).([]string)
 		sqlFields := strings.Join(generic.Map(fieldNames, db.QuoteIdentifier).([]string), ", ")
-		copySql := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", quotedTmpName, sqlFields, sqlFields, name)
-		if _, err := db.Exec(copySql); err != nil {
+		return b.rebuildTable(db, m.Table(), []string{
+			createSql,
+			fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", quotedTmpName, sqlFields, sqlFields, name),
+			fmt.Sprintf("DROP TABLE %s", name),
+			fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quotedTmpName, name),
+		})
+	}
+	return b.SqlBackend.AddFields(db, m, prevTable, newTable, fields)
+}
+
+// RenameField uses ALTER TABLE ... RENAME COLUMN when it's
+// supported (sqlite 3.25.0 or newer). Otherwise, the table is
+// rebuilt with the field renamed.
+func (b *Backend) RenameField(db *sql.DB, table string, oldName string, newName string) error {
+	var version string
+	if err := db.QueryRow("SELECT sqlite_version()").Scan(&version); err != nil {
+		return err
+	}
+	if versionAtLeast(version, 3, 25) {
+		return b.SqlBackend.RenameField(db, table, oldName, newName)
+	}
+	return b.rebuildFields(db, table, oldName, newName)
+}
+
+// DropField rebuilds the table without the given field, since
+// sqlite versions older than 3.35.0 don't implement DROP COLUMN.
+// Unique constraints and indexes which don't include the dropped
+// field are preserved.
+func (b *Backend) DropField(db *sql.DB, table string, name string) error {
+	return b.rebuildFields(db, table, name, "")
+}
+
+// rebuildFields rebuilds the table renaming the field name to newName
+// or, if newName is empty, dropping it. Unique constraints and indexes
+// are preserved, but the indexes which include a renamed field are
+// recreated using just its columns.
+func (b *Backend) rebuildFields(db *sql.DB, table string, name string, newName string) error {
+	tbl, err := b.inspectTable(db, table)
+	if err != nil {
+		return err
+	}
+	if tbl == nil {
+		return fmt.Errorf("table %s does not exist", table)
+	}
+	rename := func(n string) string {
+		if n == name {
+			return newName
+		}
+		return n
+	}
+	var fields []*sql.Field
+	// Names of the copied fields in the previous table
+	var prevNames []string
+	fieldsByName := make(map[string]*sql.Field)
+	found := false
+	for _, v := range tbl.Fields {
+		prevName := v.Name
+		if v.Name == name {
+			found = true
+			if newName == "" {
+				continue
+			}
+			f := *v
+			f.Name = newName
+			v = &f
+		}
+		fields = append(fields, v)
+		prevNames = append(prevNames, prevName)
+		fieldsByName[v.Name] = v
+	}
+	if !found {
+		return fmt.Errorf("table %s has no field named %s", table, name)
+	}
+	var createSql string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&createSql); err != nil {
+		return err
+	}
+	if pks := tbl.PrimaryKeys(); len(pks) == 1 && strings.Contains(strings.ToUpper(createSql), "AUTOINCREMENT") {
+		fieldsByName[rename(pks[0])].AddOption(sql.OptionAutoIncrement)
+	}
+	indexes, err := b.tableIndexes(db, table)
+	if err != nil {
+		return err
+	}
+	var createIndexes []string
+	for _, v := range indexes {
+		if v.origin == "pk" || (newName == "" && v.has(name)) {
+			continue
+		}
+		if v.sql != "" && !v.has(name) {
+			createIndexes = append(createIndexes, v.sql)
+			continue
+		}
+		columns := generic.Map(v.columns, rename).([]string)
+		if v.sql == "" && len(columns) == 1 {
+			// Index created by a UNIQUE constraint
+			fieldsByName[columns[0]].AddConstraint(sql.ConstraintUnique)
+			continue
+		}
+		idxName := v.name
+		if v.sql == "" {
+			idxName = fmt.Sprintf("%s_%s_unique", table, strings.Join(columns, "_"))
+		}
+		create := "CREATE INDEX"
+		if v.unique {
+			create = "CREATE UNIQUE INDEX"
+		}
+		createIndexes = append(createIndexes, fmt.Sprintf("%s %s ON %s (%s)", create, db.QuoteIdentifier(idxName),
+			db.QuoteIdentifier(table), strings.Join(generic.Map(columns, db.QuoteIdentifier).([]string), ", ")))
+	}
+	tbl.Fields = fields
+	quotedName := db.QuoteIdentifier(table)
+	tmpName := fmt.Sprintf("%s_%s", table, stringutil.Random(8))
+	quotedTmpName := db.QuoteIdentifier(tmpName)
+	tmpSql, err := tbl.SQL(db, b, nil, tmpName)
+	if err != nil {
+		return err
+	}
+	quoteFields := func(names []string) string {
+		return strings.Join(generic.Map(names, db.QuoteIdentifier).([]string), ", ")
+	}
+	newNames := generic.Map(fields, func(f *sql.Field) string { return f.Name }).([]string)
+	stmts := []string{
+		tmpSql,
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", quotedTmpName, quoteFields(newNames), quoteFields(prevNames), quotedName),
+		fmt.Sprintf("DROP TABLE %s", quotedName),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quotedTmpName, quotedName),
+	}
+	return b.rebuildTable(db, table, append(stmts, createIndexes...))
+}
+
+// rebuildTable runs the given statements, which must rebuild the given
+// table, following the procedure described at
+// https://www.sqlite.org/lang_altertable.html#otheralter. Foreign keys
+// are disabled while the table is rebuilt, otherwise dropping the previous
+// table would trigger their ON DELETE actions (or fail, for the ones
+// without actions). Since foreign keys can't be disabled inside a
+// transaction, the table is rebuilt in a new transaction on a dedicated
+// connection. If db is already in a transaction, the caller must have
+// disabled foreign keys before starting it (see sql.TableRebuilder),
+// unless the table is not referenced by any other.
+func (b *Backend) rebuildTable(db *sql.DB, table string, stmts []string) error {
+	if db.IsDryRun() {
+		return execAll(db, stmts)
+	}
+	if db.InTransaction() {
+		enabled, err := b.ForeignKeys(db)
+		if err != nil {
 			return err
 		}
-		if _, err := db.Exec(fmt.Sprintf("DROP TABLE %s", name)); err != nil {
+		if enabled {
+			refs, err := b.referencingTables(db, table)
+			if err != nil {
+				return err
+			}
+			if len(refs) > 0 {
+				return fmt.Errorf("can't rebuild table %s inside a transaction with foreign keys enabled, it's referenced by %s",
+					table, strings.Join(refs, ", "))
+			}
+		}
+		if err := execAll(db, stmts); err != nil {
 			return err
 		}
-		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quotedTmpName, name)); err != nil {
+		return b.CheckForeignKeys(db)
+	}
+	conn, err := db.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	enabled, err := b.ForeignKeys(conn)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		if err := b.SetForeignKeys(conn, false); err != nil {
 			return err
 		}
-		return nil
+		defer b.SetForeignKeys(conn, true)
 	}
-	return b.SqlBackend.AddFields(db, m, prevTable, newTable, fields)
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := execAll(tx, stmts); err != nil {
+		return err
+	}
+	if err := b.CheckForeignKeys(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execAll(db *sql.DB, stmts []string) error {
+	for _, v := range stmts {
+		if _, err := db.Exec(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForeignKeys implements sql.TableRebuilder.
+func (b *Backend) ForeignKeys(db *sql.DB) (bool, error) {
+	var enabled int
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&enabled); err != nil {
+		return false, err
+	}
+	return enabled != 0, nil
+}
+
+// SetForeignKeys implements sql.TableRebuilder.
+func (b *Backend) SetForeignKeys(db *sql.DB, enabled bool) error {
+	value := "OFF"
+	if enabled {
+		value = "ON"
+	}
+	_, err := db.Exec("PRAGMA foreign_keys = " + value)
+	return err
+}
+
+// CheckForeignKeys implements sql.TableRebuilder.
+func (b *Backend) CheckForeignKeys(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		var table, parent string
+		var rowid *int64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return err
+		}
+		if rowid != nil {
+			return fmt.Errorf("foreign key violation: row %d in table %s references a missing row in %s", *rowid, table, parent)
+		}
+		return fmt.Errorf("foreign key violation: table %s references a missing row in %s", table, parent)
+	}
+	return rows.Err()
+}
+
+// referencingTables returns the other tables
+// with foreign keys pointing to the given one.
+func (b *Backend) referencingTables(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name != ?", table)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var refs []string
+	for _, v := range tables {
+		fks, err := db.Query(fmt.Sprintf("PRAGMA foreign_key_list(%s)", db.QuoteString(v)))
+		if err != nil {
+			return nil, err
+		}
+		cols, err := fks.Columns()
+		if err != nil {
+			fks.Close()
+			return nil, err
+		}
+		for fks.Next() {
+			values := make([]interface{}, len(cols))
+			pointers := make([]interface{}, len(cols))
+			for ii := range values {
+				pointers[ii] = &values[ii]
+			}
+			if err := fks.Scan(pointers...); err != nil {
+				fks.Close()
+				return nil, err
+			}
+			// Columns are id, seq, table, from, to...
+			if toString(values[2]) == table {
+				refs = append(refs, v)
+				break
+			}
+		}
+		fks.Close()
+		if err := fks.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+// versionAtLeast returns true iff the given sqlite version
+// is equal or greater than major.minor.
+func versionAtLeast(version string, major int, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	vmajor, err1 := strconv.Atoi(parts[0])
+	vminor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return vmajor > major || (vmajor == major && vminor >= minor)
+}
+
+type sqliteIndex struct {
+	name    string
+	sql     string
+	origin  string
+	unique  bool
+	columns []string
+}
+
+func (i *sqliteIndex) has(column string) bool {
+	for _, v := range i.columns {
+		if v == column {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Backend) tableIndexes(db *sql.DB, table string) ([]*sqliteIndex, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA index_list(%s)", db.QuoteString(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	var origins []string
+	var uniques []bool
+	for rows.Next() {
+		// Older sqlite versions don't return the partial column
+		values := make([]interface{}, len(cols))
+		pointers := make([]interface{}, len(cols))
+		for ii := range values {
+			pointers[ii] = &values[ii]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		names = append(names, toString(values[1]))
+		origins = append(origins, toString(values[3]))
+		uniques = append(uniques, toInt(values[2]) != 0)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	var indexes []*sqliteIndex
+	for ii, name := range names {
+		idx := &sqliteIndex{name: name, origin: origins[ii], unique: uniques[ii]}
+		var idxSql *string
+		if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?", name).Scan(&idxSql); err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if idxSql != nil {
+			idx.sql = *idxSql
+		}
+		crows, err := db.Query(fmt.Sprintf("PRAGMA index_info(%s)", db.QuoteString(name)))
+		if err != nil {
+			return nil, err
+		}
+		for crows.Next() {
+			var seqno, cid int
+			var column string
+			if err := crows.Scan(&seqno, &cid, &column); err != nil {
+				crows.Close()
+				return nil, err
+			}
+			idx.columns = append(idx.columns, column)
+		}
+		crows.Close()
+		indexes = append(indexes, idx)
+	}
+	return indexes, nil
+}
+
+func toInt(v interface{}) int64 {
+	if i, ok := v.(int64); ok {
+		return i
+	}
+	i, _ := strconv.ParseInt(toString(v), 10, 64)
+	return i
+}
+
+func toString(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	s, _ := v.(string)
+	return s
+}
+
+func (b *Backend) InsertAll(db *sql.DB, m driver.Model, query string, count int, args ...interface{}) (driver.Result, []int64, error) {
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ids []int64
+	if m.Fields().AutoincrementPk {
+		// SQLite returns the id of the last inserted row. Since
+		// writes are serialized, the rows inserted by the same
+		// statement are assigned consecutive ids.
+		if last, err := res.LastInsertId(); err == nil && last != 0 {
+			ids = make([]int64, count)
+			for ii := range ids {
+				ids[ii] = last - int64(count-ii-1)
+			}
+		}
+	}
+	return res, ids, nil
 }
 
 func (b *Backend) FieldType(typ reflect.Type, t *structs.Tag) (string, error) {
@@ -238,6 +659,18 @@ func (b *Backend) canAddField(f *sql.Field) bool {
 }
 
 func sqliteOpener(url *config.URL) (driver.Driver, error) {
+	// PRAGMA foreign_keys only affects the connection
+	// which executes it, so ask the driver to enable FKs
+	// in every connection it opens.
+	if url.Query.Get("_foreign_keys") == "" && url.Query.Get("_fk") == "" {
+		u := *url
+		u.Query = make(config.Map, len(url.Query)+1)
+		for k, v := range url.Query {
+			u.Query[k] = v
+		}
+		u.Query["_foreign_keys"] = "1"
+		url = &u
+	}
 	drv, err := sql.NewDriver(sqliteBackend, url)
 	if err == nil {
 		if _, err := drv.DB().Exec("PRAGMA foreign_keys = on"); err != nil {
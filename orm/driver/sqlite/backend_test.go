@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"gnd.la/config"
+	"gnd.la/orm/driver/sql"
+)
+
+func TestVersionAtLeast(t *testing.T) {
+	cases := map[string]bool{
+		"3.24.0": false,
+		"3.25.0": true,
+		"3.8.11": false,
+		"3.32.2": true,
+		"4.0.0":  true,
+		"3":      false,
+	}
+	for k, v := range cases {
+		if r := versionAtLeast(k, 3, 25); r != v {
+			t.Errorf("expecting versionAtLeast(%q, 3, 25) = %v, got %v", k, v, r)
+		}
+	}
+}
+
+func TestRebuildRename(t *testing.T) {
+	f, err := ioutil.TempFile("", "sqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	drv, err := sqliteOpener(config.MustParseURL("sqlite://" + f.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drv.Close()
+	db := drv.(*sql.Driver).DB()
+	for _, v := range []string{
+		`CREATE TABLE "users" ("Id" INTEGER PRIMARY KEY, "Email" TEXT UNIQUE, "Name" TEXT)`,
+		`CREATE INDEX "users_name_email" ON "users" ("Name", "Email")`,
+		`CREATE TABLE "posts" ("Id" INTEGER PRIMARY KEY, "UserId" INTEGER REFERENCES "users" ("Id") ON DELETE CASCADE)`,
+		`INSERT INTO "users" ("Id", "Email", "Name") VALUES (1, 'foo@example.com', 'foo')`,
+		`INSERT INTO "posts" ("UserId") VALUES (1)`,
+	} {
+		if _, err := db.Exec(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sqliteBackend.rebuildFields(db, "users", "Email", "Address"); err != nil {
+		t.Fatal(err)
+	}
+	var address string
+	if err := db.QueryRow(`SELECT "Address" FROM "users"`).Scan(&address); err != nil || address != "foo@example.com" {
+		t.Errorf("expecting address foo@example.com, got %q (error %v)", address, err)
+	}
+	if _, err := db.Exec(`INSERT INTO "users" ("Address") VALUES ('foo@example.com')`); err == nil {
+		t.Error("expecting an error when violating users.Address UNIQUE constraint")
+	}
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM "posts"`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("expecting 1 post after rebuilding users, got %d (error %v)", count, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'users_name_email'`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("index users_name_email was not recreated (error %v)", err)
+	}
+	if enabled, err := sqliteBackend.ForeignKeys(db); err != nil || !enabled {
+		t.Errorf("foreign keys not enabled after rebuilding the table (error %v)", err)
+	}
+}
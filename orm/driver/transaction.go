@@ -15,3 +15,14 @@ type Tx interface {
 	Commit() error
 	Rollback() error
 }
+
+// Beginner is implemented by transactions which can start
+// nested transactions (e.g. using savepoints). Committing
+// a nested transaction makes its changes part of its parent,
+// while rolling it back only undoes the changes made since
+// the nested transaction was started. Drivers returning
+// transactions which implement this interface must report
+// CAP_SAVEPOINT in their capabilities.
+type Beginner interface {
+	Begin() (Tx, error)
+}
@@ -10,6 +10,7 @@ import (
 	"os/user"
 	"testing"
 
+	"gnd.la/config"
 	_ "gnd.la/orm/driver/mysql"
 	_ "gnd.la/orm/driver/postgres"
 	_ "gnd.la/orm/driver/sqlite"
@@ -86,6 +87,61 @@ func TestMysql(t *testing.T) {
 	runAllTests(t, &mysqlOpener{})
 }
 
+type ReplicaItem struct {
+	Id   int64 `orm:",primary_key,auto_increment"`
+	Name string
+}
+
+func TestSqliteReplicas(t *testing.T) {
+	var files []string
+	for ii := 0; ii < 2; ii++ {
+		f, err := ioutil.TempFile("", "sqlite-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+		files = append(files, f.Name())
+	}
+	opts := &Options{Table: "test_replicas"}
+	// Initialize the replica with a different object
+	replica := newOrm(t, "sqlite://"+files[1], true)
+	replica.mustRegister((*ReplicaItem)(nil), opts)
+	replica.mustInitialize()
+	replica.MustInsert(&ReplicaItem{Name: "replica"})
+	replica.Close()
+	o := newOrm(t, fmt.Sprintf("sqlite://%s#replicas=%s&max_conns=2&conn_max_lifetime=1m", files[0], files[1]), true)
+	defer o.Close()
+	table := o.mustRegister((*ReplicaItem)(nil), opts)
+	o.mustInitialize()
+	primary := &ReplicaItem{Name: "primary"}
+	o.MustInsert(primary)
+	var item ReplicaItem
+	if _, err := o.Table(table).One(&item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Name != "replica" {
+		t.Errorf("expecting read from replica, got %q instead", item.Name)
+	}
+	if n, err := o.Count(table, Eq("Name", "primary")); err != nil || n != 0 {
+		t.Errorf("expecting no primary objects in replica, got %d (error %v)", n, err)
+	}
+	tx := o.MustBegin()
+	defer tx.Close()
+	if _, err := tx.Table(table).One(&item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Name != "primary" {
+		t.Errorf("expecting read from primary in transaction, got %q instead", item.Name)
+	}
+	if e, err := tx.Exists(table, Eq("Name", "primary")); err != nil || !e {
+		t.Errorf("expecting primary object to exist in transaction (error %v)", err)
+	}
+	if _, err := New(config.MustParseURL("sqlite://" + files[0] + "#conn_max_lifetime=never")); err == nil {
+		t.Error("expecting an error with invalid conn_max_lifetime")
+	}
+}
+
 func init() {
 	openers["default"] = &sqliteOpener{}
 	openers["sqlite"] = &sqliteOpener{}
@@ -7,4 +7,7 @@ import (
 var (
 	// ErrNotSql indicates that the current driver is not using database/sql.
 	ErrNoSql = errors.New("driver is not using database/sql")
+	// ErrInvalidCursor is returned when a query uses a Cursor
+	// which is malformed or does not match its sort fields.
+	ErrInvalidCursor = errors.New("invalid cursor")
 )
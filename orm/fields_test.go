@@ -0,0 +1,65 @@
+package orm
+
+import (
+	"testing"
+)
+
+type Article struct {
+	Id    int64 `orm:",primary_key,auto_increment"`
+	Title string
+	Body  string
+	Views int
+}
+
+func testFields(t *testing.T, o *Orm) {
+	tbl := o.mustRegister((*Article)(nil), &Options{
+		Table: "test_fields",
+	})
+	o.mustInitialize()
+	o.MustInsert(&Article{Title: "Gondola", Body: "A very long body", Views: 7})
+	var articles []*Article
+	if err := o.Table(tbl).Fields("Article.Id", "Title").All(&articles); err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 1 {
+		t.Fatalf("expecting 1 article, got %d", len(articles))
+	}
+	if a := articles[0]; a.Id == 0 || a.Title != "Gondola" || a.Body != "" || a.Views != 0 {
+		t.Errorf("unexpected article loaded with fields: %+v", a)
+	}
+	article := &Article{Body: "untouched"}
+	if _, err := o.Table(tbl).Fields("Article|Views").One(article); err != nil {
+		t.Fatal(err)
+	}
+	if article.Views != 7 || article.Body != "untouched" || article.Title != "" {
+		t.Errorf("unexpected article loaded with fields: %+v", article)
+	}
+	if _, err := o.Table(tbl).Fields("Foo").One(article); err == nil {
+		t.Error("expecting an error when selecting a non-existing field")
+	}
+	// Update just the title
+	update := &Article{Title: "Gondola 2", Body: "overwritten?"}
+	if _, err := o.UpdateFields(Eq("Title", "Gondola"), update, "Title"); err != nil {
+		t.Fatal(err)
+	}
+	var saved Article
+	if _, err := o.One(nil, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Title != "Gondola 2" || saved.Body != "A very long body" || saved.Views != 7 {
+		t.Errorf("unexpected article after updating fields: %+v", saved)
+	}
+	// Update the whole object
+	saved.Body = "Short"
+	saved.Views = 8
+	if _, err := o.UpdateFields(Eq("Id", saved.Id), &saved); err != nil {
+		t.Fatal(err)
+	}
+	var updated Article
+	if _, err := o.One(Eq("Id", saved.Id), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated != saved {
+		t.Errorf("expecting %+v after updating, got %+v", saved, updated)
+	}
+}
@@ -0,0 +1,190 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+
+	"gnd.la/orm/driver"
+)
+
+// BeforeInserter is implemented by models which need to run
+// code before being inserted. If BeforeInsert returns an
+// error, the object is not inserted.
+//
+// All the hooks are called with the *Orm performing the operation. When
+// the operation is performed from a transaction, the hooks receive
+// the transaction's *Orm (i.e. &tx.Orm), so any changes they make
+// are part of the same transaction. If the model implements any
+// hook and the operation is not already running in a transaction,
+// the ORM starts one (when the driver supports it), so an error
+// returned from an After* hook undoes the operation too, while an
+// error from the operation undoes the changes made by the Before*
+// hooks.
+//
+// Note that hooks are only called for operations on objects
+// (e.g. Orm.Insert or Orm.Delete), not for operations on tables
+// (e.g. Orm.DeleteFrom).
+type BeforeInserter interface {
+	BeforeInsert(o *Orm) error
+}
+
+// AfterInserter is implemented by models which need to run
+// code after being inserted (e.g. to write related rows).
+type AfterInserter interface {
+	AfterInsert(o *Orm) error
+}
+
+// BeforeUpdater is implemented by models which need to run
+// code before being updated. If BeforeUpdate returns an
+// error, the object is not updated.
+type BeforeUpdater interface {
+	BeforeUpdate(o *Orm) error
+}
+
+// AfterUpdater is implemented by models which need to run
+// code after being updated. AfterUpdate is only called when
+// the update affects at least one row.
+type AfterUpdater interface {
+	AfterUpdate(o *Orm) error
+}
+
+// BeforeDeleter is implemented by models which need to run
+// code before being deleted. If BeforeDelete returns an error,
+// the object is not deleted.
+type BeforeDeleter interface {
+	BeforeDelete(o *Orm) error
+}
+
+// AfterDeleter is implemented by models which need to run code
+// after being deleted.
+type AfterDeleter interface {
+	AfterDelete(o *Orm) error
+}
+
+// AfterFinder is implemented by models which need to run code
+// after being loaded from the database. AfterFind is called
+// after the Load method, if any. If it returns an error, the
+// iteration stops and the error is returned from Iter.Err.
+type AfterFinder interface {
+	AfterFind(o *Orm) error
+}
+
+var hookTypes = []struct {
+	name string
+	typ  reflect.Type
+}{
+	{"BeforeInsert", reflect.TypeOf((*BeforeInserter)(nil)).Elem()},
+	{"AfterInsert", reflect.TypeOf((*AfterInserter)(nil)).Elem()},
+	{"BeforeUpdate", reflect.TypeOf((*BeforeUpdater)(nil)).Elem()},
+	{"AfterUpdate", reflect.TypeOf((*AfterUpdater)(nil)).Elem()},
+	{"BeforeDelete", reflect.TypeOf((*BeforeDeleter)(nil)).Elem()},
+	{"AfterDelete", reflect.TypeOf((*AfterDeleter)(nil)).Elem()},
+	{"AfterFind", reflect.TypeOf((*AfterFinder)(nil)).Elem()},
+}
+
+type hookOp int
+
+const (
+	hookInsert hookOp = iota
+	hookUpdate
+	hookDelete
+)
+
+// checkHooks returns an error if typ has a method with the name
+// of a hook which does not implement the corresponding interface,
+// since it would be silently ignored otherwise.
+func checkHooks(typ reflect.Type) error {
+	if typ.Kind() != reflect.Ptr {
+		typ = reflect.PtrTo(typ)
+	}
+	for _, v := range hookTypes {
+		if _, ok := typ.MethodByName(v.name); ok && !typ.Implements(v.typ) {
+			return fmt.Errorf("method %q on type %v must have the signature func(*orm.Orm) error", v.name, typ)
+		}
+	}
+	return nil
+}
+
+// hookObject returns the value the hooks are called on, which
+// is obj with any extra indirections removed. If obj is a nil
+// pointer, it returns nil.
+func hookObject(obj interface{}) interface{} {
+	val := reflect.ValueOf(obj)
+	for val.Kind() == reflect.Ptr && val.Elem().Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	if !val.IsValid() || (val.Kind() == reflect.Ptr && val.IsNil()) {
+		return nil
+	}
+	return val.Interface()
+}
+
+// withHooks calls f between the before and after hooks
+// of the given operation implemented by obj.
+func (o *Orm) withHooks(op hookOp, obj interface{}, f func(o *Orm) (Result, error)) (Result, error) {
+	var before, after func(*Orm) error
+	switch h := hookObject(obj); op {
+	case hookInsert:
+		if b, ok := h.(BeforeInserter); ok {
+			before = b.BeforeInsert
+		}
+		if a, ok := h.(AfterInserter); ok {
+			after = a.AfterInsert
+		}
+	case hookUpdate:
+		if b, ok := h.(BeforeUpdater); ok {
+			before = b.BeforeUpdate
+		}
+		if a, ok := h.(AfterUpdater); ok {
+			after = a.AfterUpdate
+		}
+	case hookDelete:
+		if b, ok := h.(BeforeDeleter); ok {
+			before = b.BeforeDelete
+		}
+		if a, ok := h.(AfterDeleter); ok {
+			after = a.AfterDelete
+		}
+	}
+	if before == nil && after == nil {
+		return f(o)
+	}
+	run := func(o *Orm) (Result, error) {
+		if before != nil {
+			if err := before(o); err != nil {
+				return nil, err
+			}
+		}
+		res, err := f(o)
+		if err != nil || after == nil {
+			return res, err
+		}
+		if op == hookUpdate {
+			if aff, err := res.RowsAffected(); err == nil && aff == 0 {
+				return res, nil
+			}
+		}
+		if err := after(o); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+	if o.inTransaction || o.driver.Capabilities()&driver.CAP_BEGIN == 0 {
+		return run(o)
+	}
+	var res Result
+	err := o.Transaction(func(tx *Orm) error {
+		var err error
+		res, err = run(tx)
+		return err
+	})
+	return res, err
+}
+
+// afterFind calls the AfterFind hook on obj, if it implements it.
+func (o *Orm) afterFind(obj interface{}) error {
+	if h, ok := hookObject(obj).(AfterFinder); ok {
+		return h.AfterFind(o)
+	}
+	return nil
+}
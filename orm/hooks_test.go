@@ -0,0 +1,194 @@
+package orm
+
+import (
+	"errors"
+	"testing"
+
+	"gnd.la/orm/driver"
+)
+
+var (
+	errNoTitle   = errors.New("post has no title")
+	errAfterHook = errors.New("after hook failed")
+	errProtected = errors.New("post is protected")
+)
+
+type HookAccount struct {
+	Id    int64 `orm:",primary_key,auto_increment"`
+	Posts int
+}
+
+type HookPost struct {
+	Id        int64 `orm:",primary_key,auto_increment"`
+	AccountId int64
+	Title     string
+	Protected bool
+	Updates   int
+	failAfter bool
+	found     bool
+}
+
+func (p *HookPost) updateAccount(o *Orm, delta int) error {
+	var account HookAccount
+	if _, err := o.One(Eq("HookAccount.Id", p.AccountId), &account); err != nil {
+		return err
+	}
+	account.Posts += delta
+	_, err := o.Save(&account)
+	return err
+}
+
+func (p *HookPost) BeforeInsert(o *Orm) error {
+	if p.Title == "" {
+		return errNoTitle
+	}
+	return nil
+}
+
+func (p *HookPost) AfterInsert(o *Orm) error {
+	if err := p.updateAccount(o, 1); err != nil {
+		return err
+	}
+	if p.failAfter {
+		return errAfterHook
+	}
+	return nil
+}
+
+func (p *HookPost) BeforeUpdate(o *Orm) error {
+	p.Updates++
+	return nil
+}
+
+func (p *HookPost) AfterUpdate(o *Orm) error {
+	if p.failAfter {
+		return errAfterHook
+	}
+	return nil
+}
+
+func (p *HookPost) BeforeDelete(o *Orm) error {
+	if p.Protected {
+		return errProtected
+	}
+	return nil
+}
+
+func (p *HookPost) AfterDelete(o *Orm) error {
+	return p.updateAccount(o, -1)
+}
+
+func (p *HookPost) AfterFind(o *Orm) error {
+	p.found = true
+	return nil
+}
+
+type HookLog struct {
+	Id      int64 `orm:",primary_key,auto_increment"`
+	Message string
+}
+
+// HookAudited writes a HookLog row from BeforeInsert
+type HookAudited struct {
+	Id int64 `orm:",primary_key"`
+}
+
+func (a *HookAudited) BeforeInsert(o *Orm) error {
+	_, err := o.Insert(&HookLog{Message: "inserting"})
+	return err
+}
+
+type BadHook struct {
+	Id int64 `orm:",primary_key,auto_increment"`
+}
+
+func (b *BadHook) BeforeInsert() error {
+	return nil
+}
+
+func testHooks(t *testing.T, o *Orm) {
+	o.mustRegister((*HookAccount)(nil), &Options{
+		Table: "test_hook_accounts",
+	})
+	posts := o.mustRegister((*HookPost)(nil), &Options{
+		Table: "test_hook_posts",
+	})
+	o.mustInitialize()
+	if _, err := o.Register((*BadHook)(nil), &Options{Table: "test_bad_hook"}); err == nil {
+		t.Error("expecting an error when registering a hook with the wrong signature")
+	}
+	account := &HookAccount{}
+	o.MustInsert(account)
+	expectPosts := func(expected int) {
+		var acc HookAccount
+		if _, err := o.One(Eq("HookAccount.Id", account.Id), &acc); err != nil {
+			t.Fatal(err)
+		}
+		if acc.Posts != expected {
+			t.Errorf("expecting %d posts, got %d", expected, acc.Posts)
+		}
+	}
+	if _, err := o.Insert(&HookPost{AccountId: account.Id}); err != errNoTitle {
+		t.Errorf("expecting errNoTitle from BeforeInsert, got %v", err)
+	}
+	post := &HookPost{AccountId: account.Id, Title: "Gondola"}
+	o.MustInsert(post)
+	expectPosts(1)
+	// Errors from AfterInsert must roll back the insert
+	// and the changes made by the hook.
+	failed := &HookPost{AccountId: account.Id, Title: "Failed", failAfter: true}
+	if _, err := o.Insert(failed); err != errAfterHook {
+		t.Errorf("expecting errAfterHook from AfterInsert, got %v", err)
+	}
+	if o.driver.Capabilities()&driver.CAP_BEGIN != 0 {
+		expectPosts(1)
+		if n := o.Table(posts).MustCount(); n != 1 {
+			t.Errorf("expecting 1 post after failed insert, got %d", n)
+		}
+	}
+	// Hooks inside a transaction use the transaction
+	tx := o.MustBegin()
+	tx.MustInsert(&HookPost{AccountId: account.Id, Title: "Rolled back"})
+	tx.MustRollback()
+	expectPosts(1)
+	post.Title = "Gondola 2"
+	o.MustSave(post)
+	if post.Updates != 1 {
+		t.Errorf("expecting 1 update, got %d", post.Updates)
+	}
+	var loaded HookPost
+	if _, err := o.One(Eq("HookPost.Id", post.Id), &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.found {
+		t.Error("AfterFind was not called")
+	}
+	if loaded.Title != "Gondola 2" || loaded.Updates != 1 {
+		t.Errorf("unexpected post after update %+v", loaded)
+	}
+	loaded.Protected = true
+	if err := o.Delete(&loaded); err != errProtected {
+		t.Errorf("expecting errProtected from BeforeDelete, got %v", err)
+	}
+	expectPosts(1)
+	o.MustDelete(post)
+	expectPosts(0)
+	// Errors from the operation must roll back the
+	// changes made by the Before* hooks.
+	logs := o.mustRegister((*HookLog)(nil), &Options{
+		Table: "test_hook_logs",
+	})
+	o.mustRegister((*HookAudited)(nil), &Options{
+		Table: "test_hook_audited",
+	})
+	o.mustInitialize()
+	o.MustInsert(&HookAudited{Id: 1})
+	if _, err := o.Insert(&HookAudited{Id: 1}); err == nil {
+		t.Error("expecting an error when inserting a duplicate primary key")
+	}
+	if o.driver.Capabilities()&driver.CAP_BEGIN != 0 {
+		if n := o.Table(logs).MustCount(); n != 1 {
+			t.Errorf("expecting 1 log after failed insert, got %d", n)
+		}
+	}
+}
@@ -9,6 +9,7 @@ type Iter struct {
 	limit int
 	driver.Iter
 	err error
+	out []interface{}
 }
 
 // Next advances the iter to the next result,
@@ -30,14 +31,20 @@ func (i *Iter) Next(out ...interface{}) bool {
 				i.q.methods = append(i.q.methods, cur.model.fields.Methods)
 			}
 		}
-		i.Iter = i.q.exec(i.limit)
+		if i.Iter, i.err = i.q.exec(i.limit); i.err != nil {
+			return false
+		}
 	}
 	ok := i.Iter.Next(out...)
 	if ok {
+		i.out = out
 		for ii, v := range out {
 			if i.err = i.q.methods[ii].Load(v); i.err != nil {
 				break
 			}
+			if i.err = i.q.orm.afterFind(v); i.err != nil {
+				break
+			}
 		}
 	} else {
 		i.Close()
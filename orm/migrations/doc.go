@@ -0,0 +1,46 @@
+// Package migrations implements versioned schema migrations for
+// gnd.la/orm.
+//
+// gnd.la/orm.Orm.Initialize creates missing tables and fields, but
+// it never drops nor renames fields and it doesn't move any data.
+// Those changes must be performed explicitly, using migrations.
+// Each Migration has a unique version, which determines the order
+// in which migrations are applied, plus Up and Down functions which
+// apply and revert it respectively. Migrations might be written
+// either in Go or in SQL. e.g.
+//
+//  func init() {
+//	migrations.MustRegister(&migrations.Migration{
+//		Version: 1,
+//		Name:    "rename-user-email",
+//		Up: func(m *migrations.Migrator) error {
+//			return m.RenameField("users", "Email", "Address")
+//		},
+//		Down: func(m *migrations.Migrator) error {
+//			return m.RenameField("users", "Address", "Email")
+//		},
+//	})
+//	migrations.MustRegister(&migrations.Migration{
+//		Version: 2,
+//		Name:    "drop-user-legacy-id",
+//		Up:      migrations.SQL(`ALTER TABLE "users" DROP COLUMN "LegacyId"`),
+//	})
+//  }
+//
+// The versions which have been applied are recorded in the
+// gondola_migrations table. Each migration is run inside a
+// transaction when the database supports it (note that some
+// databases, like MySQL, commit implicitly after any DDL statement).
+//
+// Migrations can also be run in dry mode, which prints the statements
+// the database backend would execute rather than running them. Note
+// that Go functions must check Migrator.DryRun before modifying any
+// data via Migrator.Orm, since the ORM always executes its queries.
+//
+// Apps can run their migrations using the builtin migrate command
+// (see gnd.la/commands):
+//
+//  ./myapp migrate [-to=version] [-dry-run]
+//  ./myapp migrate status
+//  ./myapp migrate rollback [-steps=n] [-dry-run]
+package migrations
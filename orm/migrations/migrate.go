@@ -0,0 +1,322 @@
+package migrations
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"gnd.la/orm"
+	"gnd.la/orm/driver"
+	"gnd.la/orm/driver/sql"
+)
+
+// Table is the name of the table which records the
+// applied migrations.
+const Table = "gondola_migrations"
+
+// Options specify how migrations are run. A nil
+// *Options is equivalent to a zero Options.
+type Options struct {
+	// Migrations are the migrations to consider. If empty,
+	// the registered migrations are used.
+	Migrations []*Migration
+	// DryRun, if non-nil, makes the migrations write the
+	// statements they would execute to it, rather than
+	// running them.
+	DryRun io.Writer
+}
+
+// MigrationStatus represents the status of a migration, as
+// returned by Status.
+type MigrationStatus struct {
+	Version int64
+	Name    string
+	// Applied is the time the migration was applied. If
+	// it's zero, the migration is pending.
+	Applied time.Time
+	// Migration is the registered migration with this version.
+	// It might be nil if the migration was applied, but it's
+	// not registered anymore.
+	Migration *Migration
+}
+
+// Migrate applies the pending migrations with versions up to
+// target, in ascending version order. If target is zero, all
+// the pending migrations are applied. It returns the migrations
+// which were applied. If a migration fails, the migrations
+// applied before it are kept.
+func Migrate(o *orm.Orm, target int64, opts *Options) ([]*Migration, error) {
+	r, err := newRunner(o, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ensureTable(); err != nil {
+		return nil, err
+	}
+	applied, err := r.applied()
+	if err != nil {
+		return nil, err
+	}
+	var done []*Migration
+	for _, v := range r.migrations {
+		if target > 0 && v.Version > target {
+			break
+		}
+		if _, ok := applied[v.Version]; ok {
+			continue
+		}
+		if err := r.run(v, true); err != nil {
+			return done, fmt.Errorf("error applying migration %s: %s", v, err)
+		}
+		done = append(done, v)
+	}
+	return done, nil
+}
+
+// Rollback reverts the last steps applied migrations, in
+// descending version order. It returns the migrations which
+// were reverted.
+func Rollback(o *orm.Orm, steps int, opts *Options) ([]*Migration, error) {
+	r, err := newRunner(o, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ensureTable(); err != nil {
+		return nil, err
+	}
+	statuses, err := r.status()
+	if err != nil {
+		return nil, err
+	}
+	var done []*Migration
+	for ii := len(statuses) - 1; ii >= 0 && len(done) < steps; ii-- {
+		st := statuses[ii]
+		if st.Applied.IsZero() {
+			continue
+		}
+		m := st.Migration
+		if m == nil {
+			return done, fmt.Errorf("can't roll back migration %d (%s), it's not registered", st.Version, st.Name)
+		}
+		if m.Down == nil {
+			return done, fmt.Errorf("migration %s can't be rolled back, it has no Down function", m)
+		}
+		if err := r.run(m, false); err != nil {
+			return done, fmt.Errorf("error rolling back migration %s: %s", m, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Status returns the status of the known migrations, both the
+// registered ones and the ones recorded as applied in the
+// database, sorted by version. Status doesn't create the table
+// which records the applied migrations if it doesn't exist yet.
+func Status(o *orm.Orm, opts *Options) ([]*MigrationStatus, error) {
+	r, err := newRunner(o, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.status()
+}
+
+type runner struct {
+	o          *orm.Orm
+	db         *sql.DB
+	dryRun     io.Writer
+	migrations []*Migration
+	// true iff the migrations table exists or
+	// has been created
+	hasTable bool
+}
+
+func newRunner(o *orm.Orm, opts *Options) (*runner, error) {
+	db := o.SqlDB()
+	if db == nil {
+		return nil, fmt.Errorf("migrations require a database/sql based ORM driver, not %T", o.Driver())
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	migrations := opts.Migrations
+	if len(migrations) == 0 {
+		migrations = Registered()
+	}
+	sorted, err := sortedMigrations(migrations)
+	if err != nil {
+		return nil, err
+	}
+	return &runner{
+		o:          o,
+		db:         db,
+		dryRun:     opts.DryRun,
+		migrations: sorted,
+	}, nil
+}
+
+func (r *runner) table() string {
+	return r.db.QuoteIdentifier(Table)
+}
+
+// tableExists returns true iff the table which
+// records the applied migrations exists.
+func (r *runner) tableExists() bool {
+	if !r.hasTable {
+		var count int
+		r.hasTable = r.db.QueryRow("SELECT COUNT(*) FROM "+r.table()).Scan(&count) == nil
+	}
+	return r.hasTable
+}
+
+func (r *runner) ensureTable() error {
+	if r.tableExists() {
+		return nil
+	}
+	db := r.db
+	if r.dryRun != nil {
+		db = db.DryRun(r.dryRun)
+	}
+	_, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s BIGINT NOT NULL PRIMARY KEY, %s VARCHAR(255) NOT NULL, %s BIGINT NOT NULL)",
+		r.table(), db.QuoteIdentifier("version"), db.QuoteIdentifier("name"), db.QuoteIdentifier("applied")))
+	if err != nil {
+		return fmt.Errorf("error creating migrations table: %s", err)
+	}
+	r.hasTable = r.dryRun == nil
+	return nil
+}
+
+func (r *runner) applied() (map[int64]*MigrationStatus, error) {
+	applied := make(map[int64]*MigrationStatus)
+	if !r.tableExists() {
+		// Not created yet or dry run
+		return applied, nil
+	}
+	rows, err := r.db.Query(fmt.Sprintf("SELECT %s, %s, %s FROM %s", r.db.QuoteIdentifier("version"),
+		r.db.QuoteIdentifier("name"), r.db.QuoteIdentifier("applied"), r.table()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var st MigrationStatus
+		var ts int64
+		if err := rows.Scan(&st.Version, &st.Name, &ts); err != nil {
+			return nil, err
+		}
+		st.Applied = time.Unix(ts, 0)
+		applied[st.Version] = &st
+	}
+	return applied, rows.Err()
+}
+
+func (r *runner) status() ([]*MigrationStatus, error) {
+	applied, err := r.applied()
+	if err != nil {
+		return nil, err
+	}
+	var statuses []*MigrationStatus
+	for _, v := range r.migrations {
+		st := applied[v.Version]
+		if st == nil {
+			st = &MigrationStatus{Version: v.Version, Name: v.Name}
+		}
+		st.Migration = v
+		delete(applied, v.Version)
+		statuses = append(statuses, st)
+	}
+	// Applied, but not registered
+	for _, v := range applied {
+		statuses = append(statuses, v)
+	}
+	sort.Sort(statusList(statuses))
+	return statuses, nil
+}
+
+type statusList []*MigrationStatus
+
+func (s statusList) Len() int           { return len(s) }
+func (s statusList) Less(i, j int) bool { return s[i].Version < s[j].Version }
+func (s statusList) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// run applies or reverts the given migration, depending on up,
+// inside a transaction if the driver supports it.
+func (r *runner) run(m *Migration, up bool) error {
+	fn := m.Up
+	if !up {
+		fn = m.Down
+	}
+	if r.dryRun != nil {
+		action := "Applying"
+		if !up {
+			action = "Reverting"
+		}
+		if _, err := fmt.Fprintf(r.dryRun, "-- %s migration %s\n", action, m); err != nil {
+			return err
+		}
+		mg := &Migrator{o: r.o, db: r.db.DryRun(r.dryRun)}
+		if err := fn(mg); err != nil {
+			return err
+		}
+		return r.record(mg.db, m, up)
+	}
+	f := func(o *orm.Orm) error {
+		mg := &Migrator{o: o, db: o.SqlDB()}
+		if err := fn(mg); err != nil {
+			return err
+		}
+		return r.record(mg.db, m, up)
+	}
+	if r.o.Driver().Capabilities()&driver.CAP_BEGIN != 0 {
+		return r.transaction(f)
+	}
+	return f(r.o)
+}
+
+// transaction runs f in a transaction. Backends which alter tables by
+// rebuilding them (see sql.TableRebuilder) need foreign keys disabled
+// while the migration runs, otherwise rebuilding a table referenced by
+// others would trigger the actions of their foreign keys. Foreign keys
+// can't be disabled inside a transaction, so in that case it's started on
+// a dedicated connection with foreign keys disabled, which are checked
+// before committing.
+func (r *runner) transaction(f func(o *orm.Orm) error) error {
+	tr, ok := r.db.Backend().(sql.TableRebuilder)
+	if !ok || r.db.InTransaction() {
+		return r.o.Transaction(f)
+	}
+	o, err := r.o.Conn()
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+	db := o.SqlDB()
+	enabled, err := tr.ForeignKeys(db)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		if err := tr.SetForeignKeys(db, false); err != nil {
+			return err
+		}
+		defer tr.SetForeignKeys(db, true)
+	}
+	return o.Transaction(func(o *orm.Orm) error {
+		if err := f(o); err != nil {
+			return err
+		}
+		return tr.CheckForeignKeys(o.SqlDB())
+	})
+}
+
+func (r *runner) record(db *sql.DB, m *Migration, up bool) error {
+	var err error
+	if up {
+		_, err = db.Exec(fmt.Sprintf("INSERT INTO %s (%s, %s, %s) VALUES (?, ?, ?)", r.table(), db.QuoteIdentifier("version"),
+			db.QuoteIdentifier("name"), db.QuoteIdentifier("applied")), m.Version, m.Name, time.Now().Unix())
+	} else {
+		_, err = db.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", r.table(), db.QuoteIdentifier("version")), m.Version)
+	}
+	return err
+}
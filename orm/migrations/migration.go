@@ -0,0 +1,119 @@
+package migrations
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	registered = struct {
+		sync.RWMutex
+		migrations []*Migration
+	}{}
+)
+
+// Func is the type of the functions used to apply and
+// revert a Migration.
+type Func func(m *Migrator) error
+
+// SQL returns a Func which executes the given statements,
+// in order.
+func SQL(stmts ...string) Func {
+	return func(m *Migrator) error {
+		for _, v := range stmts {
+			if err := m.Exec(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Migration represents a versioned change to the database.
+type Migration struct {
+	// Version is the migration version, which must be
+	// unique and greater than zero. Migrations are applied
+	// in ascending version order and reverted in descending
+	// order. Using a timestamp (e.g. 20141017103000) helps
+	// avoiding collisions when several people work on the
+	// same app.
+	Version int64
+	// Name is a short description of the migration.
+	Name string
+	// Up applies the migration. It's required.
+	Up Func
+	// Down reverts the migration. If it's nil, the
+	// migration can't be rolled back.
+	Down Func
+}
+
+func (m *Migration) String() string {
+	if m.Name != "" {
+		return fmt.Sprintf("%d (%s)", m.Version, m.Name)
+	}
+	return fmt.Sprintf("%d", m.Version)
+}
+
+type migrationList []*Migration
+
+func (m migrationList) Len() int           { return len(m) }
+func (m migrationList) Less(i, j int) bool { return m[i].Version < m[j].Version }
+func (m migrationList) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+func sortedMigrations(migrations []*Migration) ([]*Migration, error) {
+	sorted := make(migrationList, len(migrations))
+	copy(sorted, migrations)
+	sort.Sort(sorted)
+	for ii, v := range sorted {
+		if err := v.check(); err != nil {
+			return nil, err
+		}
+		if ii > 0 && sorted[ii-1].Version == v.Version {
+			return nil, fmt.Errorf("duplicate migration version %d", v.Version)
+		}
+	}
+	return sorted, nil
+}
+
+func (m *Migration) check() error {
+	if m.Version <= 0 {
+		return fmt.Errorf("invalid migration version %d, must be > 0", m.Version)
+	}
+	if m.Up == nil {
+		return fmt.Errorf("migration %s has no Up function", m)
+	}
+	return nil
+}
+
+// Register registers a migration to be run by Migrate and Rollback.
+// Migrations are usually registered from init functions.
+func Register(m *Migration) error {
+	if err := m.check(); err != nil {
+		return err
+	}
+	registered.Lock()
+	defer registered.Unlock()
+	for _, v := range registered.migrations {
+		if v.Version == m.Version {
+			return fmt.Errorf("duplicate migration version %d: %s and %s", m.Version, v, m)
+		}
+	}
+	registered.migrations = append(registered.migrations, m)
+	return nil
+}
+
+// MustRegister works like Register, but panics if there's an error.
+func MustRegister(m *Migration) {
+	if err := Register(m); err != nil {
+		panic(err)
+	}
+}
+
+// Registered returns the registered migrations, sorted by version.
+func Registered() []*Migration {
+	registered.RLock()
+	defer registered.RUnlock()
+	sorted, _ := sortedMigrations(registered.migrations)
+	return sorted
+}
@@ -0,0 +1,242 @@
+package migrations
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"gnd.la/config"
+	"gnd.la/orm"
+
+	_ "gnd.la/orm/driver/sqlite"
+)
+
+var (
+	testMigrations = []*Migration{
+		{
+			Version: 1,
+			Name:    "create-users",
+			Up: SQL(`CREATE TABLE "users" ("Id" INTEGER PRIMARY KEY, "Email" TEXT UNIQUE, "Legacy" INTEGER)`,
+				`CREATE INDEX "users_legacy_email" ON "users" ("Legacy", "Email")`),
+			Down: SQL(`DROP TABLE "users"`),
+		},
+		{
+			Version: 2,
+			Name:    "rename-email",
+			Up: func(m *Migrator) error {
+				return m.RenameField("users", "Email", "Address")
+			},
+			Down: func(m *Migrator) error {
+				return m.RenameField("users", "Address", "Email")
+			},
+		},
+		{
+			Version: 3,
+			Name:    "drop-legacy",
+			Up: func(m *Migrator) error {
+				return m.DropField("users", "Legacy")
+			},
+			Down: SQL(`ALTER TABLE "users" ADD COLUMN "Legacy" INTEGER`),
+		},
+	}
+)
+
+func newTestOrm(t *testing.T) (*orm.Orm, func()) {
+	f, err := ioutil.TempFile("", "migrations-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	o, err := orm.New(config.MustParseURL("sqlite://" + f.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o, func() {
+		o.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func tableFields(t *testing.T, o *orm.Orm, table string) []string {
+	rows, err := o.SqlDB().Query("PRAGMA table_info(" + o.SqlDB().QuoteString(table) + ")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var fields []string
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, typ string
+		var def *string
+		if err := rows.Scan(&cid, &name, &typ, &notnull, &def, &pk); err != nil {
+			t.Fatal(err)
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+func expectFields(t *testing.T, o *orm.Orm, expected string) {
+	if f := strings.Join(tableFields(t, o, "users"), ","); f != expected {
+		t.Errorf("expecting fields %q, got %q", expected, f)
+	}
+}
+
+func expectStatus(t *testing.T, o *orm.Orm, opts *Options, expected ...bool) {
+	st, err := Status(o, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st) != len(expected) {
+		t.Fatalf("expecting %d statuses, got %d", len(expected), len(st))
+	}
+	for ii, v := range st {
+		if applied := !v.Applied.IsZero(); applied != expected[ii] {
+			t.Errorf("expecting migration %d applied = %v, got %v", v.Version, expected[ii], applied)
+		}
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	o, done := newTestOrm(t)
+	defer done()
+	opts := &Options{Migrations: testMigrations}
+	applied, err := Migrate(o, 2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("expecting 2 applied migrations, got %d", len(applied))
+	}
+	expectFields(t, o, "Id,Address,Legacy")
+	expectStatus(t, o, opts, true, true, false)
+	if _, err := o.SqlDB().Exec(`INSERT INTO "users" ("Address", "Legacy") VALUES ('foo@example.com', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(o, 0, opts); err != nil {
+		t.Fatal(err)
+	}
+	expectFields(t, o, "Id,Address")
+	expectStatus(t, o, opts, true, true, true)
+	// Data and unique constraints must be preserved
+	var address string
+	if err := o.SqlDB().QueryRow(`SELECT "Address" FROM "users"`).Scan(&address); err != nil || address != "foo@example.com" {
+		t.Errorf("expecting address foo@example.com, got %q (error %v)", address, err)
+	}
+	if _, err := o.SqlDB().Exec(`INSERT INTO "users" ("Address") VALUES ('foo@example.com')`); err == nil {
+		t.Error("expecting an error when violating users.Address UNIQUE constraint")
+	}
+	reverted, err := Rollback(o, 2, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 2 || reverted[0].Version != 3 || reverted[1].Version != 2 {
+		t.Errorf("expecting reverted migrations 3 and 2, got %v", reverted)
+	}
+	expectFields(t, o, "Id,Email,Legacy")
+	expectStatus(t, o, opts, true, false, false)
+}
+
+func TestMigrationsDryRun(t *testing.T) {
+	o, done := newTestOrm(t)
+	defer done()
+	var buf bytes.Buffer
+	opts := &Options{Migrations: testMigrations[:2], DryRun: &buf}
+	applied, err := Migrate(o, 0, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("expecting 2 applied migrations, got %d", len(applied))
+	}
+	out := buf.String()
+	t.Logf("dry run output:\n%s", out)
+	for _, v := range []string{
+		`CREATE TABLE IF NOT EXISTS "gondola_migrations"`,
+		"-- Applying migration 1 (create-users)",
+		`CREATE TABLE "users"`,
+		`ALTER TABLE "users" RENAME COLUMN "Email" TO "Address";`,
+		`INSERT INTO "gondola_migrations"`,
+	} {
+		if !strings.Contains(out, v) {
+			t.Errorf("expecting %q in dry run output", v)
+		}
+	}
+	if fields := tableFields(t, o, "users"); len(fields) > 0 {
+		t.Errorf("dry run created table users with fields %v", fields)
+	}
+	if fields := tableFields(t, o, Table); len(fields) > 0 {
+		t.Errorf("dry run created table %s", Table)
+	}
+}
+
+func TestMigrationsForeignKeys(t *testing.T) {
+	o, done := newTestOrm(t)
+	defer done()
+	migrations := []*Migration{
+		{
+			Version: 1,
+			Name:    "create-tables",
+			Up: SQL(`CREATE TABLE "users" ("Id" INTEGER PRIMARY KEY, "Name" TEXT, "Legacy" INTEGER)`,
+				`CREATE TABLE "posts" ("Id" INTEGER PRIMARY KEY, "UserId" INTEGER REFERENCES "users" ("Id") ON DELETE CASCADE)`,
+				`INSERT INTO "users" ("Id", "Name", "Legacy") VALUES (1, 'alice', 0)`,
+				`INSERT INTO "posts" ("UserId") VALUES (1)`),
+		},
+		{
+			Version: 2,
+			Name:    "drop-legacy",
+			Up: func(m *Migrator) error {
+				return m.DropField("users", "Legacy")
+			},
+		},
+	}
+	if _, err := Migrate(o, 0, &Options{Migrations: migrations}); err != nil {
+		t.Fatal(err)
+	}
+	expectFields(t, o, "Id,Name")
+	var count int
+	if err := o.SqlDB().QueryRow(`SELECT COUNT(*) FROM "posts"`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("expecting 1 post after rebuilding users, got %d (error %v)", count, err)
+	}
+	// Foreign keys must be enabled again
+	if _, err := o.SqlDB().Exec(`INSERT INTO "posts" ("UserId") VALUES (2)`); err == nil {
+		t.Error("expecting an error when violating posts.UserId FOREIGN KEY constraint")
+	}
+}
+
+func TestStatusReadOnly(t *testing.T) {
+	o, done := newTestOrm(t)
+	defer done()
+	opts := &Options{Migrations: testMigrations}
+	expectStatus(t, o, opts, false, false, false)
+	if fields := tableFields(t, o, Table); len(fields) > 0 {
+		t.Errorf("Status created table %s", Table)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	bad := []*Migration{
+		{Version: 0, Up: SQL("")},
+		{Version: 1},
+	}
+	for _, v := range bad {
+		if err := Register(v); err == nil {
+			t.Errorf("expecting an error when registering %+v", v)
+		}
+	}
+	o, done := newTestOrm(t)
+	defer done()
+	dup := []*Migration{{Version: 1, Up: SQL("")}, {Version: 1, Up: SQL("")}}
+	if _, err := Migrate(o, 0, &Options{Migrations: dup}); err == nil {
+		t.Error("expecting an error with duplicate migration versions")
+	}
+	rollback := []*Migration{{Version: 1, Up: func(m *Migrator) error { return nil }}}
+	if _, err := Migrate(o, 0, &Options{Migrations: rollback}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Rollback(o, 1, &Options{Migrations: rollback}); err == nil {
+		t.Error("expecting an error when rolling back migration without Down")
+	}
+}
@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"gnd.la/orm"
+	"gnd.la/orm/driver/sql"
+)
+
+// Migrator is passed to the functions which apply and revert
+// migrations. Its methods translate each operation into the
+// statements required by the database backend, taking care of
+// the differences between them (e.g. sqlite can't drop fields,
+// so the table is rebuilt instead).
+//
+// When running in dry mode, the statements are written to the
+// output rather than executed.
+type Migrator struct {
+	o  *orm.Orm
+	db *sql.DB
+}
+
+// Orm returns the ORM the migration is being run on. If the
+// database supports transactions, the returned ORM is bound
+// to the transaction the migration is running in.
+func (m *Migrator) Orm() *orm.Orm {
+	return m.o
+}
+
+// DB returns the database the migration is being run on. In dry
+// mode, the DB only prints the statements passed to its Exec method.
+func (m *Migrator) DB() *sql.DB {
+	return m.db
+}
+
+// DryRun returns true iff the migration is running in dry mode.
+func (m *Migrator) DryRun() bool {
+	return m.db.IsDryRun()
+}
+
+// Backend returns the name of the database backend (e.g. sqlite3,
+// postgres or mysql), for migrations which need to run different
+// statements for each one.
+func (m *Migrator) Backend() string {
+	return m.db.Backend().Name()
+}
+
+// Exec executes the given statement. Placeholders must be
+// written as ?, regardless of the backend.
+func (m *Migrator) Exec(query string, args ...interface{}) error {
+	_, err := m.db.Exec(query, args...)
+	return err
+}
+
+// RenameTable renames the table from to the name to.
+func (m *Migrator) RenameTable(from string, to string) error {
+	return m.Exec("ALTER TABLE " + m.db.QuoteIdentifier(from) + " RENAME TO " + m.db.QuoteIdentifier(to))
+}
+
+// DropTable removes the given table, if it exists.
+func (m *Migrator) DropTable(table string) error {
+	return m.Exec("DROP TABLE IF EXISTS " + m.db.QuoteIdentifier(table))
+}
+
+// RenameField renames the field from to the name to in
+// the given table.
+func (m *Migrator) RenameField(table string, from string, to string) error {
+	return m.db.Backend().RenameField(m.db, table, from, to)
+}
+
+// DropField removes the given field from the table.
+func (m *Migrator) DropField(table string, field string) error {
+	return m.db.Backend().DropField(m.db, table, field)
+}
@@ -9,6 +9,12 @@ type Referenced struct {
 	Value string
 }
 
+type Referenced2 struct {
+	Id     int64 `orm:",primary_key,auto_increment"`
+	Value  string
+	Value2 string `orm:",notnull,default=Gondola"`
+}
+
 type Migration1 struct {
 	Id int64 `orm:",primary_key,auto_increment"`
 }
@@ -39,7 +45,8 @@ type Migration4 struct {
 }
 
 var (
-	migrationOptions = &Options{Name: "Migration", Table: "migration"} // This ensures the same table is always used
+	migrationOptions  = &Options{Name: "Migration", Table: "migration"} // This ensures the same table is always used
+	referencedOptions = &Options{Name: "Referenced", Table: "migration_referenced"}
 )
 
 func testMigrations(t *testing.T, o *Orm) {
@@ -97,7 +104,7 @@ func testMigrations(t *testing.T, o *Orm) {
 		}
 	}
 	clearRegistry()
-	o.mustRegister((*Referenced)(nil), nil)
+	o.mustRegister((*Referenced)(nil), referencedOptions)
 	o.mustRegister((*Migration4)(nil), migrationOptions)
 	if err := o.Initialize(); err != nil {
 		t.Errorf("error initializing Migration4: %s", err)
@@ -116,6 +123,22 @@ func testMigrations(t *testing.T, o *Orm) {
 		t.Errorf("error inserting FK %+v: %s", m4, err)
 	}
 	tx.MustCommit()
+	// Altering a referenced table must not delete
+	// nor break the rows referencing it.
+	clearRegistry()
+	o.mustRegister((*Referenced2)(nil), referencedOptions)
+	o.mustRegister((*Migration4)(nil), migrationOptions)
+	if err := o.Initialize(); err != nil {
+		t.Errorf("error initializing Referenced2: %s", err)
+	}
+	var m4c *Migration4
+	if found, err := o.Query(Eq("Id", m4.Id)).One(&m4c); err != nil {
+		t.Errorf("error querying Migration4: %s", err)
+	} else if !found {
+		t.Error("Migration4 was deleted after altering Referenced")
+	} else if m4c.Reference != ref.Id {
+		t.Errorf("expecting Migration4.Reference = %v, got %v", ref.Id, m4c.Reference)
+	}
 }
 
 func TestMigrations(t *testing.T) {
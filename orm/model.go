@@ -33,8 +33,9 @@ func (j JoinType) String() string {
 }
 
 type reference struct {
-	model string
-	field string
+	model    string
+	field    string
+	onDelete driver.ReferenceAction
 }
 
 type model struct {
@@ -90,18 +91,39 @@ func (m *model) Indexes() []*index.Index {
 }
 
 func (m *model) Map(qname string) (string, reflect.Type, error) {
+	n, err := m.fieldIndex(qname)
+	if err != nil {
+		return "", nil, err
+	}
+	return m.fields.QuotedNames[n], m.fields.Types[n], nil
+}
+
+// fieldIndex returns the index of the field with the given
+// qualified name, which might be prefixed by the model name
+// (e.g. Type|Field or Type.Field).
+func (m *model) fieldIndex(qname string) (int, error) {
 	sep := strings.IndexByte(qname, '|')
 	if sep >= 0 {
 		name := qname[:sep]
 		if name != m.name && name != m.shortName {
-			return "", nil, errNotThisModel(name)
+			return -1, errNotThisModel(name)
 		}
 		qname = qname[sep+1:]
 	}
 	if n, ok := m.fields.QNameMap[qname]; ok {
-		return m.fields.QuotedNames[n], m.fields.Types[n], nil
+		return n, nil
+	}
+	if sep < 0 {
+		// Also accept Type.Field
+		for _, v := range []string{m.shortName, m.name} {
+			if strings.HasPrefix(qname, v) && len(qname) > len(v) && qname[len(v)] == '.' {
+				if n, ok := m.fields.QNameMap[qname[len(v)+1:]]; ok {
+					return n, nil
+				}
+			}
+		}
 	}
-	return "", nil, errCantMap(qname)
+	return -1, errCantMap(qname)
 }
 
 func (m *model) Skip() bool {
@@ -31,4 +31,10 @@ type Options struct {
 	// defined in both the a field tag and using this field, an
 	// error will be returned when registering the model.
 	PrimaryKey []string
+	// Validate indicates if the objects should be validated using
+	// the rules declared in their tags (e.g. `orm:",email"`) before
+	// being inserted or updated. See gnd.la/util/structs.RegisterRule
+	// for the available rules. Validation errors are returned as a
+	// *structs.FieldError.
+	Validate bool
 }
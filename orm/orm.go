@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"gnd.la/app/profile"
 	"gnd.la/config"
@@ -12,6 +13,7 @@ import (
 	"gnd.la/orm/driver"
 	"gnd.la/orm/driver/sql"
 	"gnd.la/orm/query"
+	"gnd.la/util/structs"
 	"gnd.la/util/types"
 )
 
@@ -37,6 +39,12 @@ const (
 	orm             = "orm"
 )
 
+// connDriver is implemented by drivers which
+// support dedicated connections, see Orm.Conn.
+type connDriver interface {
+	Conn() (driver.Driver, error)
+}
+
 type Orm struct {
 	conn         driver.Conn
 	driver       driver.Driver
@@ -45,6 +53,8 @@ type Orm struct {
 	typeRegistry typeRegistry
 	// these fields are non-nil iff the ORM driver uses database/sql
 	db *sql.DB
+	// true iff this Orm is bound to a transaction
+	inTransaction bool
 }
 
 // Table returns a Query object initialized with the given table.
@@ -116,7 +126,7 @@ func (o *Orm) Insert(obj interface{}) (Result, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := m.fields.Methods.Save(obj); err != nil {
+	if err := o.prepareSave(m, obj, nil); err != nil {
 		return nil, err
 	}
 	return o.insert(m, obj)
@@ -132,10 +142,58 @@ func (o *Orm) MustInsert(obj interface{}) Result {
 	return res
 }
 
+// prepareSave calls the save methods of the fields in obj and,
+// if the model requires it, validates obj using its tag rules.
+// If fields is not empty, only the given fields are validated.
+func (o *Orm) prepareSave(m *model, obj interface{}, fields []string) error {
+	if err := m.fields.Methods.Save(obj); err != nil {
+		return err
+	}
+	if m.options != nil && m.options.Validate {
+		var qnames []string
+		for _, v := range fields {
+			n, err := m.fieldIndex(v)
+			if err != nil {
+				return err
+			}
+			qnames = append(qnames, m.fields.QNames[n])
+		}
+		return structs.ValidateFields(obj, o.dtags(), qnames)
+	}
+	return nil
+}
+
 func (o *Orm) insert(m *model, obj interface{}) (Result, error) {
+	return o.withHooks(hookInsert, obj, func(o *Orm) (Result, error) {
+		return o.doInsert(m, obj)
+	})
+}
+
+func (o *Orm) doInsert(m *model, obj interface{}) (Result, error) {
 	if profile.On && profile.Profiling() {
 		defer profile.Start(orm).Note("insert", m.name).End()
 	}
+	obj, pkName, pkVal, err := o.prepareInsert(m, obj)
+	if err != nil {
+		return nil, err
+	}
+	res, err := o.conn.Insert(m, obj)
+	if err == nil && pkVal.IsValid() && pkVal.Int() == 0 {
+		id, err := res.LastInsertId()
+		if err == nil && id != 0 {
+			o.setPrimaryKey(m, pkName, pkVal, id)
+		} else if err != nil && o.logger != nil {
+			o.logger.Errorf("could not obtain last insert id: %s", err)
+		}
+	}
+	return res, err
+}
+
+// prepareInsert sets the default values and the created and updated
+// times in obj, copying it if required. It returns the object to
+// insert and, for models with an auto incremented primary key, its
+// name and value.
+func (o *Orm) prepareInsert(m *model, obj interface{}) (interface{}, string, reflect.Value, error) {
 	var pkName string
 	var pkVal reflect.Value
 	f := m.fields
@@ -143,7 +201,7 @@ func (o *Orm) insert(m *model, obj interface{}) (Result, error) {
 		pkName, pkVal = o.primaryKey(f, obj)
 		if pkVal.Int() == 0 && !pkVal.CanSet() {
 			typ := reflect.TypeOf(obj)
-			return nil, fmt.Errorf("can't set primary key field %q. Please, insert a %v rather than a %v", pkName, reflect.PtrTo(typ), typ)
+			return nil, "", reflect.Value{}, fmt.Errorf("can't set primary key field %q. Please, insert a %v rather than a %v", pkName, reflect.PtrTo(typ), typ)
 		}
 	}
 	if f.Defaults != nil {
@@ -170,19 +228,17 @@ func (o *Orm) insert(m *model, obj interface{}) (Result, error) {
 			}
 		}
 	}
-	res, err := o.conn.Insert(m, obj)
-	if err == nil && pkVal.IsValid() && pkVal.Int() == 0 {
-		id, err := res.LastInsertId()
-		if err == nil && id != 0 {
-			if o.logger != nil {
-				o.logger.Debugf("Setting primary key %q to %d on model %v", pkName, id, m.Type())
-			}
-			pkVal.SetInt(id)
-		} else if err != nil && o.logger != nil {
-			o.logger.Errorf("could not obtain last insert id: %s", err)
-		}
+	if f.Created >= 0 || f.Updated >= 0 {
+		obj = o.setInsertTimes(f, obj)
 	}
-	return res, err
+	return obj, pkName, pkVal, nil
+}
+
+func (o *Orm) setPrimaryKey(m *model, pkName string, pkVal reflect.Value, id int64) {
+	if o.logger != nil {
+		o.logger.Debugf("Setting primary key %q to %d on model %v", pkName, id, m.Type())
+	}
+	pkVal.SetInt(id)
 }
 
 func (o *Orm) Update(q query.Q, obj interface{}) (Result, error) {
@@ -190,10 +246,10 @@ func (o *Orm) Update(q query.Q, obj interface{}) (Result, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := m.fields.Methods.Save(obj); err != nil {
+	if err := o.prepareSave(m, obj, nil); err != nil {
 		return nil, err
 	}
-	return o.update(m, q, obj)
+	return o.update(m, q, obj, nil)
 }
 
 // MustUpdate works like update, but panics if there's
@@ -206,11 +262,45 @@ func (o *Orm) MustUpdate(q query.Q, obj interface{}) Result {
 	return res
 }
 
-func (o *Orm) update(m *model, q query.Q, obj interface{}) (Result, error) {
-	if profile.On && profile.Profiling() {
-		defer profile.Start(orm).Note("update", m.name).End()
+// UpdateFields works like Update, but only updates the
+// given fields, leaving the rest untouched. If no fields
+// are provided, all the fields are updated. For models with
+// Options.Validate, only the given fields are validated.
+func (o *Orm) UpdateFields(q query.Q, obj interface{}, fields ...string) (Result, error) {
+	m, err := o.model(obj)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.prepareSave(m, obj, fields); err != nil {
+		return nil, err
+	}
+	return o.update(m, q, obj, fields)
+}
+
+// MustUpdateFields works like UpdateFields, but panics if
+// there's an error.
+func (o *Orm) MustUpdateFields(q query.Q, obj interface{}, fields ...string) Result {
+	res, err := o.UpdateFields(q, obj, fields...)
+	if err != nil {
+		panic(err)
 	}
-	return o.conn.Update(m, q, obj)
+	return res
+}
+
+func (o *Orm) update(m *model, q query.Q, obj interface{}, fields []string) (Result, error) {
+	if f := m.fields; f.Updated >= 0 {
+		obj = o.setTime(f, obj, f.Updated, time.Now().UTC(), false)
+		if len(fields) > 0 {
+			// Don't write into the caller's backing array
+			fields = append(fields[:len(fields):len(fields)], m.fullName(f.QNames[f.Updated]))
+		}
+	}
+	return o.withHooks(hookUpdate, obj, func(o *Orm) (Result, error) {
+		if profile.On && profile.Profiling() {
+			defer profile.Start(orm).Note("update", m.name).End()
+		}
+		return o.conn.Update(m, q, obj, fields)
+	})
 }
 
 // Upsert tries to perform an update with the given query
@@ -223,16 +313,18 @@ func (o *Orm) Upsert(q query.Q, obj interface{}) (Result, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := m.fields.Methods.Save(obj); err != nil {
+	if err := o.prepareSave(m, obj, nil); err != nil {
 		return nil, err
 	}
 	if o.driver.Upserts() {
-		if profile.On && profile.Profiling() {
-			defer profile.Start(orm).Note("upsert", "").End()
-		}
-		return o.conn.Upsert(m, q, obj)
+		return o.withHooks(hookUpdate, obj, func(o *Orm) (Result, error) {
+			if profile.On && profile.Profiling() {
+				defer profile.Start(orm).Note("upsert", "").End()
+			}
+			return o.conn.Upsert(m, q, obj)
+		})
 	}
-	res, err := o.update(m, q, obj)
+	res, err := o.update(m, q, obj, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -263,13 +355,15 @@ func (o *Orm) MustUpsert(q query.Q, obj interface{}) Result {
 // affected rows, an insert will be performed. Save also
 // supports models with composite keys. If any field forming
 // the composite key is non-zero, an update will be tried
-// before performing an insert.
+// before performing an insert. Note that when the update
+// doesn't affect any rows, the BeforeUpdate hook will have
+// been called before the insert hooks (see BeforeInserter).
 func (o *Orm) Save(obj interface{}) (Result, error) {
 	m, err := o.model(obj)
 	if err != nil {
 		return nil, err
 	}
-	if err := m.fields.Methods.Save(obj); err != nil {
+	if err := o.prepareSave(m, obj, nil); err != nil {
 		return nil, err
 	}
 	return o.save(m, obj)
@@ -296,7 +390,7 @@ func (o *Orm) save(m *model, obj interface{}) (Result, error) {
 		if driver.IsZero(pkVal) {
 			return o.insert(m, obj)
 		}
-		res, err = o.update(m, Eq(pkName, pkVal.Interface()), obj)
+		res, err = o.update(m, Eq(pkName, pkVal.Interface()), obj, nil)
 	} else if len(m.fields.CompositePrimaryKey) > 0 {
 		// Composite primary key
 		names, values := o.compositePrimaryKey(m.fields, obj)
@@ -307,7 +401,7 @@ func (o *Orm) save(m *model, obj interface{}) (Result, error) {
 				for ii := range names {
 					qs[ii] = Eq(names[ii], values[ii].Interface())
 				}
-				res, err = o.update(m, And(qs...), obj)
+				res, err = o.update(m, And(qs...), obj, nil)
 				break
 			}
 		}
@@ -333,20 +427,56 @@ func (o *Orm) save(m *model, obj interface{}) (Result, error) {
 }
 
 // DeleteFrom removes all objects from the given table matching
-// the query.
+// the query. If the model uses soft deletion, the objects are
+// marked as deleted instead (see Query.WithDeleted).
 func (o *Orm) DeleteFrom(t *Table, q query.Q) (Result, error) {
-	return o.delete(t.model.model, q)
+	return o.delete(t.model.model, q, false)
+}
+
+// HardDeleteFrom works like DeleteFrom, but it always removes
+// the objects, even if the model uses soft deletion.
+func (o *Orm) HardDeleteFrom(t *Table, q query.Q) (Result, error) {
+	return o.delete(t.model.model, q, true)
 }
 
 // Delete removes the given object, which must be of a type
 // previously registered as a table and must have a primary key,
-// either simple or composite.
+// either simple or composite. If the model uses soft deletion,
+// the object is marked as deleted instead (see Query.WithDeleted).
+//
+// Fields referencing other models might specify what happens when
+// the referenced object is deleted with the on_delete tag, which
+// accepts the values cascade, set_null, restrict and no_action
+// (the default) e.g.
+//
+//  AuthorId int64 `orm:",references=User,on_delete=cascade"`
+//
+// SQL drivers enforce these actions using foreign keys, while
+// the ORM emulates them for drivers without support for them
+// (see driver.CAP_FOREIGN_KEY).
 func (o *Orm) Delete(obj interface{}) error {
 	m, err := o.model(obj)
 	if err != nil {
 		return err
 	}
-	return o.deleteByPk(m, obj)
+	return o.deleteByPk(m, obj, false)
+}
+
+// HardDelete works like Delete, but it always removes the
+// object, even if its model uses soft deletion.
+func (o *Orm) HardDelete(obj interface{}) error {
+	m, err := o.model(obj)
+	if err != nil {
+		return err
+	}
+	return o.deleteByPk(m, obj, true)
+}
+
+// MustHardDelete works like HardDelete, but panics if there's an error.
+func (o *Orm) MustHardDelete(obj interface{}) {
+	if err := o.HardDelete(obj); err != nil {
+		panic(err)
+	}
 }
 
 // MustDelete works like Delete, but panics if there's an error.
@@ -356,7 +486,7 @@ func (o *Orm) MustDelete(obj interface{}) {
 	}
 }
 
-func (o *Orm) deleteByPk(m *model, obj interface{}) error {
+func (o *Orm) deleteByPk(m *model, obj interface{}, hard bool) error {
 	var q query.Q
 	if m.fields.PrimaryKey >= 0 {
 		pkName, pkVal := o.primaryKey(m.fields, obj)
@@ -374,20 +504,39 @@ func (o *Orm) deleteByPk(m *model, obj interface{}) error {
 	if q == nil {
 		return fmt.Errorf("type %T does not have a primary key", obj)
 	}
-	_, err := o.delete(m, q)
+	_, err := o.withHooks(hookDelete, obj, func(o *Orm) (Result, error) {
+		return o.delete(m, q, hard)
+	})
+	if err == nil && !hard && m.fields.SoftDelete >= 0 {
+		// Mark the object as deleted too
+		if fval := o.fieldByIndex(reflect.ValueOf(obj), m.fields.Indexes[m.fields.SoftDelete]); fval.CanSet() {
+			setTimeValue(fval, time.Now().UTC())
+		}
+	}
 	return err
 }
 
-func (o *Orm) delete(m *model, q query.Q) (Result, error) {
+func (o *Orm) delete(m *model, q query.Q, hard bool) (Result, error) {
 	if profile.On && profile.Profiling() {
 		defer profile.Start(orm).Note("delete", m.name).End()
 	}
+	if !hard && m.fields.SoftDelete >= 0 {
+		return o.softDelete(m, q)
+	}
+	if o.driver.Capabilities()&driver.CAP_FOREIGN_KEY == 0 {
+		if err := o.emulateOnDelete(m, q); err != nil {
+			return nil, err
+		}
+	}
 	return o.conn.Delete(m, q)
 }
 
 // Begin starts a new transaction. If the driver does
 // not support transactions, Begin will return a fake
-// transaction.
+// transaction. When called from a transaction, Begin
+// starts a nested transaction using a savepoint if the
+// driver supports it (see driver.CAP_SAVEPOINT), or
+// returns ErrInTransaction otherwise.
 func (o *Orm) Begin() (*Tx, error) {
 	caps := o.driver.Capabilities()
 	if caps&driver.CAP_BEGIN == 0 {
@@ -396,15 +545,33 @@ func (o *Orm) Begin() (*Tx, error) {
 		}
 		return nil, fmt.Errorf("ORM driver %T does not support Begin/Commit/Rollback - use Orm.Transaction instead", o.driver)
 	}
-	tx, err := o.driver.Begin()
+	var tx driver.Tx
+	var err error
+	if o.inTransaction {
+		b, ok := o.conn.(driver.Beginner)
+		if !ok || caps&driver.CAP_SAVEPOINT == 0 {
+			return nil, ErrInTransaction
+		}
+		tx, err = b.Begin()
+	} else {
+		tx, err = o.driver.Begin()
+	}
 	if err != nil {
 		return nil, err
 	}
 	if o.logger != nil {
-		o.logger.Debugf("Beginning transaction")
+		if o.inTransaction {
+			o.logger.Debugf("Beginning nested transaction")
+		} else {
+			o.logger.Debugf("Beginning transaction")
+		}
 	}
 	cpy := *o
 	cpy.conn = tx
+	cpy.inTransaction = true
+	if db, ok := tx.Connection().(*sql.DB); ok {
+		cpy.db = db
+	}
 	return &Tx{
 		Orm: cpy,
 		o:   o,
@@ -412,6 +579,32 @@ func (o *Orm) Begin() (*Tx, error) {
 	}, nil
 }
 
+// Conn returns a copy of the Orm which runs all its queries and
+// transactions on the same database connection, which is released
+// when the returned Orm is closed. This is only supported by drivers
+// based on database/sql. See gnd.la/orm/driver/sql.DB.Conn for
+// more details.
+func (o *Orm) Conn() (*Orm, error) {
+	if o.inTransaction {
+		return nil, ErrInTransaction
+	}
+	c, ok := o.driver.(connDriver)
+	if !ok {
+		return nil, fmt.Errorf("ORM driver %T does not support dedicated connections", o.driver)
+	}
+	drv, err := c.Conn()
+	if err != nil {
+		return nil, err
+	}
+	cpy := *o
+	cpy.conn = drv
+	cpy.driver = drv
+	if db, ok := drv.Connection().(*sql.DB); ok {
+		cpy.db = db
+	}
+	return &cpy, nil
+}
+
 // MustBegin works like Begin, but panics if there's an error.
 func (o *Orm) MustBegin() *Tx {
 	tx, err := o.Begin()
@@ -430,6 +623,11 @@ func (o *Orm) MustBegin() *Tx {
 // error will be returned from Transaction. If no errors are returned
 // from f, the transaction is commited and the only error that might be
 // returned from Transaction will be one produced while committing.
+//
+// Transaction might be also called from another transaction. In that
+// case, f runs in a nested transaction (see Begin), so returning an
+// error from f only rolls back the changes made by f. Drivers without
+// support for nested transactions return ErrInTransaction.
 func (o *Orm) Transaction(f func(o *Orm) error) error {
 	caps := o.driver.Capabilities()
 	if caps&driver.CAP_TRANSACTION == 0 {
@@ -449,9 +647,13 @@ func (o *Orm) Transaction(f func(o *Orm) error) error {
 		}
 		return tx.Commit()
 	}
+	if o.inTransaction {
+		return ErrInTransaction
+	}
 	err := o.driver.Transaction(func(d driver.Driver) error {
 		oc := *o
 		oc.conn = d
+		oc.inTransaction = true
 		return f(&oc)
 	})
 	if err == Rollback {
@@ -485,7 +687,8 @@ func (o *Orm) Driver() driver.Driver {
 // database/sql.DB, but gnd.la/orm/driver/sql.DB, which is
 // a small compatibility wrapper around the former. See the
 // gnd.la/orm/driver/sql.DB documentation for further
-// information.
+// information. When called on a transaction, the returned
+// DB executes its statements inside the transaction.
 func (o *Orm) SqlDB() *sql.DB {
 	return o.db
 }
@@ -592,7 +795,11 @@ func (o *Orm) compositePrimaryKey(f *driver.Fields, obj interface{}) ([]string,
 }
 
 // Open creates a new ORM using the specified
-// configuration URL.
+// configuration URL. For SQL databases, the URL fragment
+// might specify the connection pool settings (max_conns,
+// max_idle_conns and conn_max_lifetime) as well as a list
+// of read replicas separated by | (replicas). See
+// gnd.la/orm/driver/sql.NewDriver for more details.
 func New(url *config.URL) (*Orm, error) {
 	name := url.Scheme
 	opener := driver.Get(name)
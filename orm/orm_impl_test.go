@@ -428,6 +428,72 @@ func testFuncTransactions(t *testing.T, o *Orm) {
 	}
 }
 
+func testSavepoints(t *testing.T, o *Orm) {
+	if o.Driver().Capabilities()&driver.CAP_SAVEPOINT == 0 {
+		t.Log("skipping savepoints test")
+		return
+	}
+	table := o.mustRegister((*AutoIncrement)(nil), &Options{
+		Table: "test_savepoints",
+	})
+	o.mustInitialize()
+	exists := func(id int64) bool {
+		e, err := o.Exists(table, Eq("Id", id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	tx := o.MustBegin()
+	defer tx.Close()
+	outer := &AutoIncrement{}
+	tx.MustSave(outer)
+	nested := tx.MustBegin()
+	committed := &AutoIncrement{}
+	nested.MustSave(committed)
+	nested.MustCommit()
+	nested = tx.MustBegin()
+	rolledBack := &AutoIncrement{}
+	nested.MustSave(rolledBack)
+	nested.MustRollback()
+	var fromFunc AutoIncrement
+	if err := tx.Transaction(func(o *Orm) error {
+		o.MustSave(&fromFunc)
+		return Rollback
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nested.Begin(); err != ErrFinished {
+		t.Errorf("expecting ErrFinished from a finished transaction, got %v", err)
+	}
+	tx.MustCommit()
+	if !exists(outer.Id) {
+		t.Error("object from outer transaction does not exist")
+	}
+	if !exists(committed.Id) {
+		t.Error("object from committed nested transaction does not exist")
+	}
+	if exists(rolledBack.Id) {
+		t.Error("object from rolled back nested transaction exists")
+	}
+	if exists(fromFunc.Id) {
+		t.Error("object from rolled back nested Transaction exists")
+	}
+	// Rolling back the outer transaction undoes the nested ones
+	tx = o.MustBegin()
+	defer tx.Close()
+	if err := tx.Transaction(func(o *Orm) error {
+		_, err := o.Save(&fromFunc)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tx.MustRollback()
+	if exists(fromFunc.Id) {
+		t.Error("object from nested transaction exists after rolling back its parent")
+	}
+}
+
 func testCompositePrimaryKey(t *testing.T, o *Orm) {
 	if o.Driver().Capabilities()&driver.CAP_COMPOSITE_PK == 0 {
 		t.Log("skipping composite pk test")
@@ -672,12 +738,24 @@ func testOrm(t *testing.T, o *Orm) {
 		testInnerPointer,
 		testTransactions,
 		testFuncTransactions,
+		testSavepoints,
 		testCompositePrimaryKey,
 		testReferences,
+		testOnDelete,
+		testValidate,
 		testQueryAll,
 		testDefaults,
 		testMigrations,
 		testSaveUnchanged,
+		testAggregate,
+		testFields,
+		testHooks,
+		testTimestamps,
+		testSoftDelete,
+		testBatch,
+		testBatchHooks,
+		testPreload,
+		testCursor,
 	}
 	for _, v := range tests {
 		clearRegistry(o)
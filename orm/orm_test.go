@@ -36,6 +36,10 @@ func TestFuncTransactions(t *testing.T) {
 	runTest(t, testFuncTransactions)
 }
 
+func TestSavepoints(t *testing.T) {
+	runTest(t, testSavepoints)
+}
+
 func TestQueryAll(t *testing.T) {
 	runTest(t, testQueryAll)
 }
@@ -48,6 +52,14 @@ func TestReferences(t *testing.T) {
 	runTest(t, testReferences)
 }
 
+func TestOnDelete(t *testing.T) {
+	runTest(t, testOnDelete)
+}
+
+func TestValidate(t *testing.T) {
+	runTest(t, testValidate)
+}
+
 func TestInvalidCodecs(t *testing.T) {
 	runTest(t, testInvalidCodecs)
 }
@@ -72,6 +84,42 @@ func TestSaveUnchanged(t *testing.T) {
 	runTest(t, testSaveUnchanged)
 }
 
+func TestAggregate(t *testing.T) {
+	runTest(t, testAggregate)
+}
+
+func TestFields(t *testing.T) {
+	runTest(t, testFields)
+}
+
+func TestHooks(t *testing.T) {
+	runTest(t, testHooks)
+}
+
+func TestTimestamps(t *testing.T) {
+	runTest(t, testTimestamps)
+}
+
+func TestSoftDelete(t *testing.T) {
+	runTest(t, testSoftDelete)
+}
+
+func TestBatch(t *testing.T) {
+	runTest(t, testBatch)
+}
+
+func TestBatchHooks(t *testing.T) {
+	runTest(t, testBatchHooks)
+}
+
+func TestPreload(t *testing.T) {
+	runTest(t, testPreload)
+}
+
+func TestCursor(t *testing.T) {
+	runTest(t, testCursor)
+}
+
 func BenchmarkLoadSaveMethods(b *testing.B) {
 	runBenchmark(b, benchmarkLoadSaveMethods)
 }
@@ -0,0 +1,258 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+
+	"gnd.la/app/profile"
+	"gnd.la/orm/driver"
+	"gnd.la/orm/query"
+)
+
+// preloadChunkSize is the maximum number of values
+// used in each IN query when preloading objects.
+const preloadChunkSize = 500
+
+// preloadRelation describes how to load the objects of a
+// related model for the objects of a given model.
+type preloadRelation struct {
+	// related model
+	model *model
+	// index of the key field in the parent model
+	key int
+	// index of the key field in the related model
+	relatedKey int
+	// index of the struct field which stores the related objects
+	field []int
+	// true if the parent has many related objects
+	many bool
+}
+
+// Preload makes the query load the objects of the given models
+// which are related to the ones returned by One or All, using
+// one additional query per model (rather than one per returned
+// object). Models are identified by their name and the relation
+// is determined from their references (see the references tag). For
+// example, given the following models:
+//
+//  type Article struct {
+//	Id       int64 `orm:",primary_key,auto_increment"`
+//	Title    string
+//	Author   *User      `orm:"-"`
+//	Comments []*Comment `orm:"-"`
+//	AuthorId int64      `orm:",references=User"`
+//  }
+//
+//  type Comment struct {
+//	Id        int64 `orm:",primary_key,auto_increment"`
+//	ArticleId int64 `orm:",references=Article"`
+//	Body      string
+//  }
+//
+// Preload("Comment") stores in each Article the comments which
+// reference it, while Preload("User") stores in each Article the
+// User referenced by its AuthorId. Related objects are stored in
+// the field of the parent of type []T or []*T (when the related
+// model references the parent) or *T (when the parent references
+// the related model), which must be ignored by the ORM with the
+// "-" tag. Note that Preload only affects the first model in the
+// query and it's ignored by Iter.
+//
+// Drivers which don't support OR queries (like datastore), since
+// they don't support IN either, perform one query per distinct key.
+func (q *Query) Preload(models ...string) *Query {
+	q.preload = append(q.preload, models...)
+	return q
+}
+
+// preloadValue loads the related objects for the object
+// pointed by out.
+func (q *Query) preloadValue(out interface{}) error {
+	val := reflect.ValueOf(out)
+	for val.Kind() == reflect.Ptr && val.Elem().Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	return q.preloadObjects([]reflect.Value{val})
+}
+
+// preloadSlice loads the related objects for the
+// objects in the given slice.
+func (q *Query) preloadSlice(slice reflect.Value) error {
+	objs := make([]reflect.Value, slice.Len())
+	for ii := range objs {
+		item := slice.Index(ii)
+		if item.Kind() != reflect.Ptr {
+			item = item.Addr()
+		}
+		objs[ii] = item
+	}
+	return q.preloadObjects(objs)
+}
+
+// preloadObjects loads the related objects for the given
+// objects, which must be non-nil pointers to the query model.
+func (q *Query) preloadObjects(objs []reflect.Value) error {
+	if len(objs) == 0 || q.model == nil {
+		return nil
+	}
+	for _, v := range q.preload {
+		rel, err := q.model.model.preloadRelation(v)
+		if err != nil {
+			return err
+		}
+		if err := q.orm.preload(q.model.model, rel, objs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preloadRelation returns the relation between m and the model
+// with the given name used by Query.Preload.
+func (m *model) preloadRelation(name string) (*preloadRelation, error) {
+	related := m.namedReferences[name]
+	if related == nil {
+		return nil, fmt.Errorf("model %s has no references to or from model %q", m.name, name)
+	}
+	rtyp := related.Type()
+	var many, one []int
+	typ := m.Type()
+	for ii := 0; ii < typ.NumField(); ii++ {
+		ft := typ.Field(ii).Type
+		switch {
+		case ft.Kind() == reflect.Slice && (ft.Elem() == rtyp || ft.Elem() == reflect.PtrTo(rtyp)):
+			if many != nil {
+				return nil, fmt.Errorf("model %s has multiple fields of type %v", m.name, ft)
+			}
+			many = []int{ii}
+		case ft == reflect.PtrTo(rtyp):
+			if one != nil {
+				return nil, fmt.Errorf("model %s has multiple fields of type %v", m.name, ft)
+			}
+			one = []int{ii}
+		}
+	}
+	if many != nil {
+		// related references m
+		rel, err := referenceBetween(related, m)
+		if err != nil {
+			return nil, err
+		}
+		if rel != nil {
+			rel.model = related
+			rel.key, rel.relatedKey = rel.relatedKey, rel.key
+			rel.field = many
+			rel.many = true
+			return rel, nil
+		}
+	}
+	if one != nil {
+		// m references related
+		rel, err := referenceBetween(m, related)
+		if err != nil {
+			return nil, err
+		}
+		if rel != nil {
+			rel.model = related
+			rel.field = one
+			return rel, nil
+		}
+	}
+	return nil, fmt.Errorf("model %s has no field to store the related %s objects (must be of type []%v, []*%v or *%v)",
+		m.name, related.name, rtyp, rtyp, rtyp)
+}
+
+// referenceBetween returns the relation where from references to. The
+// key is the index of the referencing field in from, while relatedKey
+// is the index of the referenced field in to. If there's no such reference,
+// it returns nil.
+func referenceBetween(from *model, to *model) (*preloadRelation, error) {
+	var rel *preloadRelation
+	for k, v := range from.fields.References {
+		if v.Model != driver.Model(to) {
+			continue
+		}
+		if rel != nil {
+			return nil, fmt.Errorf("model %s references model %s multiple times, can't preload", from.name, to.name)
+		}
+		rel = &preloadRelation{
+			key:        from.fields.QNameMap[k],
+			relatedKey: to.fields.QNameMap[v.Field],
+		}
+	}
+	return rel, nil
+}
+
+// preload loads the objects related to objs (which are of model m)
+// using the given relation.
+func (o *Orm) preload(m *model, rel *preloadRelation, objs []reflect.Value) error {
+	if profile.On && profile.Profiling() {
+		defer profile.Start(orm).Note("preload", rel.model.name).End()
+	}
+	parents := make(map[interface{}][]reflect.Value)
+	var keys []interface{}
+	for _, v := range objs {
+		val := v.Elem()
+		target := val.FieldByIndex(rel.field)
+		target.Set(reflect.Zero(target.Type()))
+		kv := o.fieldByIndex(val, m.fields.Indexes[rel.key])
+		if !kv.IsValid() || (!rel.many && driver.IsZero(kv)) {
+			// No related object
+			continue
+		}
+		k := kv.Interface()
+		if _, ok := parents[k]; !ok {
+			keys = append(keys, k)
+		}
+		parents[k] = append(parents[k], val)
+	}
+	related := rel.model
+	table := &Table{model: &joinModel{model: related}}
+	keyName := related.fields.QNames[rel.relatedKey]
+	load := func(q query.Q) error {
+		qu := o.Table(table).Filter(q)
+		if pk := related.fields.PrimaryKey; pk >= 0 {
+			qu.Sort(related.fields.QNames[pk], ASC)
+		}
+		iter := qu.Iter()
+		for {
+			item := reflect.New(related.Type())
+			if !iter.Next(item.Interface()) {
+				break
+			}
+			kv := o.fieldByIndex(item.Elem(), related.fields.Indexes[rel.relatedKey])
+			for _, p := range parents[kv.Interface()] {
+				target := p.FieldByIndex(rel.field)
+				if !rel.many {
+					target.Set(item)
+					continue
+				}
+				if target.Type().Elem().Kind() == reflect.Ptr {
+					target.Set(reflect.Append(target, item))
+				} else {
+					target.Set(reflect.Append(target, item.Elem()))
+				}
+			}
+		}
+		return iter.Err()
+	}
+	if o.driver.Capabilities()&driver.CAP_OR == 0 {
+		for _, v := range keys {
+			if err := load(Eq(keyName, v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > preloadChunkSize {
+			n = preloadChunkSize
+		}
+		if err := load(In(keyName, keys[:n])); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
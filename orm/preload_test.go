@@ -0,0 +1,109 @@
+package orm
+
+import (
+	"testing"
+)
+
+type PreloadUser struct {
+	Id   int64 `orm:",primary_key,auto_increment"`
+	Name string
+}
+
+type PreloadArticle struct {
+	Id       int64 `orm:",primary_key,auto_increment"`
+	Title    string
+	AuthorId int64             `orm:",references=PreloadUser"`
+	Author   *PreloadUser      `orm:"-"`
+	Comments []*PreloadComment `orm:"-"`
+	Tags     []PreloadTag      `orm:"-"`
+}
+
+type PreloadComment struct {
+	Id        int64 `orm:",primary_key,auto_increment"`
+	ArticleId int64 `orm:",references=PreloadArticle"`
+	Body      string
+}
+
+type PreloadTag struct {
+	Id        int64 `orm:",primary_key,auto_increment"`
+	ArticleId int64 `orm:",references=PreloadArticle"`
+	Name      string
+}
+
+func testPreload(t *testing.T, o *Orm) {
+	o.mustRegister((*PreloadUser)(nil), &Options{
+		Table: "test_preload_users",
+	})
+	articles := o.mustRegister((*PreloadArticle)(nil), &Options{
+		Table: "test_preload_articles",
+	})
+	o.mustRegister((*PreloadComment)(nil), &Options{
+		Table: "test_preload_comments",
+	})
+	o.mustRegister((*PreloadTag)(nil), &Options{
+		Table: "test_preload_tags",
+	})
+	o.mustInitialize()
+	alice := &PreloadUser{Name: "Alice"}
+	bob := &PreloadUser{Name: "Bob"}
+	o.MustInsertAll([]*PreloadUser{alice, bob})
+	a1 := &PreloadArticle{Title: "First", AuthorId: alice.Id}
+	a2 := &PreloadArticle{Title: "Second", AuthorId: bob.Id}
+	a3 := &PreloadArticle{Title: "Third", AuthorId: alice.Id}
+	o.MustInsertAll([]*PreloadArticle{a1, a2, a3})
+	o.MustInsertAll([]*PreloadComment{
+		{ArticleId: a1.Id, Body: "1.1"},
+		{ArticleId: a2.Id, Body: "2.1"},
+		{ArticleId: a1.Id, Body: "1.2"},
+	})
+	o.MustInsertAll([]*PreloadTag{{ArticleId: a3.Id, Name: "go"}})
+	var loaded []*PreloadArticle
+	if err := o.Table(articles).Sort("Id", ASC).Preload("PreloadComment", "PreloadUser", "PreloadTag").All(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 3 {
+		t.Fatalf("expecting 3 articles, got %d", len(loaded))
+	}
+	expectComments := func(a *PreloadArticle, bodies ...string) {
+		if len(a.Comments) != len(bodies) {
+			t.Errorf("expecting %d comments in article %q, got %d", len(bodies), a.Title, len(a.Comments))
+			return
+		}
+		for ii, v := range bodies {
+			if a.Comments[ii].Body != v {
+				t.Errorf("expecting comment %q in article %q, got %q", v, a.Title, a.Comments[ii].Body)
+			}
+		}
+	}
+	expectComments(loaded[0], "1.1", "1.2")
+	expectComments(loaded[1], "2.1")
+	expectComments(loaded[2])
+	for ii, v := range []string{"Alice", "Bob", "Alice"} {
+		if loaded[ii].Author == nil || loaded[ii].Author.Name != v {
+			t.Errorf("expecting author %q in article %q, got %+v", v, loaded[ii].Title, loaded[ii].Author)
+		}
+	}
+	if len(loaded[2].Tags) != 1 || loaded[2].Tags[0].Name != "go" || len(loaded[0].Tags) != 0 {
+		t.Errorf("unexpected tags %v and %v", loaded[0].Tags, loaded[2].Tags)
+	}
+	// Preloading from the other side of the relation
+	var comment PreloadComment
+	if _, err := o.Query(Eq("PreloadComment.Body", "2.1")).Preload("PreloadArticle").One(&comment); err == nil {
+		t.Error("expecting an error when preloading without a field for the related objects")
+	}
+	var article PreloadArticle
+	if _, err := o.Query(Eq("PreloadArticle.Id", a1.Id)).Preload("PreloadComment").One(&article); err != nil {
+		t.Fatal(err)
+	}
+	expectComments(&article, "1.1", "1.2")
+	if _, err := o.Table(articles).Preload("Unknown").One(&article); err == nil {
+		t.Error("expecting an error when preloading an unrelated model")
+	}
+	// Clones must not share the preloaded models
+	q := o.Table(articles).Preload("PreloadComment").Preload("PreloadUser").Preload("PreloadTag")
+	c1 := q.Clone().Preload("Unknown")
+	q.Clone().Preload("PreloadComment")
+	if last := c1.preload[len(c1.preload)-1]; last != "Unknown" {
+		t.Errorf("expecting Unknown as the last preloaded model in the clone, got %s", last)
+	}
+}
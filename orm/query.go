@@ -14,15 +14,23 @@ type Query struct {
 	methods []*driver.Methods
 	jtype   JoinType
 	q       query.Q
+	fields  []string
 	sort    []driver.Sort
+	groupBy []string
+	having  query.Q
+	deleted deletedFilter
+	preload []string
+	cursor  Cursor
 	limit   int
 	offset  int
 	err     error
+
+	cursorBefore bool
 }
 
 func (q *Query) ensureTable(f string) error {
 	if q.model == nil {
-		fmt.Errorf("no table selected, set one with Table() before calling %s()", f)
+		return fmt.Errorf("no table selected, set one with Table() before calling %s()", f)
 	}
 	return nil
 }
@@ -64,6 +72,19 @@ func (q *Query) Filter(qu query.Q) *Query {
 	return q
 }
 
+// Fields restricts the fields loaded by the query to the given
+// ones. Fields which are not selected are left untouched in the
+// objects passed to One, All or Iter.Next. Field names might be
+// qualified with the type name when the query joins several models
+// (e.g. Fields("Article|Id", "Article|Title") or, equivalently,
+// Fields("Article.Id", "Article.Title")). Calling Fields multiple
+// times adds more fields. If Fields is never called, all the fields
+// are loaded.
+func (q *Query) Fields(fields ...string) *Query {
+	q.fields = append(q.fields, fields...)
+	return q
+}
+
 // Limit sets the maximum number of results
 // for the query.
 func (q *Query) Limit(limit int) *Query {
@@ -96,6 +117,11 @@ func (q *Query) One(out ...interface{}) (bool, error) {
 		// Must close the iter manually, because we're not
 		// reaching the end.
 		iter.Close()
+		if len(q.preload) > 0 && len(out) > 0 {
+			if err := q.preloadValue(out[0]); err != nil {
+				return false, err
+			}
+		}
 		return true, nil
 	}
 	if err := iter.Err(); err != nil {
@@ -122,7 +148,7 @@ func (q *Query) Exists() (bool, error) {
 	if profile.On && profile.Profiling() {
 		defer profile.Start(orm).Note("exists", q.model.String()).End()
 	}
-	return q.orm.driver.Exists(q.model, q.q)
+	return q.orm.conn.Exists(q.model, q.condition())
 }
 
 // Iter returns an Iter object which lets you
@@ -167,13 +193,34 @@ func (q *Query) All(out ...interface{}) error {
 		result[ii] = reflect.New(elem.Elem()).Interface()
 		values[ii] = val.Elem()
 	}
+	var start int
+	if len(values) > 0 {
+		start = values[0].Len()
+	}
 	iter := q.Iter()
 	for iter.Next(result...) {
 		for ii, v := range values {
 			v.Set(reflect.Append(v, reflect.ValueOf(result[ii]).Elem()))
 		}
 	}
-	return iter.Err()
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if q.cursor != "" && q.cursorBefore {
+		// Results were retrieved in reverse order
+		for _, v := range values {
+			for ii, jj := start, v.Len()-1; ii < jj; ii, jj = ii+1, jj-1 {
+				tmp := reflect.ValueOf(v.Index(ii).Interface())
+				v.Index(ii).Set(v.Index(jj))
+				v.Index(jj).Set(tmp)
+			}
+		}
+	}
+	if len(q.preload) > 0 && len(values) > 0 {
+		// Only preload the objects loaded by this query
+		return q.preloadSlice(values[0].Slice(start, values[0].Len()))
+	}
+	return nil
 }
 
 // MustAll works like All, but panics if there's an error.
@@ -193,7 +240,7 @@ func (q *Query) Count() (uint64, error) {
 	if profile.On && profile.Profiling() {
 		defer profile.Start(orm).Note("count", q.model.String()).End()
 	}
-	return q.orm.driver.Count(q.model, q.q, q.limit, q.offset)
+	return q.orm.conn.Count(q.model, q.condition(), q.limit, q.offset)
 }
 
 // MustCount works like Count, but panics if there's an error.
@@ -208,13 +255,21 @@ func (q *Query) MustCount() uint64 {
 // Clone returns a copy of the query.
 func (q *Query) Clone() *Query {
 	return &Query{
-		orm:    q.orm,
-		model:  q.model,
-		q:      q.q,
-		sort:   q.sort,
-		limit:  q.limit,
-		offset: q.offset,
-		err:    q.err,
+		orm:     q.orm,
+		model:   q.model,
+		q:       q.q,
+		fields:  q.fields,
+		sort:    q.sort,
+		groupBy: q.groupBy,
+		having:  q.having,
+		deleted: q.deleted,
+		preload: append([]string(nil), q.preload...),
+		cursor:  q.cursor,
+		limit:   q.limit,
+		offset:  q.offset,
+		err:     q.err,
+
+		cursorBefore: q.cursorBefore,
 	}
 }
 
@@ -226,11 +281,22 @@ func (q *Query) iter(limit int) *Iter {
 	}
 }
 
-func (q *Query) exec(limit int) driver.Iter {
+func (q *Query) exec(limit int) (driver.Iter, error) {
 	if profile.On && profile.Profiling() {
 		defer profile.Start(orm).Note("query", q.model.String()).End()
 	}
-	return q.orm.conn.Query(q.model, q.q, q.sort, limit, q.offset)
+	cond := q.condition()
+	if q.cursor != "" {
+		c, err := q.cursorCondition()
+		if err != nil {
+			return nil, err
+		}
+		if cond != nil {
+			c = And(cond, c)
+		}
+		cond = c
+	}
+	return q.orm.conn.Query(q.model, cond, q.fields, q.cursorSort(), limit, q.offset), nil
 }
 
 // Field is a conveniency function which returns a reference to a field
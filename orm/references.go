@@ -0,0 +1,170 @@
+package orm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gnd.la/orm/driver"
+	"gnd.la/orm/query"
+)
+
+// modelReference represents a reference from a field
+// in model to another model.
+type modelReference struct {
+	model *model
+	// qualified name of the referencing field
+	field string
+	ref   *driver.Reference
+}
+
+// referencesTo returns the references to m which have
+// an ON DELETE action, sorted by model and field name.
+func (o *Orm) referencesTo(m *model) []*modelReference {
+	var refs []*modelReference
+	for _, v := range o.typeRegistry {
+		for _, name := range v.fields.QNames {
+			ref := v.fields.References[name]
+			if ref == nil || ref.Model != driver.Model(m) || ref.OnDelete == driver.NoAction {
+				continue
+			}
+			refs = append(refs, &modelReference{model: v, field: name, ref: ref})
+		}
+	}
+	sort.Sort(sortModelReferences(refs))
+	return refs
+}
+
+type sortModelReferences []*modelReference
+
+func (s sortModelReferences) Len() int {
+	return len(s)
+}
+
+func (s sortModelReferences) Less(i, j int) bool {
+	if s[i].model.name != s[j].model.name {
+		return s[i].model.name < s[j].model.name
+	}
+	return s[i].field < s[j].field
+}
+
+func (s sortModelReferences) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// referencedValues returns the distinct values of the given
+// field in the objects of m which match q.
+func (o *Orm) referencedValues(m *model, field string, q query.Q) ([]interface{}, error) {
+	idx, ok := m.fields.QNameMap[field]
+	if !ok {
+		return nil, errCantMap(field)
+	}
+	table := &Table{model: &joinModel{model: m}}
+	seen := make(map[interface{}]bool)
+	var values []interface{}
+	iter := o.Table(table).Filter(q).WithDeleted().Iter()
+	for {
+		obj := reflect.New(m.Type())
+		if !iter.Next(obj.Interface()) {
+			break
+		}
+		val := o.fieldByIndex(obj.Elem(), m.fields.Indexes[idx])
+		if !val.IsValid() || driver.IsZero(val) {
+			continue
+		}
+		v := val.Interface()
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	return values, iter.Err()
+}
+
+// emulateOnDelete performs the ON DELETE actions of the references to
+// the objects of m matching q, which are about to be deleted. It's only
+// used with drivers without CAP_FOREIGN_KEY. Note that, unlike foreign
+// keys, the emulation is only atomic when running in a transaction.
+func (o *Orm) emulateOnDelete(m *model, q query.Q) error {
+	refs := o.referencesTo(m)
+	if len(refs) == 0 {
+		return nil
+	}
+	keys := make([][]interface{}, len(refs))
+	// Check all the restrictions before altering any object
+	for ii, v := range refs {
+		values, err := o.referencedValues(m, v.ref.Field, q)
+		if err != nil {
+			return err
+		}
+		keys[ii] = values
+		if v.ref.OnDelete != driver.Restrict {
+			continue
+		}
+		table := &Table{model: &joinModel{model: v.model}}
+		for _, k := range values {
+			exists, err := o.Table(table).Filter(Eq(v.field, k)).WithDeleted().Exists()
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("can't delete %s with %s = %v, it's referenced by %s", m.name, v.ref.Field, k, v.model.name)
+			}
+		}
+	}
+	for ii, v := range refs {
+		for _, k := range keys[ii] {
+			var err error
+			switch v.ref.OnDelete {
+			case driver.Cascade:
+				_, err = o.delete(v.model, Eq(v.field, k), true)
+			case driver.SetNull:
+				err = o.setNullReferences(v, k)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setNullReferences sets the field of the given reference to NULL
+// in all the objects which reference the given key. Objects are
+// updated one by one using Update, so hooks and the updated field
+// work as usual, but only the reference field is written.
+func (o *Orm) setNullReferences(r *modelReference, key interface{}) error {
+	m := r.model
+	pk := m.fields.PrimaryKey
+	if pk < 0 {
+		return fmt.Errorf("can't emulate on_delete=set_null on model %s without a primary key", m.name)
+	}
+	idx := m.fields.QNameMap[r.field]
+	table := &Table{model: &joinModel{model: m}}
+	var objs []reflect.Value
+	iter := o.Table(table).Filter(Eq(r.field, key)).WithDeleted().Iter()
+	for {
+		obj := reflect.New(m.Type())
+		if !iter.Next(obj.Interface()) {
+			break
+		}
+		objs = append(objs, obj)
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	// Reference fields with set_null are always nullempty, so
+	// the driver writes the zero value as NULL.
+	fields := []string{m.fullName(r.field)}
+	for _, v := range objs {
+		field := o.fieldByIndex(v.Elem(), m.fields.Indexes[idx])
+		if field.IsValid() {
+			field.Set(reflect.Zero(field.Type()))
+		}
+		pkVal := o.fieldByIndex(v.Elem(), m.fields.Indexes[pk])
+		if _, err := o.update(m, Eq(m.fields.QNames[pk], pkVal.Interface()), v.Interface(), fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
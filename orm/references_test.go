@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"gnd.la/orm/driver"
+	"gnd.la/orm/driver/sql"
 )
 
 type BadEvent struct {
@@ -226,3 +227,143 @@ func testReferences(t *testing.T, o *Orm) {
 		t.Error("expecting an error when violating FK")
 	}
 }
+
+type OnDeleteParent struct {
+	Id int64 `orm:",primary_key,auto_increment"`
+}
+
+type OnDeleteCascade struct {
+	Id       int64 `orm:",primary_key,auto_increment"`
+	ParentId int64 `orm:",references=OnDeleteParent,on_delete=cascade"`
+}
+
+type OnDeleteSetNull struct {
+	Id       int64     `orm:",primary_key,auto_increment"`
+	ParentId int64     `orm:",references=OnDeleteParent,on_delete=set_null"`
+	Updated  time.Time `orm:",updated"`
+}
+
+var onDeleteSetNullUpdates int
+
+func (n *OnDeleteSetNull) BeforeUpdate(o *Orm) error {
+	onDeleteSetNullUpdates++
+	return nil
+}
+
+type BadSetNull struct {
+	Id       int64 `orm:",primary_key,auto_increment"`
+	ParentId int64 `orm:",references=OnDeleteParent,on_delete=set_null,notnullempty"`
+}
+
+type OnDeleteRestrict struct {
+	Id       int64 `orm:",primary_key,auto_increment"`
+	ParentId int64 `orm:",references=OnDeleteParent,on_delete=restrict"`
+}
+
+type BadOnDelete struct {
+	Id       int64 `orm:",primary_key,auto_increment"`
+	ParentId int64 `orm:",references=OnDeleteParent,on_delete=explode"`
+}
+
+func testOnDelete(t *testing.T, o *Orm) {
+	if _, err := o.Register((*BadOnDelete)(nil), &Options{Table: "test_bad_on_delete"}); err == nil {
+		t.Error("expecting an error when registering a model with an invalid on_delete")
+	}
+	if _, err := o.Register((*BadSetNull)(nil), &Options{Table: "test_bad_set_null"}); err == nil {
+		t.Error("expecting an error when registering a set_null reference which is not nullempty")
+	}
+	parents := o.mustRegister((*OnDeleteParent)(nil), &Options{
+		Table: "test_on_delete_parents",
+	})
+	cascade := o.mustRegister((*OnDeleteCascade)(nil), &Options{
+		Table: "test_on_delete_cascade",
+	})
+	setNull := o.mustRegister((*OnDeleteSetNull)(nil), &Options{
+		Table: "test_on_delete_set_null",
+	})
+	o.mustRegister((*OnDeleteRestrict)(nil), &Options{
+		Table: "test_on_delete_restrict",
+	})
+	o.mustInitialize()
+	if drv, ok := o.Driver().(*sql.Driver); ok {
+		db := drv.DB()
+		tbl, err := db.Backend().Inspect(db, cascade.model.model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ref *sql.Constraint
+		for _, v := range tbl.Fields {
+			if c := v.Constraint(sql.ConstraintForeignKey); c != nil {
+				ref = c
+			}
+		}
+		if ref == nil || ref.OnDelete != driver.Cascade {
+			t.Errorf("expecting FK with ON DELETE CASCADE, got %v", ref)
+		}
+	}
+	p1, p2, p3 := &OnDeleteParent{}, &OnDeleteParent{}, &OnDeleteParent{}
+	o.MustInsertAll([]*OnDeleteParent{p1, p2, p3})
+	c := &OnDeleteCascade{ParentId: p1.Id}
+	o.MustInsert(c)
+	n := &OnDeleteSetNull{ParentId: p2.Id}
+	o.MustInsert(n)
+	o.MustInsert(&OnDeleteRestrict{ParentId: p3.Id})
+	o.MustDelete(p1)
+	if e, err := o.Exists(cascade, Eq("Id", c.Id)); err != nil || e {
+		t.Errorf("expecting cascaded object to be deleted (error %v)", err)
+	}
+	o.MustDelete(p2)
+	var loaded OnDeleteSetNull
+	if _, err := o.Query(Eq("OnDeleteSetNull.Id", n.Id)).One(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ParentId != 0 {
+		t.Errorf("expecting reference set to null, got %d", loaded.ParentId)
+	}
+	if err := o.Delete(p3); err == nil {
+		t.Error("expecting an error when deleting a restricted reference")
+	}
+	if e, err := o.Exists(parents, Eq("Id", p3.Id)); err != nil || !e {
+		t.Errorf("expecting restricted object to exist (error %v)", err)
+	}
+	// Test the emulation used by drivers without foreign keys,
+	// which must run before the referenced objects are deleted.
+	p4 := &OnDeleteParent{}
+	o.MustInsert(p4)
+	c = &OnDeleteCascade{ParentId: p4.Id}
+	o.MustInsert(c)
+	n = &OnDeleteSetNull{ParentId: p4.Id}
+	o.MustInsert(n)
+	// Store an old timestamp bypassing Update, to check that
+	// the emulation sets the updated field.
+	n.Updated = time.Unix(0, 0).UTC()
+	if _, err := o.conn.Update(setNull.model.model, Eq("Id", n.Id), n, nil); err != nil {
+		t.Fatal(err)
+	}
+	updates := onDeleteSetNullUpdates
+	if err := o.emulateOnDelete(parents.model.model, Eq("Id", p3.Id)); err == nil {
+		t.Error("expecting an error when emulating a restricted reference")
+	}
+	if err := o.emulateOnDelete(parents.model.model, Eq("Id", p4.Id)); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := o.Exists(cascade, Eq("Id", c.Id)); err != nil || e {
+		t.Errorf("expecting emulated cascade to delete object (error %v)", err)
+	}
+	if e, err := o.Exists(setNull, Eq("ParentId", p4.Id)); err != nil || e {
+		t.Errorf("expecting emulated set_null to clear reference (error %v)", err)
+	}
+	if e, err := o.Exists(setNull, And(Eq("Id", n.Id), Eq("ParentId", nil))); err != nil || !e {
+		t.Errorf("expecting emulated set_null to store NULL (error %v)", err)
+	}
+	var after OnDeleteSetNull
+	if _, err := o.Query(Eq("OnDeleteSetNull.Id", n.Id)).One(&after); err != nil {
+		t.Fatal(err)
+	}
+	if !after.Updated.After(n.Updated) {
+		t.Errorf("expecting emulated set_null to set the updated field, got %v", after.Updated)
+	}
+	if onDeleteSetNullUpdates != updates+1 {
+		t.Errorf("expecting emulated set_null to run 1 BeforeUpdate hook, ran %d", onDeleteSetNullUpdates-updates)
+	}
+}
@@ -232,8 +232,9 @@ func (o *Orm) Initialize() error {
 						r.field, referenced.name, fkt, k, v.name, ft)
 				}
 				v.fields.References[k] = &driver.Reference{
-					Model: referenced,
-					Field: r.field,
+					Model:    referenced,
+					Field:    r.field,
+					OnDelete: r.onDelete,
 				}
 				if v.modelReferences == nil {
 					v.modelReferences = make(map[*model][]*join)
@@ -277,9 +278,15 @@ func (o *Orm) fields(table string, s *structs.Struct) (*driver.Fields, map[strin
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := checkHooks(s.Type); err != nil {
+		return nil, nil, err
+	}
 	fields := &driver.Fields{
 		Struct:     s,
 		PrimaryKey: -1,
+		Created:    -1,
+		Updated:    -1,
+		SoftDelete: -1,
 		Methods:    methods,
 	}
 	var references map[string]*reference
@@ -315,7 +322,7 @@ func (o *Orm) fields(table string, s *structs.Struct) (*driver.Fields, map[strin
 		// to determine if it should be nullempty or omitempty by default
 		field := s.Type.FieldByIndex(s.Indexes[ii])
 		fields.OmitEmpty = append(fields.OmitEmpty, ftag.Has("omitempty") || (defaultsToOmitEmpty(field.Type, ftag) && !ftag.Has("notomitempty")))
-		fields.NullEmpty = append(fields.NullEmpty, ftag.Has("nullempty") || (defaultsToNullEmpty(field.Type, ftag) && !ftag.Has("notnullempty")))
+		fields.NullEmpty = append(fields.NullEmpty, ftag.Has("nullempty") || ftag.Has("soft_delete") || (defaultsToNullEmpty(field.Type, ftag) && !ftag.Has("notnullempty")))
 		if ftag.Has("primary_key") {
 			if fields.PrimaryKey >= 0 {
 				return nil, nil, fmt.Errorf("duplicate primary_key in struct %v (%s and %s)", s.Type, s.QNames[fields.PrimaryKey], v)
@@ -328,6 +335,28 @@ func (o *Orm) fields(table string, s *structs.Struct) (*driver.Fields, map[strin
 			}
 			fields.AutoincrementPk = fields.PrimaryKey == ii
 		}
+		for _, tf := range []struct {
+			name string
+			idx  *int
+		}{
+			{"created", &fields.Created},
+			{"updated", &fields.Updated},
+			{"soft_delete", &fields.SoftDelete},
+		} {
+			if !ftag.Has(tf.name) {
+				continue
+			}
+			if *tf.idx >= 0 {
+				return nil, nil, fmt.Errorf("duplicate %s in struct %v (%s and %s)", tf.name, s.Type, s.QNames[*tf.idx], v)
+			}
+			if t != timeType && (tf.name != "soft_delete" || t.Kind() != reflect.Bool) {
+				if tf.name == "soft_delete" {
+					return nil, nil, fmt.Errorf("soft_delete field %q in struct %s must be of type bool or time.Time", v, s.Type)
+				}
+				return nil, nil, fmt.Errorf("%s field %q in struct %s must be of type time.Time", tf.name, v, s.Type)
+			}
+			*tf.idx = ii
+		}
 		if ref := ftag.Value("references"); ref != "" {
 			m := referencesRe.FindStringSubmatch(ref)
 			if len(m) != 4 {
@@ -336,7 +365,19 @@ func (o *Orm) fields(table string, s *structs.Struct) (*driver.Fields, map[strin
 			if references == nil {
 				references = make(map[string]*reference)
 			}
-			references[v] = &reference{model: m[1], field: m[3]}
+			onDelete, err := referenceAction(ftag.Value("on_delete"))
+			if err != nil {
+				return nil, nil, fmt.Errorf("field %q has invalid on_delete: %s", v, err)
+			}
+			if onDelete == driver.SetNull && ftag.Has("notnull") {
+				return nil, nil, fmt.Errorf("field %q has on_delete=set_null but it's declared as notnull", v)
+			}
+			if onDelete == driver.SetNull && !fields.NullEmpty[len(fields.NullEmpty)-1] {
+				return nil, nil, fmt.Errorf("field %q has on_delete=set_null but it's not nullempty", v)
+			}
+			references[v] = &reference{model: m[1], field: m[3], onDelete: onDelete}
+		} else if ftag.Has("on_delete") {
+			return nil, nil, fmt.Errorf("field %q has on_delete but no references", v)
 		}
 	}
 	if err := o.setFieldsDefaults(fields); err != nil {
@@ -345,6 +386,21 @@ func (o *Orm) fields(table string, s *structs.Struct) (*driver.Fields, map[strin
 	return fields, references, nil
 }
 
+// referenceAction parses the value of the on_delete tag.
+func referenceAction(s string) (driver.ReferenceAction, error) {
+	switch s {
+	case "", "no_action":
+		return driver.NoAction, nil
+	case "cascade":
+		return driver.Cascade, nil
+	case "set_null":
+		return driver.SetNull, nil
+	case "restrict":
+		return driver.Restrict, nil
+	}
+	return driver.NoAction, fmt.Errorf("unknown action %q, must be cascade, set_null, restrict or no_action", s)
+}
+
 func (o *Orm) setFieldsDefaults(f *driver.Fields) error {
 	defaults := make(map[int]reflect.Value)
 	for ii, v := range f.Tags {
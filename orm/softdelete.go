@@ -0,0 +1,160 @@
+package orm
+
+import (
+	"reflect"
+	"time"
+
+	"gnd.la/orm/driver"
+	"gnd.la/orm/query"
+)
+
+type deletedFilter int
+
+const (
+	excludeDeleted deletedFilter = iota
+	withDeleted
+	onlyDeleted
+)
+
+// WithDeleted makes the query include the objects which
+// have been soft deleted. By default, queries on models
+// with a field tagged with soft_delete exclude them. e.g.
+//
+//  type Post struct {
+//	Id        int64     `orm:",primary_key,auto_increment"`
+//	Created   time.Time `orm:",created"`
+//	Updated   time.Time `orm:",updated"`
+//	DeletedAt time.Time `orm:",soft_delete"`
+//  }
+//
+// Fields tagged with created are set to the current time
+// when the object is inserted (unless they're already set),
+// while fields tagged with updated are set to the current
+// time every time the object is inserted or updated. Both
+// must be of type time.Time. Fields tagged with soft_delete
+// might be of type bool or time.Time and they're set to true
+// or the current time when the object is deleted, instead of
+// removing it from the database (see Orm.HardDelete to remove
+// soft deleted objects).
+func (q *Query) WithDeleted() *Query {
+	q.deleted = withDeleted
+	return q
+}
+
+// OnlyDeleted makes the query return only the objects which
+// have been soft deleted. See WithDeleted for more information.
+// Note that models joined in the query still exclude the deleted
+// objects.
+func (q *Query) OnlyDeleted() *Query {
+	q.deleted = onlyDeleted
+	return q
+}
+
+// condition returns the query condition, including the
+// conditions required for excluding soft deleted objects.
+func (q *Query) condition() query.Q {
+	if q.deleted == withDeleted || q.model == nil {
+		return q.q
+	}
+	var conditions []query.Q
+	if q.q != nil {
+		conditions = append(conditions, q.q)
+	}
+	if c := softDeleteCondition(q.model.model, q.deleted == onlyDeleted); c != nil {
+		conditions = append(conditions, c)
+	}
+	for cur := q.model.join; cur != nil; cur = cur.model.join {
+		if c := softDeleteCondition(cur.model.model, false); c != nil {
+			conditions = append(conditions, c)
+		}
+	}
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0]
+	}
+	return And(conditions...)
+}
+
+// softDeleteCondition returns the condition which matches the deleted
+// objects of the given model (or the non-deleted ones if deleted is false).
+// If the model doesn't use soft deletion, it returns nil.
+func softDeleteCondition(m *model, deleted bool) query.Q {
+	idx := m.fields.SoftDelete
+	if idx < 0 {
+		return nil
+	}
+	name := m.fullName(m.fields.QNames[idx])
+	if m.fields.Types[idx].Kind() == reflect.Bool {
+		if deleted {
+			return Eq(name, true)
+		}
+		// Rows inserted before adding the field might be NULL
+		return Or(Eq(name, false), Eq(name, nil))
+	}
+	if deleted {
+		return Neq(name, nil)
+	}
+	return Eq(name, nil)
+}
+
+// setTime sets the field at idx in obj to t, copying obj if the
+// field can't be set. If onlyZero is true, the field is only set
+// when it's zero. It returns the object with the field set.
+func (o *Orm) setTime(f *driver.Fields, obj interface{}, idx int, t time.Time, onlyZero bool) interface{} {
+	val := reflect.ValueOf(obj)
+	fval := o.fieldByIndexCreating(val, f.Indexes[idx])
+	if onlyZero && !driver.IsZero(fval) {
+		return obj
+	}
+	if !fval.CanSet() {
+		pval := reflect.New(val.Type())
+		pval.Elem().Set(val)
+		obj = pval.Interface()
+		fval = o.fieldByIndexCreating(pval, f.Indexes[idx])
+	}
+	setTimeValue(fval, t)
+	return obj
+}
+
+func setTimeValue(fval reflect.Value, t time.Time) {
+	switch fval.Kind() {
+	case reflect.Bool:
+		fval.SetBool(true)
+	default:
+		fval.Set(reflect.ValueOf(t))
+	}
+}
+
+// setInsertTimes sets the created and updated fields in obj
+// before inserting it.
+func (o *Orm) setInsertTimes(f *driver.Fields, obj interface{}) interface{} {
+	now := time.Now().UTC()
+	if f.Created >= 0 {
+		obj = o.setTime(f, obj, f.Created, now, true)
+	}
+	if f.Updated >= 0 {
+		obj = o.setTime(f, obj, f.Updated, now, false)
+	}
+	return obj
+}
+
+// softDelete marks the objects matching q as deleted.
+func (o *Orm) softDelete(m *model, q query.Q) (Result, error) {
+	f := m.fields
+	now := time.Now().UTC()
+	obj := reflect.New(f.Type)
+	setTimeValue(o.fieldByIndexCreating(obj, f.Indexes[f.SoftDelete]), now)
+	fields := []string{m.fullName(f.QNames[f.SoftDelete])}
+	if f.Updated >= 0 {
+		setTimeValue(o.fieldByIndexCreating(obj, f.Indexes[f.Updated]), now)
+		fields = append(fields, m.fullName(f.QNames[f.Updated]))
+	}
+	// Don't alter objects which were already deleted
+	cond := softDeleteCondition(m, false)
+	if q != nil {
+		cond = And(q, cond)
+	}
+	return o.conn.Update(m, cond, obj.Interface(), fields)
+}
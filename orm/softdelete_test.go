@@ -0,0 +1,134 @@
+package orm
+
+import (
+	"testing"
+	"time"
+)
+
+type Timestamped struct {
+	Id      int64 `orm:",primary_key,auto_increment"`
+	Value   string
+	Created time.Time `orm:",created"`
+	Updated time.Time `orm:",updated"`
+	Deleted bool      `orm:",soft_delete"`
+}
+
+type TimeDeleted struct {
+	Id        int64 `orm:",primary_key,auto_increment"`
+	Value     string
+	DeletedAt time.Time `orm:",soft_delete"`
+}
+
+type BadSoftDelete struct {
+	Id      int64 `orm:",primary_key,auto_increment"`
+	Deleted int   `orm:",soft_delete"`
+}
+
+func testTimestamps(t *testing.T, o *Orm) {
+	tbl := o.mustRegister((*Timestamped)(nil), &Options{
+		Table: "test_timestamps",
+	})
+	o.mustInitialize()
+	if _, err := o.Register((*BadSoftDelete)(nil), &Options{Table: "test_bad_soft_delete"}); err == nil {
+		t.Error("expecting an error when registering soft_delete field of type int")
+	}
+	before := time.Now().Add(-time.Second)
+	obj := &Timestamped{Value: "Foo"}
+	o.MustInsert(obj)
+	if obj.Created.Before(before) || !obj.Updated.Equal(obj.Created) {
+		t.Errorf("unexpected timestamps after insert created = %v, updated = %v", obj.Created, obj.Updated)
+	}
+	created := obj.Created
+	var loaded Timestamped
+	if _, err := o.Table(tbl).One(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Created.Unix() != created.Unix() {
+		t.Errorf("expecting created = %v, got %v", created, loaded.Created)
+	}
+	// Make sure the updated time changes
+	old := time.Now().Add(-time.Hour)
+	obj.Updated = old
+	obj.Value = "Bar"
+	o.MustSave(obj)
+	if !obj.Created.Equal(created) || !obj.Updated.After(old) {
+		t.Errorf("unexpected timestamps after update created = %v, updated = %v", obj.Created, obj.Updated)
+	}
+	// Spare capacity, which UpdateFields must not write into
+	fields := append(make([]string, 0, 2), "Value")
+	if _, err := o.UpdateFields(Eq("Id", obj.Id), &Timestamped{Value: "Baz", Updated: old}, fields...); err != nil {
+		t.Fatal(err)
+	}
+	if spare := fields[:2][1]; spare != "" {
+		t.Errorf("UpdateFields modified the fields slice, found %q", spare)
+	}
+	if _, err := o.Table(tbl).One(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Value != "Baz" || !loaded.Updated.After(old) {
+		t.Errorf("unexpected object after UpdateFields %+v", loaded)
+	}
+}
+
+func testSoftDelete(t *testing.T, o *Orm) {
+	tbl := o.mustRegister((*Timestamped)(nil), &Options{
+		Table: "test_soft_delete",
+	})
+	timeTbl := o.mustRegister((*TimeDeleted)(nil), &Options{
+		Table: "test_soft_delete_time",
+	})
+	o.mustInitialize()
+	expectCount := func(q *Query, expected uint64) {
+		if c := q.MustCount(); c != expected {
+			t.Errorf("expecting %d objects, got %d", expected, c)
+		}
+	}
+	objs := []*Timestamped{{Value: "1"}, {Value: "2"}, {Value: "3"}}
+	for _, v := range objs {
+		o.MustInsert(v)
+	}
+	o.MustDelete(objs[0])
+	if !objs[0].Deleted {
+		t.Error("deleted object was not marked as deleted")
+	}
+	expectCount(o.Table(tbl), 2)
+	expectCount(o.Table(tbl).WithDeleted(), 3)
+	expectCount(o.Table(tbl).OnlyDeleted(), 1)
+	var all []*Timestamped
+	if err := o.Query(nil).All(&all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("expecting 2 non-deleted objects, got %d", len(all))
+	}
+	if ok, _ := o.One(Eq("Id", objs[0].Id), &Timestamped{}); ok {
+		t.Error("soft deleted object was returned by One")
+	}
+	if _, err := o.DeleteFrom(tbl, Eq("Value", "2")); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(o.Table(tbl), 1)
+	expectCount(o.Table(tbl).OnlyDeleted(), 2)
+	o.MustHardDelete(objs[0])
+	expectCount(o.Table(tbl).WithDeleted(), 2)
+	if _, err := o.HardDeleteFrom(tbl, nil); err != nil {
+		t.Fatal(err)
+	}
+	expectCount(o.Table(tbl).WithDeleted(), 0)
+	// Soft deletion using a time field
+	td := &TimeDeleted{Value: "1"}
+	o.MustInsert(td)
+	o.MustInsert(&TimeDeleted{Value: "2"})
+	o.MustDelete(td)
+	if td.DeletedAt.IsZero() {
+		t.Error("deleted object was not marked as deleted")
+	}
+	expectCount(o.Table(timeTbl), 1)
+	var deleted TimeDeleted
+	if _, err := o.Table(timeTbl).OnlyDeleted().One(&deleted); err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Value != "1" || deleted.DeletedAt.IsZero() {
+		t.Errorf("unexpected deleted object %+v", deleted)
+	}
+}
@@ -18,10 +18,16 @@ type Tx struct {
 	done bool
 }
 
-// Begin just returns ErrInTransaction when called
-// from a transaction.
+// Begin starts a transaction nested into t, using a savepoint.
+// Committing the nested transaction makes its changes part of
+// t, while rolling it back only undoes the changes made since
+// it was started. If the driver does not support savepoints
+// (see driver.CAP_SAVEPOINT), it returns ErrInTransaction.
 func (t *Tx) Begin() (*Tx, error) {
-	return nil, ErrInTransaction
+	if t.done {
+		return nil, ErrFinished
+	}
+	return t.Orm.Begin()
 }
 
 // Commit commits the current transaction. If the transaction
@@ -0,0 +1,55 @@
+package orm
+
+import (
+	"testing"
+
+	"gnd.la/util/structs"
+)
+
+type ValidatedUser struct {
+	Id    int64  `orm:",primary_key,auto_increment"`
+	Email string `orm:",email"`
+	Age   int    `orm:",min=18"`
+}
+
+func testValidate(t *testing.T, o *Orm) {
+	tbl := o.mustRegister((*ValidatedUser)(nil), &Options{
+		Table:    "test_validated_users",
+		Validate: true,
+	})
+	o.mustInitialize()
+	user := &ValidatedUser{Email: "alice@example.com", Age: 30}
+	if _, err := o.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	user.Email = "alice"
+	if _, err := o.Save(user); err == nil {
+		t.Error("expecting an error when saving an invalid email")
+	} else if ferr, ok := err.(*structs.FieldError); !ok || ferr.Field != "Email" {
+		t.Errorf("expecting a *structs.FieldError for Email, got %v", err)
+	}
+	invalid := &ValidatedUser{Email: "bob@example.com", Age: 10}
+	if _, err := o.Insert(invalid); err == nil {
+		t.Error("expecting an error when inserting an invalid age")
+	}
+	if _, err := o.InsertAll([]*ValidatedUser{invalid}); err == nil {
+		t.Error("expecting an error when inserting an invalid age with InsertAll")
+	}
+	if n, err := o.Table(tbl).Count(); err != nil || n != 1 {
+		t.Errorf("expecting 1 user, got %d (error %v)", n, err)
+	}
+	// UpdateFields only validates the updated fields
+	if _, err := o.UpdateFields(Eq("Id", user.Id), &ValidatedUser{Email: "bob@example.com"}, "Email"); err != nil {
+		t.Errorf("unexpected error updating a valid email: %s", err)
+	}
+	if _, err := o.UpdateFields(Eq("Id", user.Id), &ValidatedUser{Email: "bob"}, "Email"); err == nil {
+		t.Error("expecting an error when updating an invalid email")
+	}
+	var loaded ValidatedUser
+	if _, err := o.Query(Eq("Id", user.Id)).One(&loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Email != "bob@example.com" || loaded.Age != 30 {
+		t.Errorf("expecting email bob@example.com and age 30, got %s and %d", loaded.Email, loaded.Age)
+	}
+}
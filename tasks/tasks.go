@@ -282,4 +282,19 @@ func init() {
 		onListenTasks.tasks = pending
 		onListenTasks.Unlock()
 	})
+	// Stop the scheduled tasks when their App (or the App
+	// they've been included into) shuts down.
+	signal.Listen(app.WILL_SHUTDOWN, func(_ string, obj interface{}) {
+		a := obj.(*app.App)
+		registered.RLock()
+		defer registered.RUnlock()
+		for _, v := range registered.tasks {
+			for ta := v.App; ta != nil; ta = ta.Parent() {
+				if ta == a {
+					v.Stop()
+					break
+				}
+			}
+		}
+	})
 }
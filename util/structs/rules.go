@@ -0,0 +1,531 @@
+package structs
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gnd.la/i18n"
+	"gnd.la/util/stringutil"
+)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]Rule{
+		"email":    emailRule,
+		"url":      urlRule,
+		"regexp":   regexpRule,
+		"pattern":  patternRule,
+		"min":      minRule,
+		"max":      maxRule,
+		"one_of":   oneOfRule,
+		"uuid":     uuidRule,
+		"after":    afterRule,
+		"before":   beforeRule,
+		"eq_field": eqFieldRule,
+		"ne_field": neFieldRule,
+	}
+
+	regexpsMu sync.Mutex
+	regexps   = make(map[string]*regexp.Regexp)
+
+	uuidRe      = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+	timeType    = reflect.TypeOf(time.Time{})
+	dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+)
+
+// RuleValue contains the value passed to a Rule.
+type RuleValue struct {
+	// Name is the name of the field which should be included
+	// in the error messages. It might be empty.
+	Name string
+	// Value is the value of the field.
+	Value reflect.Value
+	// Arg is the rule argument in the tag (e.g. "5" for "min=5").
+	Arg string
+	// Parent is the struct which contains the field. It's
+	// used by the rules which compare several fields.
+	Parent reflect.Value
+}
+
+// String returns the value as a string, dereferencing any pointers.
+func (v *RuleValue) String() string {
+	val := v.Value
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return ""
+		}
+		val = val.Elem()
+	}
+	if val.Kind() == reflect.String {
+		return val.String()
+	}
+	return fmt.Sprint(val.Interface())
+}
+
+// Rule is a function which validates a field. Rules are specified in
+// the field tags, using the same name they were registered with. e.g.
+// a field with the tag `form:",email"` will be validated using the email
+// rule, while `form:",min=5"` uses the min rule with the argument "5".
+// Rules should return an i18n.Error, so the error can be translated.
+type Rule func(v *RuleValue) error
+
+// RegisterRule registers a new validation rule with the given name.
+// If there's already a rule with the same name, it's overwritten.
+// The following rules are registered by default:
+//
+//  - email: The value must be an email address.
+//  - url: The value must be an absolute URL.
+//  - regexp: The value must match the regular expression in the argument.
+//  - pattern: Like regexp, but the whole value must match the regular expression,
+//    like in the HTML5 pattern attribute (gnd.la/form also emits it in the HTML).
+//  - min, max: The numeric value must be >= or <= the argument.
+//  - one_of: The value must be one of the values in the argument, separated by |.
+//  - uuid: The value must be an UUID.
+//  - after, before: The time.Time value must come after or before the date in the
+//    argument, which might be either "now" or a date using the RFC 3339 format (time
+//    and timezone might be omitted).
+//  - eq_field, ne_field: The value must be equal or different to the value in the
+//    field named in the argument, which must be in the same struct.
+//
+// Note that all the built-in rules except eq_field and ne_field ignore empty
+// values. Use the required tag to reject them.
+func RegisterRule(name string, rule Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = rule
+}
+
+// HasRule returns true iff there's a rule registered with the given name.
+func HasRule(name string) bool {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	_, ok := rules[name]
+	return ok
+}
+
+// FieldError is returned by ValidateStruct when a field doesn't
+// satisfy any of its rules.
+type FieldError struct {
+	// Field is the qualified name of the field (e.g. Foo.Bar).
+	Field string
+	// Err is the error returned by the rule.
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+// ValidateRules validates the given value using the rules in its tag.
+// The parent argument must be the struct which contains the field, while
+// name is the field name to be used in the error messages (it might be
+// empty). Rules are applied in alphabetical order and the first error is
+// returned.
+func ValidateRules(name string, value reflect.Value, parent reflect.Value, tag *Tag) error {
+	if tag == nil {
+		return nil
+	}
+	var names []string
+	rulesMu.RLock()
+	for k := range tag.values {
+		if rules[k] != nil {
+			names = append(names, k)
+		}
+	}
+	rulesMu.RUnlock()
+	sort.Strings(names)
+	for _, v := range names {
+		rulesMu.RLock()
+		rule := rules[v]
+		rulesMu.RUnlock()
+		rv := &RuleValue{
+			Name:   name,
+			Value:  value,
+			Arg:    tag.Value(v),
+			Parent: parent,
+		}
+		if err := rule(rv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateStruct validates all the fields in obj, which must be a struct
+// or a pointer to a struct, using the rules in their tags. The tags argument
+// indicates the names of the tags to look for (e.g. []string{"form"}), like
+// in NewStruct. Nested and embedded structs are also validated, unless they're
+// nil pointers. If a field doesn't satisfy its rules, a *FieldError is returned.
+func ValidateStruct(obj interface{}, tags []string) error {
+	val := reflect.ValueOf(obj)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return ErrNoStruct
+	}
+	return validateStruct(val, tags, "", nil)
+}
+
+// ValidateFields works like ValidateStruct, but only validates the fields
+// with the given qualified names (e.g. Foo.Bar). If fields is empty, all
+// the fields are validated.
+func ValidateFields(obj interface{}, tags []string, fields []string) error {
+	if len(fields) == 0 {
+		return ValidateStruct(obj, tags)
+	}
+	val := reflect.ValueOf(obj)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return ErrNoStruct
+	}
+	only := make(map[string]bool, len(fields))
+	for _, v := range fields {
+		only[v] = true
+	}
+	return validateStruct(val, tags, "", only)
+}
+
+func validateStruct(val reflect.Value, tags []string, prefix string, only map[string]bool) error {
+	typ := val.Type()
+	for ii := 0; ii < typ.NumField(); ii++ {
+		field := typ.Field(ii)
+		if field.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		tag := NewTag(field, tags)
+		if tag.Name() == "-" {
+			continue
+		}
+		fv := val.Field(ii)
+		qname := prefix + field.Name
+		inner := fv
+		for inner.Kind() == reflect.Ptr && !inner.IsNil() {
+			inner = inner.Elem()
+		}
+		if inner.Kind() == reflect.Struct && decompose(inner.Type(), tag) {
+			if err := validateStruct(inner, tags, qname+".", only); err != nil {
+				return err
+			}
+			continue
+		}
+		if only != nil && !only[qname] {
+			continue
+		}
+		name := stringutil.CamelCaseToWords(field.Name, " ")
+		if err := ValidateRules(name, fv, val, tag); err != nil {
+			return &FieldError{Field: qname, Err: err}
+		}
+	}
+	return nil
+}
+
+func isEmpty(v *RuleValue) bool {
+	val := v.Value
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return true
+		}
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return val.Len() == 0
+	case reflect.Struct:
+		if val.Type() == timeType {
+			return val.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
+
+func emailRule(v *RuleValue) error {
+	if isEmpty(v) {
+		return nil
+	}
+	s := v.String()
+	if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s must be a valid email address", v.Name)
+		}
+		return i18n.Errorfc("form", "must be a valid email address")
+	}
+	return nil
+}
+
+func urlRule(v *RuleValue) error {
+	if isEmpty(v) {
+		return nil
+	}
+	if u, err := url.Parse(v.String()); err != nil || u.Scheme == "" || u.Host == "" {
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s must be a valid URL", v.Name)
+		}
+		return i18n.Errorfc("form", "must be a valid URL")
+	}
+	return nil
+}
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	regexpsMu.Lock()
+	defer regexpsMu.Unlock()
+	if re := regexps[expr]; re != nil {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regexp %q: %s", expr, err)
+	}
+	regexps[expr] = re
+	return re, nil
+}
+
+func regexpRule(v *RuleValue) error {
+	re, err := compileRegexp(v.Arg)
+	if err != nil {
+		return err
+	}
+	if isEmpty(v) {
+		return nil
+	}
+	if !re.MatchString(v.String()) {
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s is not valid", v.Name)
+		}
+		return i18n.Errorfc("form", "not valid")
+	}
+	return nil
+}
+
+func patternRule(v *RuleValue) error {
+	re, err := compileRegexp("^(?:" + v.Arg + ")$")
+	if err != nil {
+		return err
+	}
+	if isEmpty(v) {
+		return nil
+	}
+	if !re.MatchString(v.String()) {
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s is not valid", v.Name)
+		}
+		return i18n.Errorfc("form", "not valid")
+	}
+	return nil
+}
+
+// compareNumber returns -1, 0 or 1 if the value is lower, equal
+// or greater than the given argument.
+func compareNumber(v *RuleValue, rule string) (int, error) {
+	val := reflect.Indirect(v.Value)
+	arg, err := strconv.ParseFloat(v.Arg, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s argument %q: %s", rule, v.Arg, err)
+	}
+	var f float64
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f = float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		f = float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		f = val.Float()
+	default:
+		return 0, fmt.Errorf("rule %s can't be used with type %s", rule, v.Value.Type())
+	}
+	switch {
+	case f < arg:
+		return -1, nil
+	case f > arg:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func minRule(v *RuleValue) error {
+	if v.Value.Kind() == reflect.Ptr && v.Value.IsNil() {
+		return nil
+	}
+	c, err := compareNumber(v, "min")
+	if err != nil {
+		return err
+	}
+	if c < 0 {
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s must be at least %s", v.Name, v.Arg)
+		}
+		return i18n.Errorfc("form", "must be at least %s", v.Arg)
+	}
+	return nil
+}
+
+func maxRule(v *RuleValue) error {
+	if v.Value.Kind() == reflect.Ptr && v.Value.IsNil() {
+		return nil
+	}
+	c, err := compareNumber(v, "max")
+	if err != nil {
+		return err
+	}
+	if c > 0 {
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s must be at most %s", v.Name, v.Arg)
+		}
+		return i18n.Errorfc("form", "must be at most %s", v.Arg)
+	}
+	return nil
+}
+
+func oneOfRule(v *RuleValue) error {
+	if isEmpty(v) {
+		return nil
+	}
+	s := v.String()
+	choices := strings.Split(v.Arg, "|")
+	for _, c := range choices {
+		if s == c {
+			return nil
+		}
+	}
+	values := strings.Join(choices, ", ")
+	if v.Name != "" {
+		return i18n.Errorfc("form", "%s must be one of %s", v.Name, values)
+	}
+	return i18n.Errorfc("form", "must be one of %s", values)
+}
+
+func uuidRule(v *RuleValue) error {
+	if isEmpty(v) {
+		return nil
+	}
+	if !uuidRe.MatchString(v.String()) {
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s must be a valid UUID", v.Name)
+		}
+		return i18n.Errorfc("form", "must be a valid UUID")
+	}
+	return nil
+}
+
+// ruleTime returns the time in the value and the one in the argument.
+func ruleTime(v *RuleValue, rule string) (time.Time, time.Time, error) {
+	val := reflect.Indirect(v.Value)
+	if val.Type() != timeType {
+		return time.Time{}, time.Time{}, fmt.Errorf("rule %s can't be used with type %s", rule, v.Value.Type())
+	}
+	t := val.Interface().(time.Time)
+	if v.Arg == "now" {
+		return t, time.Now(), nil
+	}
+	for _, layout := range dateLayouts {
+		if arg, err := time.Parse(layout, v.Arg); err == nil {
+			return t, arg, nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid %s argument %q, must be \"now\" or a RFC 3339 date", rule, v.Arg)
+}
+
+func afterRule(v *RuleValue) error {
+	if isEmpty(v) {
+		return nil
+	}
+	t, arg, err := ruleTime(v, "after")
+	if err != nil {
+		return err
+	}
+	if !t.After(arg) {
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s must be after %s", v.Name, v.Arg)
+		}
+		return i18n.Errorfc("form", "must be after %s", v.Arg)
+	}
+	return nil
+}
+
+func beforeRule(v *RuleValue) error {
+	if isEmpty(v) {
+		return nil
+	}
+	t, arg, err := ruleTime(v, "before")
+	if err != nil {
+		return err
+	}
+	if !t.Before(arg) {
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s must be before %s", v.Name, v.Arg)
+		}
+		return i18n.Errorfc("form", "must be before %s", v.Arg)
+	}
+	return nil
+}
+
+// ruleFields returns the values of the field and the
+// field named by the rule argument.
+func ruleFields(v *RuleValue, rule string) (interface{}, interface{}, error) {
+	parent := reflect.Indirect(v.Parent)
+	if parent.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("rule %s requires a parent struct", rule)
+	}
+	other := parent.FieldByName(v.Arg)
+	if !other.IsValid() {
+		return nil, nil, fmt.Errorf("rule %s references unknown field %q in %s", rule, v.Arg, parent.Type())
+	}
+	return indirectInterface(v.Value), indirectInterface(other), nil
+}
+
+func indirectInterface(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+func eqFieldRule(v *RuleValue) error {
+	val, other, err := ruleFields(v, "eq_field")
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(val, other) {
+		otherName := stringutil.CamelCaseToWords(v.Arg, " ")
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s must be equal to %s", v.Name, otherName)
+		}
+		return i18n.Errorfc("form", "must be equal to %s", otherName)
+	}
+	return nil
+}
+
+func neFieldRule(v *RuleValue) error {
+	val, other, err := ruleFields(v, "ne_field")
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(val, other) {
+		otherName := stringutil.CamelCaseToWords(v.Arg, " ")
+		if v.Name != "" {
+			return i18n.Errorfc("form", "%s must be different from %s", v.Name, otherName)
+		}
+		return i18n.Errorfc("form", "must be different from %s", otherName)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package structs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type ruleAddress struct {
+	City string `form:",one_of=Madrid|Paris"`
+	Zip  string `form:",regexp='^[0-9]{5}$'"`
+	Code string `form:",pattern=[A-Z]{3}"`
+}
+
+type RuleEmbedded struct {
+	Id string `form:",uuid"`
+}
+
+type ruleUser struct {
+	RuleEmbedded `form:",inline"`
+	Email        string `form:",email"`
+	Website      string `form:",url"`
+	Age          int    `form:",min=18,max=120"`
+	Password     string
+	Confirm      string    `form:",eq_field=Password"`
+	Username     string    `form:",ne_field=Password"`
+	Birthday     time.Time `form:",after=1900-01-01,before=now"`
+	Address      *ruleAddress
+	Ignored      string `form:"-,email"`
+}
+
+func validRuleUser() *ruleUser {
+	return &ruleUser{
+		RuleEmbedded: RuleEmbedded{Id: "6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		Email:        "alice@example.com",
+		Website:      "http://www.example.com",
+		Age:          30,
+		Password:     "secret",
+		Confirm:      "secret",
+		Username:     "alice",
+		Birthday:     time.Date(1985, 5, 1, 0, 0, 0, 0, time.UTC),
+		Address:      &ruleAddress{City: "Madrid", Zip: "28001", Code: "MAD"},
+		Ignored:      "not an email",
+	}
+}
+
+func TestValidateStruct(t *testing.T) {
+	tags := []string{"form"}
+	if err := ValidateStruct(validRuleUser(), tags); err != nil {
+		t.Fatalf("unexpected error validating valid struct: %s", err)
+	}
+	cases := []struct {
+		field  string
+		modify func(u *ruleUser)
+	}{
+		{"RuleEmbedded.Id", func(u *ruleUser) { u.Id = "not-an-uuid" }},
+		{"Email", func(u *ruleUser) { u.Email = "alice" }},
+		{"Website", func(u *ruleUser) { u.Website = "/relative" }},
+		{"Age", func(u *ruleUser) { u.Age = 17 }},
+		{"Age", func(u *ruleUser) { u.Age = 121 }},
+		{"Confirm", func(u *ruleUser) { u.Confirm = "other" }},
+		{"Username", func(u *ruleUser) { u.Username = u.Password }},
+		{"Birthday", func(u *ruleUser) { u.Birthday = time.Now().Add(time.Hour) }},
+		{"Birthday", func(u *ruleUser) { u.Birthday = time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC) }},
+		{"Address.City", func(u *ruleUser) { u.Address.City = "London" }},
+		{"Address.Zip", func(u *ruleUser) { u.Address.Zip = "ABC" }},
+		{"Address.Code", func(u *ruleUser) { u.Address.Code = "MADRID" }},
+	}
+	for _, v := range cases {
+		u := validRuleUser()
+		v.modify(u)
+		err := ValidateStruct(u, tags)
+		ferr, ok := err.(*FieldError)
+		if !ok {
+			t.Errorf("expecting *FieldError for field %s, got %v", v.field, err)
+			continue
+		}
+		if ferr.Field != v.field {
+			t.Errorf("expecting error in field %s, got %s (%s)", v.field, ferr.Field, ferr)
+		}
+	}
+	// Empty values are accepted
+	empty := validRuleUser()
+	empty.Email = ""
+	empty.Website = ""
+	empty.Birthday = time.Time{}
+	empty.Address = nil
+	if err := ValidateStruct(empty, tags); err != nil {
+		t.Errorf("unexpected error validating empty values: %s", err)
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	tags := []string{"form"}
+	u := validRuleUser()
+	u.Age = 17
+	u.Address.Zip = "ABC"
+	if err := ValidateFields(u, tags, []string{"Email", "Address.City"}); err != nil {
+		t.Errorf("unexpected error validating valid fields: %s", err)
+	}
+	for _, v := range []string{"Age", "Address.Zip"} {
+		err := ValidateFields(u, tags, []string{"Email", v})
+		if ferr, ok := err.(*FieldError); !ok || ferr.Field != v {
+			t.Errorf("expecting *FieldError for field %s, got %v", v, err)
+		}
+	}
+	if err := ValidateFields(u, tags, nil); err == nil {
+		t.Error("expecting an error when validating all the fields")
+	}
+}
+
+func TestRegisterRule(t *testing.T) {
+	errOdd := errors.New("odd")
+	RegisterRule("even", func(v *RuleValue) error {
+		if v.Value.Int()%2 != 0 {
+			return errOdd
+		}
+		return nil
+	})
+	tag := MustParseTag(",even")
+	if err := ValidateRules("", reflect.ValueOf(2), reflect.Value{}, tag); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+	if err := ValidateRules("", reflect.ValueOf(3), reflect.Value{}, tag); err != errOdd {
+		t.Errorf("expecting errOdd, got %v", err)
+	}
+	if err := ValidateRules("", reflect.ValueOf("x"), reflect.Value{}, MustParseTag(",min=1")); err == nil {
+		t.Error("expecting an error when using min with a string")
+	}
+}